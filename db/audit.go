@@ -0,0 +1,137 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lcmps/DevicesAPI/model/database"
+	"gorm.io/gorm"
+)
+
+// Actions recorded on the audit trail
+const (
+	AuditCreate      = "create"
+	AuditUpdate      = "update"
+	AuditStateChange = "state_change"
+	AuditDelete      = "delete"
+	AuditRestore     = "restore"
+)
+
+// AuditActions lists every action that can be found on the audit trail.
+var AuditActions = []string{AuditCreate, AuditUpdate, AuditStateChange, AuditDelete, AuditRestore}
+
+// Actor recorded when the context carries no audit info, e.g. changes made by the service itself
+const systemActor = "system"
+
+// AuditInfo identifies who made a change, and through which request.
+type AuditInfo struct {
+	Actor     string
+	RequestID string
+}
+
+type auditInfoKey struct{}
+
+// WithAuditInfo returns a copy of ctx carrying info, recorded along every change made with it.
+func WithAuditInfo(ctx context.Context, info AuditInfo) context.Context {
+	return context.WithValue(ctx, auditInfoKey{}, info)
+}
+
+func auditInfoFrom(ctx context.Context) AuditInfo {
+	info, _ := ctx.Value(auditInfoKey{}).(AuditInfo)
+	if info.Actor == "" {
+		info.Actor = systemActor
+	}
+	return info
+}
+
+// AuditQuery filters the audit trail, zero values mean no filter.
+// From is inclusive and To exclusive. A negative Limit returns every record.
+type AuditQuery struct {
+	DeviceID string
+	Action   string
+	From     time.Time
+	To       time.Time
+	Limit    int
+	Offset   int
+}
+
+// updateAction tells which action an update of the device was.
+func updateAction(before, after database.Device) string {
+	if before.State != after.State {
+		return AuditStateChange
+	}
+	return AuditUpdate
+}
+
+// newAuditRecord builds the record of a change made with ctx, before is nil on creation.
+func newAuditRecord(ctx context.Context, action string, before, after *database.Device) (database.AuditRecord, error) {
+	info := auditInfoFrom(ctx)
+	record := database.AuditRecord{
+		DeviceID:  after.ID,
+		Action:    action,
+		Actor:     info.Actor,
+		RequestID: info.RequestID,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	snapshot, err := json.Marshal(after)
+	if err != nil {
+		return record, fmt.Errorf("failed to write audit record: %w", err)
+	}
+	record.After = string(snapshot)
+
+	if before != nil {
+		snapshot, err := json.Marshal(before)
+		if err != nil {
+			return record, fmt.Errorf("failed to write audit record: %w", err)
+		}
+		previous := string(snapshot)
+		record.Before = &previous
+	}
+
+	return record, nil
+}
+
+// writeAudit records a change on the same transaction that made it, so one never exists without the other.
+func writeAudit(tx *gorm.DB, action string, before, after *database.Device) error {
+	record, err := newAuditRecord(tx.Statement.Context, action, before, after)
+	if err != nil {
+		return err
+	}
+	if err := tx.Create(&record).Error; err != nil {
+		return fmt.Errorf("failed to write audit record: %w", err)
+	}
+	return nil
+}
+
+// GetAuditRecords returns the audit records matching the query, newest first.
+func (db *DB) GetAuditRecords(ctx context.Context, query AuditQuery) ([]database.AuditRecord, error) {
+	records := []database.AuditRecord{}
+
+	q := db.Connector.WithContext(ctx)
+	if query.DeviceID != "" {
+		if _, err := uuid.Parse(query.DeviceID); err != nil {
+			return records, ErrInvalidID
+		}
+		q = q.Where("device_id = ?", query.DeviceID)
+	}
+	if query.Action != "" {
+		q = q.Where("action = ?", query.Action)
+	}
+	if !query.From.IsZero() {
+		q = q.Where("created_at >= ?", query.From.UTC())
+	}
+	if !query.To.IsZero() {
+		q = q.Where("created_at < ?", query.To.UTC())
+	}
+
+	result := q.Order("id DESC").Limit(query.Limit).Offset(query.Offset).Find(&records)
+	if result.Error != nil {
+		return records, fmt.Errorf("failed to get audit records: %w", result.Error)
+	}
+
+	return records, nil
+}
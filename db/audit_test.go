@@ -0,0 +1,97 @@
+package db_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lcmps/DevicesAPI/db"
+	"github.com/lcmps/DevicesAPI/model/database"
+)
+
+// testAuditTrail runs the same changes against any store and checks what was recorded.
+func testAuditTrail(t *testing.T, store db.DeviceStore) {
+	ctx := db.WithAuditInfo(t.Context(), db.AuditInfo{Actor: "jane", RequestID: "req-1"})
+
+	device := &database.Device{Name: "Audited", Brand: "BrandX", State: "Available"}
+	if err := store.CreateDevice(ctx, device); err != nil {
+		t.Fatalf("failed to create device: %v", err)
+	}
+	if err := store.UpdateDevice(ctx, &database.Device{ID: device.ID, Name: "Audited 2"}); err != nil {
+		t.Fatalf("failed to update device: %v", err)
+	}
+	if err := store.UpdateDevice(ctx, &database.Device{ID: device.ID, State: "Inactive"}); err != nil {
+		t.Fatalf("failed to update device: %v", err)
+	}
+	// Failed changes leave nothing behind
+	if err := store.UpdateDevice(ctx, &database.Device{ID: device.ID, State: "Available", Version: 1}); !errors.Is(err, db.ErrVersionMismatch) {
+		t.Fatalf("expected ErrVersionMismatch, got %v", err)
+	}
+	// Changes made without audit info are attributed to the service itself
+	if err := store.DeleteDevice(t.Context(), device.ID.String(), 0); err != nil {
+		t.Fatalf("failed to delete device: %v", err)
+	}
+
+	records, err := store.GetAuditRecords(t.Context(), db.AuditQuery{DeviceID: device.ID.String(), Limit: -1})
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("expected 4 audit records, got %d", len(records))
+	}
+
+	// Newest first
+	wantActions := []string{db.AuditDelete, db.AuditStateChange, db.AuditUpdate, db.AuditCreate}
+	wantActors := []string{"system", "jane", "jane", "jane"}
+	for i, record := range records {
+		if record.Action != wantActions[i] || record.Actor != wantActors[i] {
+			t.Fatalf("record %d: expected %s by %s, got %s by %s", i, wantActions[i], wantActors[i], record.Action, record.Actor)
+		}
+	}
+	if records[3].Before != nil || records[3].RequestID != "req-1" {
+		t.Fatalf("unexpected creation record %+v", records[3])
+	}
+
+	var before, after database.Device
+	_ = json.Unmarshal([]byte(*records[1].Before), &before)
+	_ = json.Unmarshal([]byte(records[1].After), &after)
+	if before.State != "Available" || after.State != "Inactive" || after.Version != before.Version+1 {
+		t.Fatalf("unexpected state change snapshots: before %+v, after %+v", before, after)
+	}
+
+	records, _ = store.GetAuditRecords(t.Context(), db.AuditQuery{Action: db.AuditUpdate, Limit: 10})
+	if len(records) != 1 || !strings.Contains(records[0].After, "Audited 2") {
+		t.Fatalf("expected the rename record, got %+v", records)
+	}
+	records, _ = store.GetAuditRecords(t.Context(), db.AuditQuery{From: time.Now().Add(time.Hour), Limit: 10})
+	if len(records) != 0 {
+		t.Fatalf("expected no records in the future, got %d", len(records))
+	}
+	records, _ = store.GetAuditRecords(t.Context(), db.AuditQuery{To: time.Now().Add(time.Hour), Limit: 2, Offset: 1})
+	if len(records) != 2 || records[0].Action != db.AuditStateChange {
+		t.Fatalf("expected the second page of records, got %+v", records)
+	}
+
+	if _, err := store.GetAuditRecords(t.Context(), db.AuditQuery{DeviceID: "not-a-uuid"}); !errors.Is(err, db.ErrInvalidID) {
+		t.Fatalf("expected ErrInvalidID, got %v", err)
+	}
+}
+
+func TestMemory_AuditTrail(t *testing.T) {
+	testAuditTrail(t, db.NewMemory())
+}
+
+func TestSQLite_AuditTrail(t *testing.T) {
+	dbInstance := newSQLite(t)
+	testAuditTrail(t, dbInstance)
+
+	// Records can't be changed once written
+	if err := dbInstance.Connector.Exec("UPDATE device_audit SET actor = 'someone else'").Error; err == nil {
+		t.Fatal("expected audit records to be immutable")
+	}
+	if err := dbInstance.Connector.Exec("DELETE FROM device_audit").Error; err == nil {
+		t.Fatal("expected audit records to be immutable")
+	}
+}
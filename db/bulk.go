@@ -0,0 +1,426 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lcmps/DevicesAPI/model/database"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// bulkBatchSize is the number of devices inserted per statement by CreateDevices.
+const bulkBatchSize = 100
+
+// BulkMode tells how a bulk write handles the items that fail.
+type BulkMode int
+
+const (
+	// BulkAtomic writes every item or none of them
+	BulkAtomic BulkMode = iota
+	// BulkBestEffort writes the items that succeed, even if others fail
+	BulkBestEffort
+)
+
+// BulkChange selects live devices, either by ID or through a query, and tells what to do with them.
+type BulkChange struct {
+	// IDs of the devices to change, in that order. When empty, the live devices matching Query
+	// are changed instead, up to its Limit and in its order
+	IDs   []string
+	Query DeviceQuery
+	// Delete soft deletes the devices, otherwise they are moved to State
+	Delete bool
+	State  string
+	// Check may refuse to change a device by returning an error, e.g. a StateViolationError
+	// for a forbidden transition. Devices in use are never deleted, regardless of Check.
+	Check func(device database.Device) error
+	Mode  BulkMode
+	// DryRun rolls the change back, only reporting what it would do
+	DryRun bool
+}
+
+// BulkOutcome is what a bulk change did, or would do on a dry run, to a single device.
+type BulkOutcome struct {
+	ID string
+	// Device as it is after the change, unset when it wasn't found
+	Device database.Device
+	// Changed is false when there was nothing to do, e.g. the device was already on the given state
+	Changed bool
+	Err     error
+}
+
+// apply changes the device as asked, reporting whether there was anything to change.
+func (c BulkChange) apply(ctx context.Context, device *database.Device, now time.Time) (bool, error) {
+	if c.Check != nil {
+		if err := c.Check(*device); err != nil {
+			return false, err
+		}
+	}
+	if c.Delete {
+		if err := checkDeletable(*device); err != nil {
+			return false, err
+		}
+		device.Deleted = true
+		device.DeletedAt = &now
+		device.DeletedBy = auditInfoFrom(ctx).Actor
+	} else {
+		if device.State == c.State {
+			return false, nil
+		}
+		device.State = c.State
+	}
+	device.Version++
+	return true, nil
+}
+
+// auditAction is the audit action of the change made to a device.
+func (c BulkChange) auditAction(before, after database.Device) string {
+	if c.Delete {
+		return AuditDelete
+	}
+	return updateAction(before, after)
+}
+
+// bulkOutcomes starts the outcomes of a change made by ID, finding the devices through lookup.
+func bulkOutcomes(ids []string, lookup func(id uuid.UUID) (database.Device, bool)) []BulkOutcome {
+	outcomes := make([]BulkOutcome, 0, len(ids))
+	for _, id := range ids {
+		outcome := BulkOutcome{ID: id}
+		guid, err := uuid.Parse(id)
+		if err != nil {
+			outcome.Err = ErrInvalidID
+		} else if device, ok := lookup(guid); ok {
+			outcome.Device = device
+		} else {
+			outcome.Err = ErrNotFound
+		}
+		outcomes = append(outcomes, outcome)
+	}
+	return outcomes
+}
+
+// abortOutcomes reports whether any device failed and, if so, marks the others with ErrBulkAborted.
+func abortOutcomes(outcomes []BulkOutcome) bool {
+	errs := make([]error, len(outcomes))
+	for i, outcome := range outcomes {
+		errs[i] = outcome.Err
+	}
+	if !abortFailed(errs) {
+		return false
+	}
+	for i := range outcomes {
+		outcomes[i].Err = errs[i]
+	}
+	return true
+}
+
+// errBulkRollback rolls back a bulk write once one of its items failed, or when it was a dry run.
+var errBulkRollback = errors.New("bulk write rolled back")
+
+// abortFailed reports whether any item failed and, if so, marks the others with ErrBulkAborted.
+func abortFailed(errs []error) bool {
+	failed := false
+	for _, err := range errs {
+		if err != nil {
+			failed = true
+			break
+		}
+	}
+	if failed {
+		for i, err := range errs {
+			if err == nil {
+				errs[i] = ErrBulkAborted
+			}
+		}
+	}
+	return failed
+}
+
+// CreateDevices creates the devices in batches, within a single transaction, and returns the error of each
+// one (nil when it was created), in the same order. Devices conflicting with a live device, or with another
+// one of the list, fail with ErrConflict. The error returned last is a failure of the whole write.
+func (db *DB) CreateDevices(ctx context.Context, devices []database.Device, mode BulkMode) ([]error, error) {
+	errs := make([]error, len(devices))
+	for i := range devices {
+		db.setDefaults(&devices[i])
+	}
+
+	err := db.Connector.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for start := 0; start < len(devices); start += bulkBatchSize {
+			end := min(start+bulkBatchSize, len(devices))
+			if err := createBatch(tx, devices[start:end], errs[start:end]); err != nil {
+				return err
+			}
+		}
+		if mode == BulkAtomic && abortFailed(errs) {
+			return errBulkRollback
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errBulkRollback) {
+		return nil, err
+	}
+	return errs, nil
+}
+
+// createBatch inserts the batch with a single statement, falling back to one device at a time to find out
+// which ones conflict when it fails. Each attempt runs within a savepoint, so a failure doesn't abort tx.
+func createBatch(tx *gorm.DB, batch []database.Device, errs []error) error {
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&batch).Error; err != nil {
+			return err
+		}
+		return writeAudits(tx, batch)
+	})
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, gorm.ErrDuplicatedKey) {
+		return fmt.Errorf("failed to create devices: %w", err)
+	}
+
+	for i := range batch {
+		err := tx.Transaction(func(tx *gorm.DB) error {
+			result := tx.Create(&batch[i])
+			if errors.Is(result.Error, gorm.ErrDuplicatedKey) {
+				return ErrConflict
+			}
+			if result.Error != nil {
+				return fmt.Errorf("failed to create device: %w", result.Error)
+			}
+			return writeAudit(tx, AuditCreate, nil, &batch[i])
+		})
+		if errors.Is(err, ErrConflict) {
+			errs[i] = err
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeAudits records the creation of every device with a single statement.
+func writeAudits(tx *gorm.DB, devices []database.Device) error {
+	records := make([]database.AuditRecord, 0, len(devices))
+	for i := range devices {
+		record, err := newAuditRecord(tx.Statement.Context, AuditCreate, nil, &devices[i])
+		if err != nil {
+			return err
+		}
+		records = append(records, record)
+	}
+	if err := tx.Create(&records).Error; err != nil {
+		return fmt.Errorf("failed to write audit record: %w", err)
+	}
+	return nil
+}
+
+// lockDevices fetches the devices to change, locking their rows until the transaction ends.
+func (db *DB) lockDevices(tx *gorm.DB, change BulkChange) ([]BulkOutcome, error) {
+	query := tx
+	if db.driver == driverPostgres {
+		query = tx.Clauses(clause.Locking{Strength: "UPDATE"})
+	}
+
+	var devices []database.Device
+	if len(change.IDs) == 0 {
+		filter := change.Query
+		filter.Deleted = ExcludeDeleted
+		keys, err := sortKeys(filter.Sort)
+		if err != nil {
+			return nil, err
+		}
+		result := db.filterDevices(query, filter).Order(orderClause(keys, false)).Limit(filter.Limit).Find(&devices)
+		if result.Error != nil {
+			return nil, fmt.Errorf("failed to get devices: %w", result.Error)
+		}
+
+		outcomes := make([]BulkOutcome, 0, len(devices))
+		for _, device := range devices {
+			outcomes = append(outcomes, BulkOutcome{ID: device.ID.String(), Device: device})
+		}
+		return outcomes, nil
+	}
+
+	var ids []uuid.UUID
+	for _, id := range change.IDs {
+		if guid, err := uuid.Parse(id); err == nil {
+			ids = append(ids, guid)
+		}
+	}
+	// Always locking in the same order, so concurrent changes don't deadlock
+	if len(ids) > 0 {
+		result := query.Where("id IN ? AND deleted = FALSE", ids).Order("id").Find(&devices)
+		if result.Error != nil {
+			return nil, fmt.Errorf("failed to get devices: %w", result.Error)
+		}
+	}
+
+	found := make(map[uuid.UUID]database.Device, len(devices))
+	for _, device := range devices {
+		found[device.ID] = device
+	}
+	return bulkOutcomes(change.IDs, func(id uuid.UUID) (database.Device, bool) {
+		device, ok := found[id]
+		return device, ok
+	}), nil
+}
+
+// ChangeDevices applies the change to every selected device within a single transaction and returns
+// the outcome of each one. Devices that can't be changed are reported with the reason why.
+func (db *DB) ChangeDevices(ctx context.Context, change BulkChange) ([]BulkOutcome, error) {
+	var outcomes []BulkOutcome
+
+	err := db.Connector.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if outcomes, err = db.lockDevices(tx, change); err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		for i := range outcomes {
+			outcome := &outcomes[i]
+			if outcome.Err != nil {
+				continue
+			}
+			// Devices are refused before anything is written, so there's no failed write to undo
+			after := outcome.Device
+			changed, err := change.apply(ctx, &after, now)
+			if err != nil {
+				outcome.Err = err
+				continue
+			}
+			if !changed {
+				continue
+			}
+
+			result := tx.Model(&after).Select("state", "deleted", "deleted_at", "deleted_by", "version").Updates(&after)
+			if result.Error != nil {
+				return fmt.Errorf("failed to change device: %w", result.Error)
+			}
+			if err := writeAudit(tx, change.auditAction(outcome.Device, after), &outcome.Device, &after); err != nil {
+				return err
+			}
+			outcome.Device, outcome.Changed = after, true
+		}
+
+		if change.Mode == BulkAtomic && abortOutcomes(outcomes) || change.DryRun {
+			return errBulkRollback
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errBulkRollback) {
+		return nil, err
+	}
+	return outcomes, nil
+}
+
+// CreateDevices creates the devices one by one, undoing them all on failure in atomic mode.
+func (m *MemoryDB) CreateDevices(ctx context.Context, devices []database.Device, mode BulkMode) ([]error, error) {
+	errs := make([]error, len(devices))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	audited := len(m.audit)
+	for i := range devices {
+		err := m.create(ctx, &devices[i])
+		if errors.Is(err, ErrConflict) {
+			errs[i] = err
+			continue
+		}
+		if err != nil {
+			m.undoCreate(devices[:i], errs, audited)
+			return nil, err
+		}
+	}
+	if mode == BulkAtomic && abortFailed(errs) {
+		m.undoCreate(devices, errs, audited)
+	}
+	return errs, nil
+}
+
+// undoCreate removes the devices created by a bulk write, along with their audit records.
+// Failed devices are skipped, since they may share the id of a device created before.
+func (m *MemoryDB) undoCreate(devices []database.Device, errs []error, audited int) {
+	for i, device := range devices {
+		if !errors.Is(errs[i], ErrConflict) {
+			delete(m.devices, device.ID)
+		}
+	}
+	m.audit = m.audit[:audited]
+}
+
+// ChangeDevices applies the change to every selected device, putting them back on failure in atomic mode.
+func (m *MemoryDB) ChangeDevices(ctx context.Context, change BulkChange) ([]BulkOutcome, error) {
+	filter := change.Query
+	filter.Deleted = ExcludeDeleted
+	keys, err := sortKeys(filter.Sort)
+	if err != nil {
+		return nil, err
+	}
+	if filter.Filter != nil {
+		if err := filter.Filter.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var outcomes []BulkOutcome
+	if len(change.IDs) == 0 {
+		for _, device := range m.list(filter, keys) {
+			outcomes = append(outcomes, BulkOutcome{ID: device.ID.String(), Device: device})
+		}
+	} else {
+		outcomes = bulkOutcomes(change.IDs, func(id uuid.UUID) (database.Device, bool) {
+			device, ok := m.devices[id]
+			return device, ok && !device.Deleted
+		})
+	}
+
+	audited := len(m.audit)
+	var changed []database.Device
+	now := time.Now().UTC()
+	for i := range outcomes {
+		outcome := &outcomes[i]
+		if outcome.Err != nil {
+			continue
+		}
+		after := outcome.Device
+		ok, err := change.apply(ctx, &after, now)
+		if err != nil {
+			outcome.Err = err
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if err := m.writeAudit(ctx, change.auditAction(outcome.Device, after), &outcome.Device, &after); err != nil {
+			m.undoChange(changed, audited)
+			return nil, err
+		}
+		changed = append(changed, outcome.Device)
+		m.devices[after.ID] = after
+		outcome.Device, outcome.Changed = after, true
+	}
+
+	if change.Mode == BulkAtomic && abortOutcomes(outcomes) || change.DryRun {
+		m.undoChange(changed, audited)
+	}
+	return outcomes, nil
+}
+
+// undoChange puts back the devices as they were before a bulk change, dropping its audit records.
+func (m *MemoryDB) undoChange(before []database.Device, audited int) {
+	for _, device := range before {
+		m.devices[device.ID] = device
+	}
+	m.audit = m.audit[:audited]
+}
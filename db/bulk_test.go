@@ -0,0 +1,212 @@
+package db_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/lcmps/DevicesAPI/db"
+	"github.com/lcmps/DevicesAPI/model/database"
+)
+
+// shipment returns n devices with distinct names, more than a single batch when n is above 100.
+func shipment(n int) []database.Device {
+	devices := make([]database.Device, 0, n)
+	for i := range n {
+		devices = append(devices, database.Device{Name: fmt.Sprintf("Device %03d", i), Brand: "BrandA", State: "Available"})
+	}
+	return devices
+}
+
+// testCreateDevices runs the same bulk creations against any store.
+func testCreateDevices(t *testing.T, store db.DeviceStore) {
+	ctx := db.WithAuditInfo(t.Context(), db.AuditInfo{Actor: "jane"})
+
+	if err := store.CreateDevice(ctx, &database.Device{Name: "Device 120", Brand: "BrandA", State: "Available"}); err != nil {
+		t.Fatalf("failed to create device: %v", err)
+	}
+	count := func() int64 {
+		count, _, err := store.CountDevices(ctx, db.DeviceQuery{}, db.CountExact)
+		if err != nil {
+			t.Fatalf("failed to count devices: %v", err)
+		}
+		return count
+	}
+
+	// The conflict lies on the second batch, along with a duplicate within the request
+	devices := shipment(150)
+	devices = append(devices, database.Device{Name: "Device 000", Brand: "BrandA", State: "Available"})
+	errs, err := store.CreateDevices(ctx, devices, db.BulkAtomic)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	for i, err := range errs {
+		want := db.ErrBulkAborted
+		if i == 120 || i == 150 {
+			want = db.ErrConflict
+		}
+		if !errors.Is(err, want) {
+			t.Fatalf("expected %v for device %d, got %v", want, i, err)
+		}
+	}
+	if n := count(); n != 1 {
+		t.Fatalf("expected the atomic creation to be rolled back, got %d devices", n)
+	}
+	if records, _ := store.GetAuditRecords(ctx, db.AuditQuery{Limit: 10}); len(records) != 1 {
+		t.Fatalf("expected the audit records to be rolled back too, got %d", len(records))
+	}
+
+	devices = shipment(150)
+	devices = append(devices, database.Device{Name: "Device 000", Brand: "BrandA", State: "Available"})
+	errs, err = store.CreateDevices(ctx, devices, db.BulkBestEffort)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	for i, err := range errs {
+		if i == 120 || i == 150 {
+			if !errors.Is(err, db.ErrConflict) {
+				t.Fatalf("expected ErrConflict for device %d, got %v", i, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("expected device %d to be created, got %v", i, err)
+		}
+		if devices[i].ID == uuid.Nil || devices[i].CreatedAt.IsZero() || devices[i].Version != 1 {
+			t.Fatalf("expected device %d to hold its stored values, got %+v", i, devices[i])
+		}
+	}
+	if n := count(); n != 150 {
+		t.Fatalf("expected 150 devices, got %d", n)
+	}
+	stored, err := store.GetDeviceByID(ctx, devices[42].ID.String())
+	if err != nil || stored.Name != "Device 042" {
+		t.Fatalf("expected device to be stored, got %+v and %v", stored, err)
+	}
+	records, _ := store.GetAuditRecords(ctx, db.AuditQuery{DeviceID: devices[42].ID.String(), Action: db.AuditCreate, Limit: 10})
+	if len(records) != 1 || records[0].Actor != "jane" {
+		t.Fatalf("expected the creation to be audited, got %+v", records)
+	}
+
+	errs, err = store.CreateDevices(ctx, nil, db.BulkAtomic)
+	if err != nil || len(errs) != 0 {
+		t.Fatalf("expected nothing to be created, got %v and %v", errs, err)
+	}
+}
+
+func TestMemory_CreateDevices(t *testing.T) {
+	testCreateDevices(t, db.NewMemory())
+}
+
+func TestSQLite_CreateDevices(t *testing.T) {
+	testCreateDevices(t, newSQLite(t))
+}
+
+// testChangeDevices runs the same bulk state changes and deletions against any store.
+func testChangeDevices(t *testing.T, store db.DeviceStore) {
+	ctx := db.WithAuditInfo(t.Context(), db.AuditInfo{Actor: "jane"})
+
+	alpha := &database.Device{Name: "Alpha", Brand: "BrandA", State: "Available"}
+	beta := &database.Device{Name: "Beta", Brand: "BrandA", State: "In-Use"}
+	gamma := &database.Device{Name: "Gamma", Brand: "BrandB", State: "Inactive"}
+	for _, d := range []*database.Device{alpha, beta, gamma} {
+		if err := store.CreateDevice(ctx, d); err != nil {
+			t.Fatalf("failed to create device: %v", err)
+		}
+	}
+	state := func(device *database.Device) string {
+		stored, err := store.GetDeviceByID(ctx, device.ID.String())
+		if err != nil {
+			return "deleted"
+		}
+		return stored.State
+	}
+	// Inactive devices can't be used, as the web layer does through the state machine
+	noInactive := func(device database.Device) error {
+		if device.State == "Inactive" {
+			return &db.StateViolationError{Reason: "cannot change an inactive device"}
+		}
+		return nil
+	}
+	missing := "00000000-0000-0000-0000-000000000000"
+	ids := []string{alpha.ID.String(), beta.ID.String(), gamma.ID.String(), missing, "not-a-uuid"}
+
+	outcomes, err := store.ChangeDevices(ctx, db.BulkChange{IDs: ids, State: "In-Use", Check: noInactive})
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	wantErrs := []error{db.ErrBulkAborted, db.ErrBulkAborted, db.ErrStateViolation, db.ErrNotFound, db.ErrInvalidID}
+	for i, outcome := range outcomes {
+		if outcome.ID != ids[i] || !errors.Is(outcome.Err, wantErrs[i]) {
+			t.Fatalf("expected %v for device %s, got %+v", wantErrs[i], ids[i], outcome)
+		}
+	}
+	if state(alpha) != "Available" {
+		t.Fatalf("expected the atomic change to be rolled back, got %s", state(alpha))
+	}
+
+	outcomes, err = store.ChangeDevices(ctx, db.BulkChange{IDs: ids[:3], State: "In-Use", Check: noInactive, Mode: db.BulkBestEffort, DryRun: true})
+	if err != nil || len(outcomes) != 3 {
+		t.Fatalf("expected 3 outcomes, got %+v and %v", outcomes, err)
+	}
+	if !outcomes[0].Changed || outcomes[0].Device.State != "In-Use" || outcomes[0].Device.Version != 2 || outcomes[0].Err != nil {
+		t.Fatalf("expected alpha to be reported as changed, got %+v", outcomes[0])
+	}
+	if outcomes[1].Changed || outcomes[1].Err != nil {
+		t.Fatalf("expected beta to be left as it is, got %+v", outcomes[1])
+	}
+	if state(alpha) != "Available" {
+		t.Fatalf("expected the dry run to change nothing, got %s", state(alpha))
+	}
+
+	outcomes, _ = store.ChangeDevices(ctx, db.BulkChange{IDs: ids[:3], State: "In-Use", Check: noInactive, Mode: db.BulkBestEffort})
+	if !outcomes[0].Changed || !errors.Is(outcomes[2].Err, db.ErrStateViolation) {
+		t.Fatalf("unexpected outcomes %+v", outcomes)
+	}
+	if state(alpha) != "In-Use" || state(gamma) != "Inactive" {
+		t.Fatalf("expected only alpha to change, got %s and %s", state(alpha), state(gamma))
+	}
+	records, _ := store.GetAuditRecords(ctx, db.AuditQuery{DeviceID: alpha.ID.String(), Action: db.AuditStateChange, Limit: 10})
+	if len(records) != 1 || records[0].Actor != "jane" {
+		t.Fatalf("expected the state change to be audited, got %+v", records)
+	}
+
+	// Deleting through a filter, devices in use are refused
+	filter := db.DeviceQuery{Brand: "BrandA", Limit: 10}
+	outcomes, _ = store.ChangeDevices(ctx, db.BulkChange{Query: filter, Delete: true, Mode: db.BulkBestEffort})
+	if len(outcomes) != 2 || !errors.Is(outcomes[0].Err, db.ErrStateViolation) || !errors.Is(outcomes[1].Err, db.ErrStateViolation) {
+		t.Fatalf("expected both devices in use to be refused, got %+v", outcomes)
+	}
+	outcomes, _ = store.ChangeDevices(ctx, db.BulkChange{IDs: ids[:2], State: "Available"})
+	if !outcomes[0].Changed || !outcomes[1].Changed {
+		t.Fatalf("expected both devices to be made available, got %+v", outcomes)
+	}
+	outcomes, _ = store.ChangeDevices(ctx, db.BulkChange{Query: filter, Delete: true})
+	if len(outcomes) != 2 || outcomes[0].Err != nil || outcomes[1].Err != nil || !outcomes[0].Device.Deleted || outcomes[0].Device.DeletedBy != "jane" {
+		t.Fatalf("expected both devices to be deleted, got %+v", outcomes)
+	}
+	if state(alpha) != "deleted" || state(beta) != "deleted" || state(gamma) != "Inactive" {
+		t.Fatalf("expected only BrandA devices to be deleted, got %s, %s and %s", state(alpha), state(beta), state(gamma))
+	}
+	if records, _ := store.GetAuditRecords(ctx, db.AuditQuery{Action: db.AuditDelete, Limit: 10}); len(records) != 2 {
+		t.Fatalf("expected the deletions to be audited, got %d records", len(records))
+	}
+
+	// Deleted devices are no longer found, by ID or by filter
+	outcomes, _ = store.ChangeDevices(ctx, db.BulkChange{IDs: ids[:1], Delete: true})
+	if !errors.Is(outcomes[0].Err, db.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %+v", outcomes[0])
+	}
+	if outcomes, _ = store.ChangeDevices(ctx, db.BulkChange{Query: filter, Delete: true}); len(outcomes) != 0 {
+		t.Fatalf("expected no device to match, got %+v", outcomes)
+	}
+}
+
+func TestMemory_ChangeDevices(t *testing.T) {
+	testChangeDevices(t, db.NewMemory())
+}
+
+func TestSQLite_ChangeDevices(t *testing.T) {
+	testChangeDevices(t, newSQLite(t))
+}
@@ -0,0 +1,66 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/lcmps/DevicesAPI/db"
+	"github.com/lcmps/DevicesAPI/model/database"
+)
+
+// testCountDevices runs the same counts against any store.
+func testCountDevices(t *testing.T, store db.DeviceStore) {
+	ctx := t.Context()
+
+	alpha := &database.Device{Name: "Alpha", Brand: "BrandA", State: "Available"}
+	for _, d := range []*database.Device{
+		alpha,
+		{Name: "Beta", Brand: "BrandA", State: "Inactive"},
+		{Name: "Gamma", Brand: "BrandB", State: "In-Use"},
+	} {
+		if err := store.CreateDevice(ctx, d); err != nil {
+			t.Fatalf("failed to create device: %v", err)
+		}
+	}
+	if err := store.DeleteDevice(ctx, alpha.ID.String(), 0); err != nil {
+		t.Fatalf("failed to delete device: %v", err)
+	}
+
+	cases := []struct {
+		name  string
+		query db.DeviceQuery
+		want  int64
+	}{
+		{"Live", db.DeviceQuery{}, 2},
+		{"IncludeDeleted", db.DeviceQuery{Deleted: db.IncludeDeleted}, 3},
+		{"OnlyDeleted", db.DeviceQuery{Deleted: db.OnlyDeleted}, 1},
+		{"Name", db.DeviceQuery{Name: "bet"}, 1},
+		{"Brand", db.DeviceQuery{Brand: "BrandA"}, 1},
+		{"BrandIncludeDeleted", db.DeviceQuery{Brand: "BrandA", Deleted: db.IncludeDeleted}, 2},
+		{"State", db.DeviceQuery{State: "In-Use"}, 1},
+		{"NoMatch", db.DeviceQuery{Brand: "BrandC"}, 0},
+		// Pagination doesn't change the count
+		{"Paginated", db.DeviceQuery{Deleted: db.IncludeDeleted, Limit: 1, Offset: 1}, 3},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			count, estimated, err := store.CountDevices(ctx, tc.query, db.CountExact)
+			if err != nil || count != tc.want || estimated {
+				t.Fatalf("expected an exact count of %d devices, got %d (estimated: %t) and %v", tc.want, count, estimated, err)
+			}
+			// Only Postgres has statistics to estimate from, the other stores always count
+			count, estimated, err = store.CountDevices(ctx, tc.query, db.CountEstimate)
+			if err != nil || count != tc.want || estimated {
+				t.Fatalf("expected the estimate to fall back on an exact count of %d devices, got %d (estimated: %t) and %v", tc.want, count, estimated, err)
+			}
+		})
+	}
+}
+
+func TestMemory_CountDevices(t *testing.T) {
+	testCountDevices(t, db.NewMemory())
+}
+
+func TestSQLite_CountDevices(t *testing.T) {
+	testCountDevices(t, newSQLite(t))
+}
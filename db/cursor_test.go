@@ -0,0 +1,136 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lcmps/DevicesAPI/db"
+	"github.com/lcmps/DevicesAPI/model/database"
+)
+
+// testCursorPagination walks the device list through cursors on any store.
+func testCursorPagination(t *testing.T, store db.DeviceStore) {
+	ctx := t.Context()
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// Beta and Gamma share their creation time, so the id breaks the tie
+	devices := []*database.Device{
+		{Name: "Alpha", CreatedAt: base},
+		{ID: uuid.MustParse("00000000-0000-0000-0000-000000000001"), Name: "Beta", CreatedAt: base.Add(time.Second)},
+		{ID: uuid.MustParse("00000000-0000-0000-0000-000000000002"), Name: "Gamma", CreatedAt: base.Add(time.Second)},
+		{Name: "Delta", CreatedAt: base.Add(2 * time.Second)},
+		{Name: "Epsilon", CreatedAt: base.Add(3 * time.Second)},
+	}
+	// Created out of order, listing must still follow the creation time
+	for _, i := range []int{3, 0, 4, 2, 1} {
+		devices[i].Brand, devices[i].State = "BrandA", "Available"
+		if err := store.CreateDevice(ctx, devices[i]); err != nil {
+			t.Fatalf("failed to create device: %v", err)
+		}
+	}
+
+	names := func(list []database.Device) []string {
+		var result []string
+		for _, d := range list {
+			result = append(result, d.Name)
+		}
+		return result
+	}
+	assertNames := func(query db.DeviceQuery, want ...string) {
+		t.Helper()
+		list, err := store.GetDevices(ctx, query)
+		if err != nil {
+			t.Fatalf("expected nil error, got %v", err)
+		}
+		got := names(list)
+		if len(got) != len(want) {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("expected %v, got %v", want, got)
+			}
+		}
+	}
+
+	assertNames(db.DeviceQuery{Limit: 10}, "Alpha", "Beta", "Gamma", "Delta", "Epsilon")
+	assertNames(db.DeviceQuery{Limit: 2, Offset: 1}, "Beta", "Gamma")
+
+	beta := db.CursorOf(*devices[1])
+	assertNames(db.DeviceQuery{Limit: 2, After: &beta}, "Gamma", "Delta")
+	assertNames(db.DeviceQuery{Limit: 10, Before: &beta}, "Alpha")
+
+	epsilon := db.CursorOf(*devices[4])
+	assertNames(db.DeviceQuery{Limit: 2, Before: &epsilon}, "Gamma", "Delta")
+	assertNames(db.DeviceQuery{Limit: 10, After: &epsilon})
+}
+
+func TestMemory_CursorPagination(t *testing.T) {
+	testCursorPagination(t, db.NewMemory())
+}
+
+func TestSQLite_CursorPagination(t *testing.T) {
+	testCursorPagination(t, newSQLite(t))
+}
+
+// testSortedPagination walks the device list sorted on several keys, with mixed directions, on any store.
+func testSortedPagination(t *testing.T, store db.DeviceStore) {
+	ctx := t.Context()
+	for _, d := range []*database.Device{
+		{Name: "Alpha", Brand: "BrandB", State: "Available"},
+		{Name: "Beta", Brand: "BrandA", State: "Inactive"},
+		{Name: "Gamma", Brand: "BrandB", State: "In-Use"},
+		{Name: "Delta", Brand: "BrandA", State: "Available"},
+		{Name: "Epsilon", Brand: "BrandB", State: "Inactive"},
+	} {
+		if err := store.CreateDevice(ctx, d); err != nil {
+			t.Fatalf("failed to create device: %v", err)
+		}
+	}
+
+	// brand,-name
+	sort := []db.SortField{{Column: db.ColumnBrand}, {Column: db.ColumnName, Desc: true}}
+	want := []string{"Delta", "Beta", "Gamma", "Epsilon", "Alpha"}
+
+	all, err := store.GetDevices(ctx, db.DeviceQuery{Sort: sort, Limit: 10})
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	for i, d := range all {
+		if d.Name != want[i] {
+			t.Fatalf("expected %v, got device %d named %s", want, i, d.Name)
+		}
+	}
+
+	// Every page following a cursor, forwards and backwards
+	for i := range all {
+		cursor := db.CursorOf(all[i])
+		after, _ := store.GetDevices(ctx, db.DeviceQuery{Sort: sort, Limit: 10, After: &cursor})
+		if len(after) != len(all)-i-1 || len(after) > 0 && after[0].Name != want[i+1] {
+			t.Fatalf("unexpected devices after %s: %+v", want[i], after)
+		}
+		before, _ := store.GetDevices(ctx, db.DeviceQuery{Sort: sort, Limit: 2, Before: &cursor})
+		if len(before) != min(i, 2) || len(before) > 0 && before[len(before)-1].Name != want[i-1] {
+			t.Fatalf("unexpected devices before %s: %+v", want[i], before)
+		}
+	}
+
+	// state ascending: Available < In-Use < Inactive on every store
+	states, _ := store.GetDevices(ctx, db.DeviceQuery{Sort: []db.SortField{{Column: db.ColumnState}, {Column: db.ColumnName}}, Limit: 10})
+	if states[0].Name != "Alpha" || states[2].Name != "Gamma" || states[4].Name != "Epsilon" {
+		t.Fatalf("unexpected order by state %+v", states)
+	}
+
+	if _, err := store.GetDevices(ctx, db.DeviceQuery{Sort: []db.SortField{{Column: "id; DROP TABLE devices"}}, Limit: 10}); err == nil {
+		t.Fatalf("expected error on unknown sort column")
+	}
+}
+
+func TestMemory_SortedPagination(t *testing.T) {
+	testSortedPagination(t, db.NewMemory())
+}
+
+func TestSQLite_SortedPagination(t *testing.T) {
+	testSortedPagination(t, newSQLite(t))
+}
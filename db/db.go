@@ -1,17 +1,30 @@
 package db
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"slices"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/lcmps/DevicesAPI/model/database"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Supported SQL dialects, both share the same gorm based implementation
+const (
+	driverPostgres = "postgres"
+	driverSQLite   = "sqlite"
 )
 
 type DB struct {
 	Connector *gorm.DB
+	driver    string
 }
 
 func New() (*DB, error) {
@@ -21,97 +34,165 @@ func New() (*DB, error) {
 		os.Getenv("POSTGRES_PASSWORD"),
 		os.Getenv("POSTGRES_DB"))
 
-	conn, err := gorm.Open(postgres.Open(connString), &gorm.Config{})
+	// TranslateError maps driver specific errors (e.g. unique violations) to gorm's own errors
+	conn, err := gorm.Open(postgres.Open(connString), &gorm.Config{TranslateError: true})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
-	return &DB{Connector: conn}, nil
+	return &DB{Connector: conn, driver: driverPostgres}, nil
 }
 
+// Init brings the schema up to date by applying the pending migrations,
+// refusing to start if the database was migrated by a newer version of the service.
 func (db *DB) Init() error {
-	// Since i'm using UUID as ID and trigram index, I need to enable both extensions on postgres
-	// Also using DO/BEGIN to create the ENUM type as a compatibility measure in case the type already
-	// exists AND the postgres version doesn't support 'IF NOT EXISTS' on types
-	setupQueries := []string{
-		`CREATE EXTENSION IF NOT EXISTS "pgcrypto";`,
-		`CREATE EXTENSION IF NOT EXISTS pg_trgm;`,
-		`DO $$
-			BEGIN
-				IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'device_state') THEN
-				CREATE TYPE device_state AS ENUM ('Available', 'In-Use', 'Inactive');
-			END IF;
-		END$$;`,
+	if _, err := db.MigrateUp(); err != nil {
+		return err
 	}
 
-	// Creating a few indexes to optimize queries
-	// [0] Index on state to speedup filtering by state
-	// [1] Partial index on state where deleted is false to optimize filtering active devices by state
-	// [2] Trigram index on name to optimize searching by partial name matches
-	postSetupQueries := []string{
-		`CREATE INDEX IF NOT EXISTS idx_devices_state ON devices(state);`,
-		`CREATE INDEX IF NOT EXISTS idx_devices_active_state ON devices(state) WHERE deleted = FALSE;`,
-		`CREATE INDEX IF NOT EXISTS idx_devices_name_trgm ON devices USING gin (name gin_trgm_ops);`,
-	}
+	log.Println("Database Migrated")
+	return nil
+}
 
-	for _, query := range setupQueries {
-		if err := db.Connector.Exec(query).Error; err != nil {
-			return fmt.Errorf("failed to execute setup query: %w", err)
-		}
+// setDefaults fills the values that SQLite can't generate by itself,
+// since it has no gen_random_uuid() nor now(). Postgres relies on the column defaults.
+func (db *DB) setDefaults(device *database.Device) {
+	if db.driver != driverSQLite {
+		return
 	}
-
-	if err := db.Connector.AutoMigrate(&database.Device{}); err != nil {
-		return fmt.Errorf("failed to migrate device: %w", err)
+	if device.ID == uuid.Nil {
+		device.ID = uuid.New()
+	}
+	if device.CreatedAt.IsZero() {
+		device.CreatedAt = time.Now()
 	}
+	// Timestamps are stored as text, so they must share the same time zone to be compared
+	device.CreatedAt = device.CreatedAt.UTC()
+}
 
-	for _, query := range postSetupQueries {
-		if err := db.Connector.Exec(query).Error; err != nil {
-			return fmt.Errorf("failed to execute post-setup query: %w", err)
+// SeedDevices creates the given devices, skipping the ones that already exist (same name and brand),
+// and returns how many were created.
+func (db *DB) SeedDevices(ctx context.Context, devices []database.Device) (int, error) {
+	created := 0
+	for _, d := range devices {
+		err := db.CreateDevice(ctx, &d)
+		if errors.Is(err, ErrConflict) {
+			continue
+		}
+		if err != nil {
+			return created, fmt.Errorf("failed to seed device: %w", err)
 		}
+		created++
 	}
+	return created, nil
+}
 
-	dummyDevices := []database.Device{
-		{Name: "Alpha", Brand: "BrandA", State: "Available"},
-		{Name: "Beta", Brand: "BrandB", State: "Inactive"},
-		{Name: "Gamma", Brand: "BrandA", State: "In-Use"},
+func (db *DB) CreateDevice(ctx context.Context, device *database.Device) error {
+	if device == nil {
+		return fmt.Errorf("failed to create device: nil device")
 	}
-	for _, d := range dummyDevices {
-		var dev database.Device
-		cond := database.Device{Name: d.Name, Brand: d.Brand}
-		if err := db.Connector.Where(cond).FirstOrCreate(&dev, d).Error; err != nil {
-			return fmt.Errorf("failed to insert dummy device: %w", err)
+	db.setDefaults(device)
+
+	return db.transaction(ctx, "create device", func(tx *gorm.DB) error {
+		result := tx.Create(device)
+		if errors.Is(result.Error, gorm.ErrDuplicatedKey) {
+			return ErrConflict
 		}
-	}
-	log.Println("Database Migrated")
-	return nil
+		if result.Error != nil {
+			return fmt.Errorf("failed to create device: %w", result.Error)
+		}
+		return writeAudit(tx, AuditCreate, nil, device)
+	})
 }
 
-func (db *DB) CreateDevice(device *database.Device) error {
-	result := db.Connector.Create(device)
-	if result.Error != nil {
-		return fmt.Errorf("failed to create device: %w", result.Error)
+// transaction runs fn within a transaction. Errors of the transaction itself, like failing to begin it on a
+// closed connection, are wrapped as failing to do action, the same way fn wraps the errors of its queries.
+func (db *DB) transaction(ctx context.Context, action string, fn func(tx *gorm.DB) error) error {
+	var fnErr error
+	err := db.Connector.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		fnErr = fn(tx)
+		return fnErr
+	})
+	if err != nil && err != fnErr {
+		return fmt.Errorf("failed to %s: %w", action, err)
 	}
-	return nil
+	return err
 }
 
-func (db *DB) UpdateDevice(device database.Device) error {
-	// Update the device in the database
-	// UPDATE devices SET ... WHERE id = ? AND deleted = FALSE
-	result := db.Connector.Model(&database.Device{}).Where("id = ? AND deleted = FALSE", device.ID).Updates(device)
+// lockDevice fetches a live (or deleted) device within a transaction, locking its row until the transaction
+// ends so the checks made on it still hold when it is written. SQLite has no row locks, but it doesn't need
+// them either since its transactions are serialized by the single connection.
+func (db *DB) lockDevice(tx *gorm.DB, id string, deleted bool) (database.Device, error) {
+	var device database.Device
+
+	query := tx
+	if db.driver == driverPostgres {
+		query = tx.Clauses(clause.Locking{Strength: "UPDATE"})
+	}
+
+	// SELECT * FROM devices WHERE id = ? AND deleted = ? LIMIT 1 FOR UPDATE
+	result := query.Where("id = ? AND deleted = ?", id, deleted).First(&device)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return device, ErrNotFound
+	}
 	if result.Error != nil {
-		return fmt.Errorf("failed to update device: %w", result.Error)
+		return device, fmt.Errorf("failed to get device by ID: %w", result.Error)
 	}
-	if result.RowsAffected == 0 {
-		return fmt.Errorf("no device found with the given ID")
+
+	return device, nil
+}
+
+func (db *DB) UpdateDevice(ctx context.Context, device *database.Device) error {
+	if device == nil || device.ID == uuid.Nil {
+		return ErrNotFound
 	}
 
-	return nil
+	return db.transaction(ctx, "update device", func(tx *gorm.DB) error {
+		before, err := db.lockDevice(tx, device.ID.String(), false)
+		if err != nil {
+			return err
+		}
+		// Conditional write, so concurrent requests can't overwrite each other
+		if device.Version > 0 && device.Version != before.Version {
+			return ErrVersionMismatch
+		}
+
+		// Only the provided fields are changed, and the version is always bumped
+		updates := map[string]interface{}{"version": gorm.Expr("version + 1")}
+		if device.Name != "" {
+			updates["name"] = device.Name
+		}
+		if device.Brand != "" {
+			updates["brand"] = device.Brand
+		}
+		if device.State != "" {
+			updates["state"] = device.State
+		}
+
+		// UPDATE devices SET ..., version = version + 1 WHERE id = ? RETURNING *
+		result := tx.Model(device).Clauses(clause.Returning{}).Updates(updates)
+		if errors.Is(result.Error, gorm.ErrDuplicatedKey) {
+			return ErrConflict
+		}
+		if result.Error != nil {
+			return fmt.Errorf("failed to update device: %w", result.Error)
+		}
+
+		return writeAudit(tx, updateAction(before, *device), &before, device)
+	})
 }
 
-func (db *DB) GetDeviceByID(id string) (database.Device, error) {
+func (db *DB) GetDeviceByID(ctx context.Context, id string) (database.Device, error) {
 	var device database.Device
 
+	if _, err := uuid.Parse(id); err != nil {
+		return device, ErrInvalidID
+	}
+
 	// Select * FROM devices WHERE id = ? AND deleted = FALSE LIMIT 1
-	result := db.Connector.Where("id = ? AND deleted = FALSE", id).First(&device)
+	result := db.Connector.WithContext(ctx).Where("id = ? AND deleted = FALSE", id).First(&device)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return device, ErrNotFound
+	}
 	if result.Error != nil {
 		return device, fmt.Errorf("failed to get device by ID: %w", result.Error)
 	}
@@ -119,39 +200,204 @@ func (db *DB) GetDeviceByID(id string) (database.Device, error) {
 	return device, nil
 }
 
-func (db *DB) GetDevices(limit int, offset int, brand, state, name string) ([]database.Device, error) {
+// filterDevices applies the filters of the query, leaving pagination aside.
+func (db *DB) filterDevices(q *gorm.DB, query DeviceQuery) *gorm.DB {
+	switch query.Deleted {
+	case ExcludeDeleted:
+		q = q.Where("deleted = FALSE")
+	case OnlyDeleted:
+		q = q.Where("deleted = TRUE")
+	}
+	if query.Brand != "" {
+		q = q.Where("brand = ?", query.Brand)
+	}
+	if query.State != "" {
+		q = q.Where("state = ?", query.State)
+	}
+	if query.Name != "" {
+		// SQLite has no ILIKE, but its LIKE operator is already case-insensitive
+		if db.driver == driverSQLite {
+			q = q.Where("name LIKE ?", "%"+query.Name+"%")
+		} else {
+			q = q.Where("name ILIKE ?", "%"+query.Name+"%")
+		}
+	}
+	if query.Filter != nil {
+		if err := query.Filter.Validate(); err != nil {
+			_ = q.AddError(err)
+			return q
+		}
+		condition, args := query.Filter.sql(db.driver)
+		q = q.Where(condition, args...)
+	}
+	return q
+}
+
+func (db *DB) GetDevices(ctx context.Context, query DeviceQuery) ([]database.Device, error) {
 	var deviceList []database.Device
 
-	query := db.Connector.Where("deleted = FALSE")
+	q := db.filterDevices(db.Connector.WithContext(ctx), query)
 
-	if brand != "" {
-		query = query.Where("brand = ?", brand)
+	keys, err := sortKeys(query.Sort)
+	if err != nil {
+		return deviceList, err
 	}
-	if state != "" {
-		query = query.Where("state = ?", state)
+	// The default order is served by idx_devices_created_at_id
+	if query.After != nil {
+		condition, args := keysetCondition(keys, *query.After, true)
+		q = q.Where(condition, args...)
 	}
-	if name != "" {
-		query = query.Where("name ILIKE ?", "%"+name+"%")
+	if query.Before != nil {
+		// Walking backwards from the cursor, then putting the page back in order
+		condition, args := keysetCondition(keys, *query.Before, false)
+		q = q.Where(condition, args...)
 	}
 
-	result := query.Limit(limit).Offset(offset).Find(&deviceList)
+	result := q.Order(orderClause(keys, query.Before != nil)).Limit(query.Limit).Offset(query.Offset).Find(&deviceList)
 	if result.Error != nil {
 		return deviceList, fmt.Errorf("failed to get devices: %w", result.Error)
 	}
+	if query.Before != nil {
+		slices.Reverse(deviceList)
+	}
 
 	return deviceList, nil
 }
 
-func (db *DB) DeleteDevice(id string) error {
-	// Using soft delete on a device by setting the deleted flag to TRUE on the database
-	// UPDATE devices SET deleted = TRUE WHERE id = ? AND deleted = FALSE
-	result := db.Connector.Model(&database.Device{}).Where("id = ? AND deleted = FALSE", id).Update("deleted", true)
+func (db *DB) CountDevices(ctx context.Context, query DeviceQuery, mode CountMode) (int64, bool, error) {
+	if mode == CountEstimate && db.driver == driverPostgres {
+		count, ok, err := db.estimateDevices(ctx, query)
+		if err != nil || ok {
+			return count, ok, err
+		}
+	}
+
+	var count int64
+	// SELECT count(*) FROM devices WHERE ...
+	result := db.filterDevices(db.Connector.WithContext(ctx).Model(&database.Device{}), query).Count(&count)
+	if result.Error != nil {
+		return 0, false, fmt.Errorf("failed to count devices: %w", result.Error)
+	}
+	return count, false, nil
+}
+
+// estimateDevices reads the number of devices from the planner statistics instead of scanning the table.
+// Only the deleted filter can be estimated, through the partial index on deleted devices: ok is false
+// for any other filter, or when the table was never analyzed.
+func (db *DB) estimateDevices(ctx context.Context, query DeviceQuery) (count int64, ok bool, err error) {
+	if query.Brand != "" || query.State != "" || query.Name != "" || query.Filter != nil {
+		return 0, false, nil
+	}
+
+	var stats struct {
+		Total   float64
+		Deleted float64
+	}
+	// reltuples is -1 until the table is vacuumed or analyzed for the first time
+	result := db.Connector.WithContext(ctx).Raw(`SELECT
+		COALESCE((SELECT reltuples FROM pg_class WHERE oid = to_regclass('devices')), -1) AS total,
+		COALESCE((SELECT reltuples FROM pg_class WHERE oid = to_regclass('idx_devices_deleted_at')), -1) AS deleted`).Scan(&stats)
 	if result.Error != nil {
-		return fmt.Errorf("failed to delete device: %w", result.Error)
+		return 0, false, fmt.Errorf("failed to estimate devices: %w", result.Error)
 	}
-	if result.RowsAffected == 0 {
-		return fmt.Errorf("no device found with the given ID")
+	if stats.Total < 0 || stats.Deleted < 0 {
+		return 0, false, nil
 	}
 
+	switch query.Deleted {
+	case OnlyDeleted:
+		count = int64(stats.Deleted)
+	case ExcludeDeleted:
+		count = max(int64(stats.Total)-int64(stats.Deleted), 0)
+	default:
+		count = int64(stats.Total)
+	}
+	return count, true, nil
+}
+
+// checkDeletable refuses to delete devices in use.
+func checkDeletable(device database.Device) error {
+	if device.State == "In-Use" {
+		return &StateViolationError{Reason: "cannot delete device: device is currently in use"}
+	}
 	return nil
 }
+
+func (db *DB) DeleteDevice(ctx context.Context, id string, version int64) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrInvalidID
+	}
+
+	return db.transaction(ctx, "delete device", func(tx *gorm.DB) error {
+		before, err := db.lockDevice(tx, id, false)
+		if err != nil {
+			return err
+		}
+		// The row lock keeps an update from racing with this check
+		if err := checkDeletable(before); err != nil {
+			return err
+		}
+		if version > 0 && version != before.Version {
+			return ErrVersionMismatch
+		}
+
+		// Using soft delete on a device by setting the deleted flag to TRUE on the database.
+		// UPDATE devices SET deleted = TRUE, deleted_at = ?, deleted_by = ?, version = version + 1 WHERE id = ? RETURNING *
+		after := before
+		result := tx.Model(&after).Clauses(clause.Returning{}).Updates(map[string]interface{}{
+			"deleted":    true,
+			"deleted_at": time.Now().UTC(),
+			"deleted_by": auditInfoFrom(ctx).Actor,
+			"version":    gorm.Expr("version + 1"),
+		})
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete device: %w", result.Error)
+		}
+
+		return writeAudit(tx, AuditDelete, &before, &after)
+	})
+}
+
+func (db *DB) RestoreDevice(ctx context.Context, id string) (database.Device, error) {
+	var after database.Device
+	if _, err := uuid.Parse(id); err != nil {
+		return after, ErrInvalidID
+	}
+
+	err := db.transaction(ctx, "restore device", func(tx *gorm.DB) error {
+		before, err := db.lockDevice(tx, id, true)
+		if errors.Is(err, ErrNotFound) {
+			// Telling apart devices that don't exist from the ones that were never deleted
+			var count int64
+			if err := tx.Model(&database.Device{}).Where("id = ?", id).Count(&count).Error; err != nil {
+				return fmt.Errorf("failed to get device by ID: %w", err)
+			}
+			if count > 0 {
+				return &StateViolationError{Reason: "cannot restore device: device is not deleted"}
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		// The unique index on name and brand rejects the restore if a live device took them
+		// UPDATE devices SET deleted = FALSE, deleted_at = NULL, deleted_by = '', version = version + 1 WHERE id = ? RETURNING *
+		after = before
+		result := tx.Model(&after).Clauses(clause.Returning{}).Updates(map[string]interface{}{
+			"deleted":    false,
+			"deleted_at": nil,
+			"deleted_by": "",
+			"version":    gorm.Expr("version + 1"),
+		})
+		if errors.Is(result.Error, gorm.ErrDuplicatedKey) {
+			return ErrConflict
+		}
+		if result.Error != nil {
+			return fmt.Errorf("failed to restore device: %w", result.Error)
+		}
+
+		return writeAudit(tx, AuditRestore, &before, &after)
+	})
+
+	return after, err
+}
@@ -112,17 +112,17 @@ func TestCreateDevice_Integration(t *testing.T) {
 		t.Skipf("skipping: could not connect to test database: %v", err)
 	}
 
-	if err := dbInstance.Connector.AutoMigrate(&database.Device{}); err != nil {
+	if _, err := dbInstance.MigrateUp(); err != nil {
 		t.Fatalf("failed to migrate: %v", err)
 	}
 
-	device := &database.Device{Name: "TestDevice", Brand: "TestBrand", State: "Available"}
-	err = dbInstance.CreateDevice(device)
+	device := &database.Device{Name: "TestDevice", Brand: "TestBrand " + uuid.NewString(), State: "Available"}
+	err = dbInstance.CreateDevice(t.Context(), device)
 	if err != nil {
 		t.Fatalf("expected nil error, got %v", err)
 	}
 
-	err = dbInstance.CreateDevice(nil)
+	err = dbInstance.CreateDevice(t.Context(), nil)
 	if err == nil {
 		t.Fatalf("expected error when creating device with nil pointer, got nil")
 	}
@@ -140,31 +140,31 @@ func TestUpdateDevice_Integration(t *testing.T) {
 		t.Skipf("skipping: could not connect to test database: %v", err)
 	}
 
-	if err := dbInstance.Connector.AutoMigrate(&database.Device{}); err != nil {
+	if _, err := dbInstance.MigrateUp(); err != nil {
 		t.Fatalf("failed to migrate: %v", err)
 	}
 
-	device := &database.Device{Name: "UpdateMe", Brand: "BrandX", State: "Available"}
-	if err := dbInstance.CreateDevice(device); err != nil {
+	device := &database.Device{Name: "UpdateMe", Brand: "BrandX " + uuid.NewString(), State: "Available"}
+	if err := dbInstance.CreateDevice(t.Context(), device); err != nil {
 		t.Fatalf("failed to create device for update: %v", err)
 	}
 
 	device.State = "Inactive"
-	err = dbInstance.UpdateDevice(*device)
+	err = dbInstance.UpdateDevice(t.Context(), device)
 	if err != nil {
 		t.Fatalf("expected nil error on update, got %v", err)
 	}
 
 	nonExistent := *device
 	nonExistent.ID, _ = uuid.Parse("00000000-0000-0000-0000-000000000000")
-	err = dbInstance.UpdateDevice(nonExistent)
+	err = dbInstance.UpdateDevice(t.Context(), &nonExistent)
 	if err == nil || err.Error() != "no device found with the given ID" {
 		t.Fatalf("expected error for non-existent device, got %v", err)
 	}
 
 	sqlDB, _ := dbInstance.Connector.DB()
 	_ = sqlDB.Close()
-	err = dbInstance.UpdateDevice(*device)
+	err = dbInstance.UpdateDevice(t.Context(), device)
 	if err == nil || !strings.Contains(err.Error(), "failed to update device") {
 		t.Fatalf("expected error for failed update device, got %v", err)
 	}
@@ -182,16 +182,16 @@ func TestGetDeviceByID_Integration(t *testing.T) {
 		t.Skipf("skipping: could not connect to test database: %v", err)
 	}
 
-	if err := dbInstance.Connector.AutoMigrate(&database.Device{}); err != nil {
+	if _, err := dbInstance.MigrateUp(); err != nil {
 		t.Fatalf("failed to migrate: %v", err)
 	}
 
-	device := &database.Device{Name: "FetchMe", Brand: "BrandY", State: "Available"}
-	if err := dbInstance.CreateDevice(device); err != nil {
+	device := &database.Device{Name: "FetchMe", Brand: "BrandY " + uuid.NewString(), State: "Available"}
+	if err := dbInstance.CreateDevice(t.Context(), device); err != nil {
 		t.Fatalf("failed to create device for fetch: %v", err)
 	}
 
-	fetched, err := dbInstance.GetDeviceByID(device.ID.String())
+	fetched, err := dbInstance.GetDeviceByID(t.Context(), device.ID.String())
 	if err != nil {
 		t.Fatalf("expected nil error on fetch, got %v", err)
 	}
@@ -199,7 +199,7 @@ func TestGetDeviceByID_Integration(t *testing.T) {
 		t.Fatalf("expected device ID %v, got %v", device.ID, fetched.ID)
 	}
 
-	_, err = dbInstance.GetDeviceByID("00000000-0000-0000-0000-000000000000")
+	_, err = dbInstance.GetDeviceByID(t.Context(), "00000000-0000-0000-0000-000000000000")
 	if err == nil {
 		t.Fatalf("expected error for non-existent device, got nil")
 	}
@@ -216,30 +216,35 @@ func TestGetDevices_Integration(t *testing.T) {
 		t.Skipf("skipping: could not connect to test database: %v", err)
 	}
 
-	if err := dbInstance.Connector.AutoMigrate(&database.Device{}); err != nil {
+	if _, err := dbInstance.MigrateUp(); err != nil {
 		t.Fatalf("failed to migrate: %v", err)
 	}
 
-	dev1 := &database.Device{Name: "Alpha", Brand: "BrandA", State: "Available"}
-	dev2 := &database.Device{Name: "Beta", Brand: "BrandB", State: "Inactive"}
-	dev3 := &database.Device{Name: "Gamma", Brand: "BrandA", State: "Available"}
+	// Brands are unique to each run, name and brand being unique among live devices
+	brandA, brandB := "BrandA "+uuid.NewString(), "BrandB "+uuid.NewString()
+	dev1 := &database.Device{Name: "Alpha", Brand: brandA, State: "Available"}
+	dev2 := &database.Device{Name: "Beta", Brand: brandB, State: "Inactive"}
+	dev3 := &database.Device{Name: "Gamma", Brand: brandA, State: "Available"}
 	for _, d := range []*database.Device{dev1, dev2, dev3} {
-		if err := dbInstance.CreateDevice(d); err != nil {
+		if err := dbInstance.CreateDevice(t.Context(), d); err != nil {
 			t.Fatalf("failed to create device: %v", err)
 		}
 	}
 
-	brandADevices, err := dbInstance.GetDevices(10, 0, "BrandA", "", "")
+	brandADevices, err := dbInstance.GetDevices(t.Context(), db.DeviceQuery{Brand: brandA, Limit: 10})
 	if err != nil {
 		t.Fatalf("expected nil error for brand filter, got %v", err)
 	}
+	if len(brandADevices) != 2 {
+		t.Fatalf("expected 2 devices of %s, got %d", brandA, len(brandADevices))
+	}
 	for _, d := range brandADevices {
-		if d.Brand != "BrandA" {
-			t.Fatalf("expected BrandA, got %v", d.Brand)
+		if d.Brand != brandA {
+			t.Fatalf("expected %s, got %v", brandA, d.Brand)
 		}
 	}
 
-	alphaDevices, err := dbInstance.GetDevices(10, 0, "", "", "Alpha")
+	alphaDevices, err := dbInstance.GetDevices(t.Context(), db.DeviceQuery{Name: "Alpha", Limit: 10})
 	if err != nil {
 		t.Fatalf("expected nil error for name filter, got %v", err)
 	}
@@ -253,7 +258,7 @@ func TestGetDevices_Integration(t *testing.T) {
 		t.Fatalf("expected to find device with name Alpha")
 	}
 
-	_, err = dbInstance.GetDevices(10, 0, "", "INVALID_STATE_FOR_ERROR", "")
+	_, err = dbInstance.GetDevices(t.Context(), db.DeviceQuery{State: "INVALID_STATE_FOR_ERROR", Limit: 10})
 	if err == nil || !strings.Contains(err.Error(), "failed to get devices") {
 		t.Fatalf("expected error for failed get devices, got %v", err)
 	}
@@ -270,27 +275,102 @@ func TestDeleteDevice_Integration(t *testing.T) {
 		t.Skipf("skipping: could not connect to test database: %v", err)
 	}
 
-	if err := dbInstance.Connector.AutoMigrate(&database.Device{}); err != nil {
+	if _, err := dbInstance.MigrateUp(); err != nil {
 		t.Fatalf("failed to migrate: %v", err)
 	}
 
-	device := &database.Device{Name: "DeleteMe", Brand: "BrandZ", State: "Available"}
-	if err := dbInstance.CreateDevice(device); err != nil {
+	device := &database.Device{Name: "DeleteMe", Brand: "BrandZ " + uuid.NewString(), State: "Available"}
+	if err := dbInstance.CreateDevice(t.Context(), device); err != nil {
 		t.Fatalf("failed to create device for delete: %v", err)
 	}
 
-	err = dbInstance.DeleteDevice(device.ID.String())
+	err = dbInstance.DeleteDevice(t.Context(), device.ID.String(), 0)
 	if err != nil {
 		t.Fatalf("expected nil error on delete, got %v", err)
 	}
 
-	err = dbInstance.DeleteDevice("00000000-0000-0000-0000-000000000000")
+	err = dbInstance.DeleteDevice(t.Context(), "00000000-0000-0000-0000-000000000000", 0)
 	if err == nil || err.Error() != "no device found with the given ID" {
 		t.Fatalf("expected error for non-existent device, got %v", err)
 	}
 
-	err = dbInstance.DeleteDevice("not-a-uuid")
+	err = dbInstance.DeleteDevice(t.Context(), "not-a-uuid", 0)
 	if err == nil {
 		t.Fatalf("expected error for invalid UUID, got nil")
 	}
 }
+
+func TestSearchDevices_Integration(t *testing.T) {
+	_ = os.Setenv("POSTGRES_HOST", "localhost")
+	_ = os.Setenv("POSTGRES_USER", "postgres")
+	_ = os.Setenv("POSTGRES_PASSWORD", "postgres")
+	_ = os.Setenv("POSTGRES_DB", "device_api")
+
+	dbInstance, err := db.New()
+	if err != nil {
+		t.Skipf("skipping: could not connect to test database: %v", err)
+	}
+	if _, err := dbInstance.MigrateUp(); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	// A word of its own, so devices left by previous runs don't get in the way
+	word := "dev" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	device := &database.Device{Name: "Gamma " + word, Brand: "SearchBrand", State: "Available"}
+	if err := dbInstance.CreateDevice(t.Context(), device); err != nil {
+		t.Fatalf("failed to create device: %v", err)
+	}
+
+	results, err := dbInstance.SearchDevices(t.Context(), db.SearchQuery{Text: `"gamma ` + word[:10] + `"*`, Mode: db.SearchText, Limit: 10})
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if len(results) != 1 || results[0].Device.ID != device.ID || results[0].NameHighlight != "<mark>Gamma</mark> <mark>"+word+"</mark>" {
+		t.Fatalf("unexpected full-text results %+v", results)
+	}
+
+	results, err = dbInstance.SearchDevices(t.Context(), db.SearchQuery{Text: "Gama " + word, Threshold: 0.5, Limit: 10})
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if len(results) != 1 || results[0].Device.ID != device.ID || results[0].Score < 0.5 {
+		t.Fatalf("unexpected fuzzy results %+v", results)
+	}
+}
+
+func TestExportDevices_Integration(t *testing.T) {
+	_ = os.Setenv("POSTGRES_HOST", "localhost")
+	_ = os.Setenv("POSTGRES_USER", "postgres")
+	_ = os.Setenv("POSTGRES_PASSWORD", "postgres")
+	_ = os.Setenv("POSTGRES_DB", "device_api")
+
+	dbInstance, err := db.New()
+	if err != nil {
+		t.Skipf("skipping: could not connect to test database: %v", err)
+	}
+	if _, err := dbInstance.MigrateUp(); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	// A brand of its own, so devices left by previous runs don't get in the way
+	brand := "Export " + uuid.NewString()
+	for _, name := range []string{"Alpha", "Beta", "Gamma"} {
+		if err := dbInstance.CreateDevice(t.Context(), &database.Device{Name: name, Brand: brand, State: "Available"}); err != nil {
+			t.Fatalf("failed to create device: %v", err)
+		}
+	}
+
+	// Parameters of the filters must reach the cursor
+	var names []string
+	query := db.DeviceQuery{Brand: brand, Name: "a", Sort: []db.SortField{{Column: db.ColumnName, Desc: true}}}
+	err = dbInstance.ExportDevices(t.Context(), query, func(device database.Device) error {
+		names = append(names, device.Name)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if strings.Join(names, ",") != "Gamma,Beta,Alpha" {
+		t.Fatalf("expected every device sorted by name descending, got %v", names)
+	}
+}
@@ -0,0 +1,35 @@
+package db
+
+import "errors"
+
+// Errors returned by every DeviceStore implementation, so callers can tell them apart with errors.Is
+var (
+	// ErrNotFound is returned when there's no live device with the given ID.
+	ErrNotFound = errors.New("no device found with the given ID")
+	// ErrInvalidID is returned when the given ID is not a valid UUID.
+	ErrInvalidID = errors.New("invalid device ID, should be a UUID")
+	// ErrConflict is returned when a write would leave two live devices with the same name and brand.
+	ErrConflict = errors.New("a device with the same name and brand already exists")
+	// ErrVersionMismatch is returned by conditional writes when the device was modified in the meantime.
+	ErrVersionMismatch = errors.New("device was modified by another request")
+	// ErrBulkAborted is returned for the items of an atomic bulk write that were not written because others failed.
+	ErrBulkAborted = errors.New("not written, since other items of the request failed")
+	// ErrInvalidSearch is returned when a full-text search has nothing to look for.
+	ErrInvalidSearch = errors.New("search text has no word to look for")
+	// ErrStateViolation is matched by every StateViolationError.
+	ErrStateViolation = errors.New("operation not allowed on the current device state")
+)
+
+// StateViolationError is returned when an operation isn't allowed on the device's current state,
+// e.g. deleting a device that is in use. Reason explains what was refused.
+type StateViolationError struct {
+	Reason string
+}
+
+func (e *StateViolationError) Error() string {
+	return e.Reason
+}
+
+func (e *StateViolationError) Is(target error) bool {
+	return target == ErrStateViolation
+}
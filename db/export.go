@@ -0,0 +1,91 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lcmps/DevicesAPI/model/database"
+	"gorm.io/gorm"
+)
+
+// exportBatchSize is the number of devices read at once by an export.
+const exportBatchSize = 1000
+
+// ExportDevices calls fn with every device matching the query, in its order, regardless of its pagination.
+// On Postgres devices are read through a server-side cursor, within a read-only transaction, so the export
+// sees a consistent snapshot in constant memory. SQLite, having a single connection to share, reads them by
+// pages instead, following the sort keys.
+func (db *DB) ExportDevices(ctx context.Context, query DeviceQuery, fn func(device database.Device) error) error {
+	keys, err := sortKeys(query.Sort)
+	if err != nil {
+		return err
+	}
+	if db.driver == driverSQLite {
+		return db.exportPages(ctx, query, fn)
+	}
+
+	return db.Connector.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// The statement is only built, then declared as a cursor with the same parameters
+		var devices []database.Device
+		stmt := db.filterDevices(tx.Session(&gorm.Session{DryRun: true}), query).Order(orderClause(keys, false)).Find(&devices).Statement
+		if stmt.Error != nil {
+			return fmt.Errorf("failed to export devices: %w", stmt.Error)
+		}
+		// DECLARE devices_export NO SCROLL CURSOR FOR SELECT * FROM devices WHERE ... ORDER BY ...
+		if _, err := tx.Statement.ConnPool.ExecContext(ctx, "DECLARE devices_export NO SCROLL CURSOR FOR "+stmt.SQL.String(), stmt.Vars...); err != nil {
+			return fmt.Errorf("failed to export devices: %w", err)
+		}
+
+		for {
+			var batch []database.Device
+			if err := tx.Raw(fmt.Sprintf("FETCH %d FROM devices_export", exportBatchSize)).Scan(&batch).Error; err != nil {
+				return fmt.Errorf("failed to export devices: %w", err)
+			}
+			for _, device := range batch {
+				if err := fn(device); err != nil {
+					return err
+				}
+			}
+			if len(batch) < exportBatchSize {
+				return nil
+			}
+		}
+	}, &sql.TxOptions{ReadOnly: true})
+}
+
+// exportPages reads the devices to export page by page, each one starting after the last device of the previous one.
+func (db *DB) exportPages(ctx context.Context, query DeviceQuery, fn func(device database.Device) error) error {
+	query.Limit, query.Offset, query.After, query.Before = exportBatchSize, 0, nil, nil
+	for {
+		devices, err := db.GetDevices(ctx, query)
+		if err != nil {
+			return err
+		}
+		for _, device := range devices {
+			if err := fn(device); err != nil {
+				return err
+			}
+		}
+		if len(devices) < exportBatchSize {
+			return nil
+		}
+		last := CursorOf(devices[len(devices)-1])
+		query.After = &last
+	}
+}
+
+// ExportDevices lists the devices to export upfront, so fn never runs while the lock is held.
+func (m *MemoryDB) ExportDevices(ctx context.Context, query DeviceQuery, fn func(device database.Device) error) error {
+	query.Limit, query.Offset, query.After, query.Before = -1, 0, nil, nil
+	devices, err := m.GetDevices(ctx, query)
+	if err != nil {
+		return err
+	}
+	for _, device := range devices {
+		if err := fn(device); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package db_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/lcmps/DevicesAPI/db"
+	"github.com/lcmps/DevicesAPI/model/database"
+)
+
+// testExportDevices runs the same exports against any store, spanning several pages.
+func testExportDevices(t *testing.T, store db.DeviceStore) {
+	ctx := t.Context()
+
+	var devices []database.Device
+	for i := range 2500 {
+		brand := "BrandA"
+		if i%5 == 0 {
+			brand = "BrandB"
+		}
+		devices = append(devices, database.Device{Name: fmt.Sprintf("Device %04d", i), Brand: brand, State: "Available"})
+	}
+	if _, err := store.CreateDevices(ctx, devices, db.BulkAtomic); err != nil {
+		t.Fatalf("failed to create devices: %v", err)
+	}
+
+	var names []string
+	query := db.DeviceQuery{Brand: "BrandA", Sort: []db.SortField{{Column: db.ColumnName, Desc: true}}, Limit: 10, Offset: 5}
+	err := store.ExportDevices(ctx, query, func(device database.Device) error {
+		names = append(names, device.Name)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if len(names) != 2000 {
+		t.Fatalf("expected every BrandA device regardless of the pagination, got %d", len(names))
+	}
+	if names[0] != "Device 2499" || names[1999] != "Device 0001" {
+		t.Fatalf("expected devices sorted by name descending, got %s first and %s last", names[0], names[1999])
+	}
+	for i := 1; i < len(names); i++ {
+		if names[i] >= names[i-1] {
+			t.Fatalf("expected every device once and in order, got %s after %s", names[i], names[i-1])
+		}
+	}
+
+	stop := errors.New("client went away")
+	exported := 0
+	err = store.ExportDevices(ctx, db.DeviceQuery{}, func(device database.Device) error {
+		exported++
+		if exported == 3 {
+			return stop
+		}
+		return nil
+	})
+	if !errors.Is(err, stop) || exported != 3 {
+		t.Fatalf("expected the export to stop on the first error, got %v after %d devices", err, exported)
+	}
+
+	err = store.ExportDevices(ctx, db.DeviceQuery{Sort: []db.SortField{{Column: "color"}}}, func(database.Device) error { return nil })
+	if err == nil {
+		t.Fatalf("expected an error for an unknown sort column")
+	}
+}
+
+func TestMemory_ExportDevices(t *testing.T) {
+	testExportDevices(t, db.NewMemory())
+}
+
+func TestSQLite_ExportDevices(t *testing.T) {
+	testExportDevices(t, newSQLite(t))
+}
@@ -0,0 +1,176 @@
+package db
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lcmps/DevicesAPI/model/database"
+)
+
+// FilterOp is the comparison made by a filter on its column.
+type FilterOp int
+
+const (
+	// FilterEqual matches the exact values
+	FilterEqual FilterOp = iota
+	// FilterPrefix matches the values starting with any of the given ones, case-insensitive
+	FilterPrefix
+	// FilterContains matches the values containing any of the given ones, case-insensitive
+	FilterContains
+	// FilterFrom matches the times equal or after the given one, created_at only
+	FilterFrom
+	// FilterUntil matches the times before the given one, created_at only
+	FilterUntil
+)
+
+// Filter is a node of a filter expression on the device list: either a comparison on a column,
+// or a group of filters all matching (or any of them, with Or). Not negates it either way.
+type Filter struct {
+	Column string
+	Op     FilterOp
+	// Values compared by FilterEqual, FilterPrefix and FilterContains, matching any of them is enough
+	Values []string
+	// Time compared by FilterFrom and FilterUntil
+	Time time.Time
+
+	// Filters of the group, when there's no Column. An empty group matches every device.
+	Filters []Filter
+	Or      bool
+
+	Not bool
+}
+
+// Validate checks the columns and operations of the whole expression.
+func (f Filter) Validate() error {
+	if f.Column == "" {
+		for _, filter := range f.Filters {
+			if err := filter.Validate(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if !slices.Contains(columns, f.Column) {
+		return fmt.Errorf("unknown filter column %q", f.Column)
+	}
+	switch f.Op {
+	case FilterEqual, FilterPrefix, FilterContains:
+		if f.Column == ColumnCreatedAt {
+			return fmt.Errorf("filter on %s can only compare times", f.Column)
+		}
+		if len(f.Values) == 0 {
+			return fmt.Errorf("filter on %s has no value", f.Column)
+		}
+	case FilterFrom, FilterUntil:
+		if f.Column != ColumnCreatedAt {
+			return fmt.Errorf("filter on %s cannot compare times", f.Column)
+		}
+	default:
+		return fmt.Errorf("unknown filter operation %d", f.Op)
+	}
+	return nil
+}
+
+// Matches evaluates the expression against a device, the way the database does.
+func (f Filter) Matches(device database.Device) bool {
+	return f.matches(device) != f.Not
+}
+
+func (f Filter) matches(device database.Device) bool {
+	if f.Column == "" {
+		if len(f.Filters) == 0 {
+			return true
+		}
+		for _, filter := range f.Filters {
+			if filter.Matches(device) == f.Or {
+				return f.Or
+			}
+		}
+		return !f.Or
+	}
+
+	if f.Op == FilterFrom {
+		return !device.CreatedAt.Before(f.Time)
+	}
+	if f.Op == FilterUntil {
+		return device.CreatedAt.Before(f.Time)
+	}
+
+	value := CursorOf(device).value(f.Column).(string)
+	for _, v := range f.Values {
+		switch {
+		case f.Op == FilterEqual && value == v,
+			f.Op == FilterPrefix && strings.HasPrefix(strings.ToLower(value), strings.ToLower(v)),
+			f.Op == FilterContains && strings.Contains(strings.ToLower(value), strings.ToLower(v)):
+			return true
+		}
+	}
+	return false
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns, used along with ESCAPE '\'.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// sql translates the expression into a parameterised condition. Columns come from the validated
+// whitelist, values are always passed as parameters.
+func (f Filter) sql(driver string) (string, []any) {
+	condition, args := f.condition(driver)
+	if f.Not {
+		condition = "NOT " + condition
+	}
+	return condition, args
+}
+
+func (f Filter) condition(driver string) (string, []any) {
+	if f.Column == "" {
+		if len(f.Filters) == 0 {
+			return "(1 = 1)", nil
+		}
+		operator := " AND "
+		if f.Or {
+			operator = " OR "
+		}
+		conditions := make([]string, 0, len(f.Filters))
+		var args []any
+		for _, filter := range f.Filters {
+			condition, filterArgs := filter.sql(driver)
+			conditions = append(conditions, condition)
+			args = append(args, filterArgs...)
+		}
+		return "(" + strings.Join(conditions, operator) + ")", args
+	}
+
+	switch f.Op {
+	case FilterFrom:
+		return "(created_at >= ?)", []any{f.Time.UTC()}
+	case FilterUntil:
+		return "(created_at < ?)", []any{f.Time.UTC()}
+	case FilterEqual:
+		return fmt.Sprintf("(%s IN ?)", f.Column), []any{f.Values}
+	}
+
+	// SQLite has no ILIKE, but its LIKE operator is already case-insensitive
+	like := "ILIKE"
+	if driver == driverSQLite {
+		like = "LIKE"
+	}
+	// device_state is an enum on Postgres, which has no LIKE operator
+	column := f.Column
+	if column == ColumnState {
+		column = "CAST(state AS TEXT)"
+	}
+	conditions := make([]string, 0, len(f.Values))
+	args := make([]any, 0, len(f.Values))
+	for _, v := range f.Values {
+		pattern := likeEscaper.Replace(v) + "%"
+		if f.Op == FilterContains {
+			pattern = "%" + pattern
+		}
+		conditions = append(conditions, fmt.Sprintf(`%s %s ? ESCAPE '\'`, column, like))
+		args = append(args, pattern)
+	}
+	return "(" + strings.Join(conditions, " OR ") + ")", args
+}
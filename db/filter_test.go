@@ -0,0 +1,114 @@
+package db_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/lcmps/DevicesAPI/db"
+	"github.com/lcmps/DevicesAPI/model/database"
+)
+
+// testFilters runs the same filter expressions against any store.
+func testFilters(t *testing.T, store db.DeviceStore) {
+	ctx := t.Context()
+	base := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, d := range []*database.Device{
+		{Name: "Alpha", Brand: "BrandA", State: "Available", CreatedAt: base.Add(-24 * time.Hour)},
+		{Name: "Beta", Brand: "BrandA", State: "Inactive", CreatedAt: base.Add(24 * time.Hour)},
+		{Name: "Gamma", Brand: "BrandA", State: "In-Use", CreatedAt: base.Add(48 * time.Hour)},
+		{Name: "Delta", Brand: "Brand_B", State: "Available", CreatedAt: base},
+		{Name: "Epsilon 100%", Brand: "OtherBrand", State: "Inactive", CreatedAt: base.Add(72 * time.Hour)},
+	} {
+		if err := store.CreateDevice(ctx, d); err != nil {
+			t.Fatalf("failed to create device: %v", err)
+		}
+	}
+
+	september := db.Filter{Filters: []db.Filter{
+		{Column: db.ColumnCreatedAt, Op: db.FilterFrom, Time: base},
+		{Column: db.ColumnCreatedAt, Op: db.FilterUntil, Time: base.AddDate(0, 1, 0)},
+	}}
+
+	cases := []struct {
+		name   string
+		filter db.Filter
+		want   []string
+	}{
+		{"MultipleValues", db.Filter{Column: db.ColumnState, Values: []string{"Available", "In-Use"}}, []string{"Alpha", "Gamma", "Delta"}},
+		{"Negation", db.Filter{Column: db.ColumnState, Values: []string{"Inactive"}, Not: true}, []string{"Alpha", "Gamma", "Delta"}},
+		{"Prefix", db.Filter{Column: db.ColumnBrand, Op: db.FilterPrefix, Values: []string{"brand"}}, []string{"Alpha", "Beta", "Gamma", "Delta"}},
+		{"Partial", db.Filter{Column: db.ColumnBrand, Op: db.FilterContains, Values: []string{"RBRA"}}, []string{"Epsilon 100%"}},
+		{"EscapedWildcards", db.Filter{Column: db.ColumnBrand, Op: db.FilterPrefix, Values: []string{"Brand_"}}, []string{"Delta"}},
+		{"EscapedPercent", db.Filter{Column: db.ColumnName, Op: db.FilterContains, Values: []string{"0%"}}, []string{"Epsilon 100%"}},
+		{"StatePrefix", db.Filter{Column: db.ColumnState, Op: db.FilterPrefix, Values: []string{"in"}}, []string{"Beta", "Gamma", "Epsilon 100%"}},
+		{"CreatedRange", september, []string{"Beta", "Gamma", "Delta", "Epsilon 100%"}},
+		{
+			// BrandA devices created in September that aren't Inactive
+			"AndGroup",
+			db.Filter{Filters: []db.Filter{
+				{Column: db.ColumnBrand, Values: []string{"BrandA"}},
+				september,
+				{Column: db.ColumnState, Values: []string{"Inactive"}, Not: true},
+			}},
+			[]string{"Gamma"},
+		},
+		{
+			"OrGroup",
+			db.Filter{Or: true, Filters: []db.Filter{
+				{Column: db.ColumnName, Values: []string{"Alpha"}},
+				{Filters: []db.Filter{{Column: db.ColumnBrand, Values: []string{"OtherBrand"}}}, Not: true},
+			}},
+			[]string{"Alpha", "Beta", "Gamma", "Delta"},
+		},
+		{"EmptyGroup", db.Filter{}, []string{"Alpha", "Beta", "Gamma", "Delta", "Epsilon 100%"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			devices, err := store.GetDevices(ctx, db.DeviceQuery{Filter: &tc.filter, Limit: 10})
+			if err != nil {
+				t.Fatalf("expected nil error, got %v", err)
+			}
+			var got []string
+			for _, d := range devices {
+				got = append(got, d.Name)
+			}
+			if !slices.Equal(sortedNames(got), sortedNames(tc.want)) {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+			count, _, err := store.CountDevices(ctx, db.DeviceQuery{Filter: &tc.filter}, db.CountExact)
+			if err != nil || count != int64(len(tc.want)) {
+				t.Fatalf("expected a count of %d, got %d and %v", len(tc.want), count, err)
+			}
+		})
+	}
+
+	invalid := []db.Filter{
+		{Column: "id", Values: []string{"x"}},
+		{Column: db.ColumnName},
+		{Column: db.ColumnName, Op: db.FilterFrom, Time: base},
+		{Column: db.ColumnCreatedAt, Values: []string{"2025"}},
+		{Filters: []db.Filter{{Column: "deleted = TRUE OR 1", Values: []string{"1"}}}},
+	}
+	for _, filter := range invalid {
+		if _, err := store.GetDevices(ctx, db.DeviceQuery{Filter: &filter, Limit: 10}); err == nil {
+			t.Fatalf("expected error on invalid filter %+v", filter)
+		}
+	}
+}
+
+func sortedNames(names []string) []string {
+	names = slices.Clone(names)
+	slices.Sort(names)
+	return names
+}
+
+func TestMemory_Filters(t *testing.T) {
+	testFilters(t, db.NewMemory())
+}
+
+func TestSQLite_Filters(t *testing.T) {
+	testFilters(t, newSQLite(t))
+}
@@ -0,0 +1,211 @@
+package db
+
+import (
+	"html"
+	"strings"
+	"unicode"
+
+	"github.com/lcmps/DevicesAPI/model/database"
+)
+
+// Markers wrapping the matches of highlights, before they are turned into <mark> tags. Control characters
+// can't be typed in a device name, so they can't be mistaken with the text.
+const (
+	highlightStart = "\x02"
+	highlightStop  = "\x03"
+)
+
+// Weights of the fields on the full-text score, the ones ts_rank gives to the A (name) and B (brand) labels
+const (
+	nameWeight  = 1.0
+	brandWeight = 0.4
+)
+
+// textTerm is a clause of a full-text search, all of them having to match: a word, or a phrase of
+// consecutive words, the last one matched by prefix when Prefix is set. Not reverses it.
+type textTerm struct {
+	Words  []string
+	Prefix bool
+	Not    bool
+}
+
+// parseTextSearch reads a full-text search, made of words, "quoted phrases", prefixes (ending with *)
+// and negations (starting with -). Words are lowercased runs of letters and digits, like the simple
+// text search configuration of Postgres.
+func parseTextSearch(text string) ([]textTerm, error) {
+	var terms []textTerm
+	positive := false
+	runes := []rune(text)
+
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+
+		term := textTerm{}
+		if runes[i] == '-' {
+			term.Not = true
+			i++
+		}
+
+		var raw string
+		if i < len(runes) && runes[i] == '"' {
+			// Unterminated quotes run up to the end of the search
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			raw = string(runes[i+1 : end])
+			i = end + 1
+		} else {
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) {
+				end++
+			}
+			raw = string(runes[i:end])
+			i = end
+		}
+		if i < len(runes) && runes[i] == '*' {
+			i++
+			term.Prefix = true
+		}
+		if strings.HasSuffix(raw, "*") {
+			term.Prefix = true
+		}
+
+		term.Words = textWords(raw)
+		if len(term.Words) == 0 {
+			continue
+		}
+		positive = positive || !term.Not
+		terms = append(terms, term)
+	}
+
+	if !positive {
+		return nil, ErrInvalidSearch
+	}
+	return terms, nil
+}
+
+// textWords splits a text into its lowercased words.
+func textWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// tsquery writes the terms as a Postgres tsquery. Words only hold letters and digits,
+// and are quoted anyway so they're never read as operators.
+func tsquery(terms []textTerm) string {
+	clauses := make([]string, 0, len(terms))
+	for _, term := range terms {
+		words := make([]string, 0, len(term.Words))
+		for _, word := range term.Words {
+			words = append(words, "'"+strings.ReplaceAll(word, "'", "''")+"'")
+		}
+		if term.Prefix {
+			words[len(words)-1] += ":*"
+		}
+		clause := "(" + strings.Join(words, " <-> ") + ")"
+		if term.Not {
+			clause = "!" + clause
+		}
+		clauses = append(clauses, clause)
+	}
+	return strings.Join(clauses, " & ")
+}
+
+// matchWords reports whether the words match the term, as a phrase.
+func (t textTerm) matchWords(words []string) bool {
+	for start := 0; start+len(t.Words) <= len(words); start++ {
+		matched := true
+		for i, word := range t.Words {
+			candidate := words[start+i]
+			last := i == len(t.Words)-1
+			if candidate != word && !(last && t.Prefix && strings.HasPrefix(candidate, word)) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// scoreText matches the device against the terms, the way search @@ to_tsquery(...) does. The score
+// adds the weight of the best field matching each term, so it ranks like ts_rank without being equal to it.
+func scoreText(device database.Device, terms []textTerm) (float64, bool) {
+	name, brand := textWords(device.Name), textWords(device.Brand)
+	score := 0.0
+	positive := 0
+	for _, term := range terms {
+		weight := 0.0
+		if term.matchWords(name) {
+			weight = nameWeight
+		} else if term.matchWords(brand) {
+			weight = brandWeight
+		}
+
+		if term.Not {
+			if weight > 0 {
+				return 0, false
+			}
+			continue
+		}
+		if weight == 0 {
+			return 0, false
+		}
+		score += weight
+		positive++
+	}
+	return score / float64(positive), true
+}
+
+// highlightText wraps the words of the text matching one of the terms with the highlight markers,
+// like ts_headline with HighlightAll.
+func highlightText(text string, terms []textTerm) string {
+	var b strings.Builder
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		if !unicode.IsLetter(runes[i]) && !unicode.IsDigit(runes[i]) {
+			b.WriteRune(runes[i])
+			i++
+			continue
+		}
+
+		end := i
+		for end < len(runes) && (unicode.IsLetter(runes[end]) || unicode.IsDigit(runes[end])) {
+			end++
+		}
+		word := string(runes[i:end])
+		if highlighted(strings.ToLower(word), terms) {
+			word = highlightStart + word + highlightStop
+		}
+		b.WriteString(word)
+		i = end
+	}
+	return b.String()
+}
+
+func highlighted(word string, terms []textTerm) bool {
+	for _, term := range terms {
+		if term.Not {
+			continue
+		}
+		for i, w := range term.Words {
+			if word == w || i == len(term.Words)-1 && term.Prefix && strings.HasPrefix(word, w) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// markHighlight escapes a highlighted text for HTML, turning its markers into <mark> tags.
+func markHighlight(text string) string {
+	text = html.EscapeString(text)
+	return strings.NewReplacer(highlightStart, "<mark>", highlightStop, "</mark>").Replace(text)
+}
@@ -0,0 +1,423 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lcmps/DevicesAPI/model/database"
+)
+
+// MemoryDB is a DeviceStore kept entirely in memory.
+// It mimics the Postgres implementation (soft delete, filters and default values)
+// so the API can run without a database, e.g. locally or on CI.
+type MemoryDB struct {
+	mu      sync.RWMutex
+	devices map[uuid.UUID]database.Device
+	// audit holds the audit trail, oldest first
+	audit []database.AuditRecord
+	// archive holds the purged devices, when archiving was asked for
+	archive []database.ArchivedDevice
+}
+
+var _ DeviceStore = (*MemoryDB)(nil)
+
+func NewMemory() *MemoryDB {
+	return &MemoryDB{devices: map[uuid.UUID]database.Device{}}
+}
+
+func (m *MemoryDB) CreateDevice(ctx context.Context, device *database.Device) error {
+	if device == nil {
+		return fmt.Errorf("failed to create device: nil device")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.create(ctx, device)
+}
+
+// create stores a new device, so the lock must be held.
+func (m *MemoryDB) create(ctx context.Context, device *database.Device) error {
+	// Same defaults applied by the database schema
+	if device.ID == uuid.Nil {
+		device.ID = uuid.New()
+	}
+	if device.CreatedAt.IsZero() {
+		device.CreatedAt = time.Now()
+	}
+	if device.State == "" {
+		device.State = "Available"
+	}
+	device.Version = 1
+	if _, exists := m.devices[device.ID]; exists {
+		return fmt.Errorf("failed to create device: duplicated id %s", device.ID)
+	}
+	if m.conflicts(device.Name, device.Brand, device.ID) {
+		return ErrConflict
+	}
+	if err := m.writeAudit(ctx, AuditCreate, nil, device); err != nil {
+		return err
+	}
+
+	m.devices[device.ID] = *device
+	return nil
+}
+
+// SeedDevices creates the given devices, skipping the ones that already exist (same name and brand),
+// and returns how many were created.
+func (m *MemoryDB) SeedDevices(ctx context.Context, devices []database.Device) (int, error) {
+	created := 0
+	for _, d := range devices {
+		err := m.CreateDevice(ctx, &d)
+		if errors.Is(err, ErrConflict) {
+			continue
+		}
+		if err != nil {
+			return created, fmt.Errorf("failed to seed device: %w", err)
+		}
+		created++
+	}
+	return created, nil
+}
+
+// conflicts reports whether a live device, other than the one with the given id,
+// has exactly the same name and brand. Emulates the partial unique index, so the lock must be held.
+func (m *MemoryDB) conflicts(name, brand string, id uuid.UUID) bool {
+	for _, device := range m.devices {
+		if !device.Deleted && device.ID != id && device.Name == name && device.Brand == brand {
+			return true
+		}
+	}
+	return false
+}
+
+// writeAudit appends a change to the audit trail, so the lock must be held.
+func (m *MemoryDB) writeAudit(ctx context.Context, action string, before, after *database.Device) error {
+	record, err := newAuditRecord(ctx, action, before, after)
+	if err != nil {
+		return err
+	}
+	record.ID = int64(len(m.audit) + 1)
+	m.audit = append(m.audit, record)
+	return nil
+}
+
+func (m *MemoryDB) UpdateDevice(ctx context.Context, device *database.Device) error {
+	if device == nil {
+		return ErrNotFound
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	before, ok := m.devices[device.ID]
+	if !ok || before.Deleted {
+		return ErrNotFound
+	}
+	if device.Version > 0 && device.Version != before.Version {
+		return ErrVersionMismatch
+	}
+
+	// Following gorm's Updates behaviour, only non-zero fields are changed
+	current := before
+	if device.Name != "" {
+		current.Name = device.Name
+	}
+	if device.Brand != "" {
+		current.Brand = device.Brand
+	}
+	if device.State != "" {
+		current.State = device.State
+	}
+	if m.conflicts(current.Name, current.Brand, current.ID) {
+		return ErrConflict
+	}
+	current.Version++
+	if err := m.writeAudit(ctx, updateAction(before, current), &before, &current); err != nil {
+		return err
+	}
+	m.devices[device.ID] = current
+	*device = current
+
+	return nil
+}
+
+func (m *MemoryDB) GetDeviceByID(ctx context.Context, id string) (database.Device, error) {
+	guid, err := uuid.Parse(id)
+	if err != nil {
+		return database.Device{}, ErrInvalidID
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	device, ok := m.devices[guid]
+	if !ok || device.Deleted {
+		return database.Device{}, ErrNotFound
+	}
+
+	return device, nil
+}
+
+// matches reports whether the device passes the filters of the query, leaving pagination aside.
+func matches(device database.Device, query DeviceQuery) bool {
+	if device.Deleted && query.Deleted == ExcludeDeleted || !device.Deleted && query.Deleted == OnlyDeleted {
+		return false
+	}
+	if query.Brand != "" && device.Brand != query.Brand {
+		return false
+	}
+	if query.State != "" && device.State != query.State {
+		return false
+	}
+	// Emulating ILIKE '%name%'
+	if query.Name != "" && !strings.Contains(strings.ToLower(device.Name), strings.ToLower(query.Name)) {
+		return false
+	}
+	return query.Filter == nil || query.Filter.Matches(device)
+}
+
+func (m *MemoryDB) GetDevices(ctx context.Context, query DeviceQuery) ([]database.Device, error) {
+	keys, err := sortKeys(query.Sort)
+	if err != nil {
+		return nil, err
+	}
+	if query.Filter != nil {
+		if err := query.Filter.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.list(query, keys), nil
+}
+
+// list returns the devices matching the query in the order of keys, so the lock must be held.
+func (m *MemoryDB) list(query DeviceQuery, keys []SortField) []database.Device {
+	deviceList := []database.Device{}
+
+	for _, device := range m.devices {
+		if !matches(device, query) {
+			continue
+		}
+		if query.After != nil && CursorOf(device).Compare(*query.After, keys) <= 0 {
+			continue
+		}
+		if query.Before != nil && CursorOf(device).Compare(*query.Before, keys) >= 0 {
+			continue
+		}
+		deviceList = append(deviceList, device)
+	}
+
+	// Same as the ORDER BY of the keys, or its reverse when walking backwards from a cursor
+	slices.SortFunc(deviceList, func(a, b database.Device) int {
+		return CursorOf(a).Compare(CursorOf(b), keys)
+	})
+	if query.Before != nil {
+		slices.Reverse(deviceList)
+	}
+
+	deviceList = deviceList[min(max(query.Offset, 0), len(deviceList)):]
+	if query.Limit >= 0 && len(deviceList) > query.Limit {
+		deviceList = deviceList[:query.Limit]
+	}
+	if query.Before != nil {
+		slices.Reverse(deviceList)
+	}
+
+	return deviceList
+}
+
+// CountDevices always counts exactly, there's nothing to estimate from in memory.
+func (m *MemoryDB) CountDevices(ctx context.Context, query DeviceQuery, mode CountMode) (int64, bool, error) {
+	if query.Filter != nil {
+		if err := query.Filter.Validate(); err != nil {
+			return 0, false, err
+		}
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var count int64
+	for _, device := range m.devices {
+		if matches(device, query) {
+			count++
+		}
+	}
+	return count, false, nil
+}
+
+func (m *MemoryDB) DeleteDevice(ctx context.Context, id string, version int64) error {
+	guid, err := uuid.Parse(id)
+	if err != nil {
+		return ErrInvalidID
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	device, ok := m.devices[guid]
+	if !ok || device.Deleted {
+		return ErrNotFound
+	}
+	if err := checkDeletable(device); err != nil {
+		return err
+	}
+	if version > 0 && version != device.Version {
+		return ErrVersionMismatch
+	}
+
+	before := device
+	deletedAt := time.Now().UTC()
+	device.Deleted = true
+	device.DeletedAt = &deletedAt
+	device.DeletedBy = auditInfoFrom(ctx).Actor
+	device.Version++
+	if err := m.writeAudit(ctx, AuditDelete, &before, &device); err != nil {
+		return err
+	}
+	m.devices[guid] = device
+
+	return nil
+}
+
+func (m *MemoryDB) RestoreDevice(ctx context.Context, id string) (database.Device, error) {
+	guid, err := uuid.Parse(id)
+	if err != nil {
+		return database.Device{}, ErrInvalidID
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	device, ok := m.devices[guid]
+	if !ok {
+		return database.Device{}, ErrNotFound
+	}
+	if !device.Deleted {
+		return database.Device{}, &StateViolationError{Reason: "cannot restore device: device is not deleted"}
+	}
+	if m.conflicts(device.Name, device.Brand, device.ID) {
+		return database.Device{}, ErrConflict
+	}
+
+	before := device
+	device.Deleted = false
+	device.DeletedAt = nil
+	device.DeletedBy = ""
+	device.Version++
+	if err := m.writeAudit(ctx, AuditRestore, &before, &device); err != nil {
+		return database.Device{}, err
+	}
+	m.devices[guid] = device
+
+	return device, nil
+}
+
+func (m *MemoryDB) SearchDevices(ctx context.Context, query SearchQuery) ([]SearchResult, error) {
+	var terms []textTerm
+	if query.Mode == SearchText {
+		var err error
+		if terms, err = parseTextSearch(query.Text); err != nil {
+			return []SearchResult{}, err
+		}
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	devices := make([]database.Device, 0, len(m.devices))
+	for _, device := range m.devices {
+		if !device.Deleted {
+			devices = append(devices, device)
+		}
+	}
+	return rankDevices(devices, query, terms), nil
+}
+
+func (m *MemoryDB) GetAuditRecords(ctx context.Context, query AuditQuery) ([]database.AuditRecord, error) {
+	records := []database.AuditRecord{}
+
+	var deviceID uuid.UUID
+	if query.DeviceID != "" {
+		guid, err := uuid.Parse(query.DeviceID)
+		if err != nil {
+			return records, ErrInvalidID
+		}
+		deviceID = guid
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	skipped := 0
+	// Newest first, same as ORDER BY id DESC
+	for i := len(m.audit) - 1; i >= 0; i-- {
+		if query.Limit >= 0 && len(records) >= query.Limit {
+			break
+		}
+
+		record := m.audit[i]
+		if deviceID != uuid.Nil && record.DeviceID != deviceID {
+			continue
+		}
+		if query.Action != "" && record.Action != query.Action {
+			continue
+		}
+		if !query.From.IsZero() && record.CreatedAt.Before(query.From) {
+			continue
+		}
+		if !query.To.IsZero() && !record.CreatedAt.Before(query.To) {
+			continue
+		}
+
+		if skipped < query.Offset {
+			skipped++
+			continue
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+func (m *MemoryDB) PurgeDeleted(ctx context.Context, before time.Time, limit int, archive bool) ([]database.Device, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var devices []database.Device
+	for _, device := range m.devices {
+		if device.Deleted && device.DeletedAt != nil && device.DeletedAt.Before(before) {
+			devices = append(devices, device)
+		}
+	}
+	// Oldest deletions first, same as ORDER BY deleted_at
+	slices.SortFunc(devices, func(a, b database.Device) int {
+		if n := a.DeletedAt.Compare(*b.DeletedAt); n != 0 {
+			return n
+		}
+		return CursorOf(a).compare(CursorOf(b), "id")
+	})
+	if len(devices) > limit {
+		devices = devices[:limit]
+	}
+
+	archivedAt := time.Now().UTC()
+	for _, device := range devices {
+		if archive {
+			m.archive = append(m.archive, archivedDevice(device, archivedAt))
+		}
+		delete(m.devices, device.ID)
+	}
+
+	return devices, nil
+}
@@ -0,0 +1,210 @@
+package db_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/lcmps/DevicesAPI/db"
+	"github.com/lcmps/DevicesAPI/model/database"
+)
+
+func newSeededMemory(t *testing.T) *db.MemoryDB {
+	t.Helper()
+	mem := db.NewMemory()
+	for _, d := range []*database.Device{
+		{Name: "Alpha", Brand: "BrandA", State: "Available"},
+		{Name: "Beta", Brand: "BrandB", State: "Inactive"},
+		{Name: "Gamma", Brand: "BrandA", State: "In-Use"},
+		{Name: "AlphaX", Brand: "BrandB", State: "Available"},
+	} {
+		if err := mem.CreateDevice(t.Context(), d); err != nil {
+			t.Fatalf("failed to create device: %v", err)
+		}
+	}
+	return mem
+}
+
+func TestMemory_CreateDevice(t *testing.T) {
+	mem := db.NewMemory()
+
+	device := &database.Device{Name: "TestDevice", Brand: "TestBrand"}
+	if err := mem.CreateDevice(t.Context(), device); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if device.ID == uuid.Nil {
+		t.Fatal("expected ID to be generated")
+	}
+	if device.CreatedAt.IsZero() {
+		t.Fatal("expected CreatedAt to be set")
+	}
+	if device.State != "Available" {
+		t.Fatalf("expected default state Available, got %v", device.State)
+	}
+
+	if err := mem.CreateDevice(t.Context(), nil); err == nil {
+		t.Fatal("expected error when creating device with nil pointer, got nil")
+	}
+}
+
+func TestMemory_GetDeviceByID(t *testing.T) {
+	mem := db.NewMemory()
+	device := &database.Device{Name: "FetchMe", Brand: "BrandY", State: "Available"}
+	if err := mem.CreateDevice(t.Context(), device); err != nil {
+		t.Fatalf("failed to create device: %v", err)
+	}
+
+	fetched, err := mem.GetDeviceByID(t.Context(), device.ID.String())
+	if err != nil {
+		t.Fatalf("expected nil error on fetch, got %v", err)
+	}
+	if fetched.ID != device.ID {
+		t.Fatalf("expected device ID %v, got %v", device.ID, fetched.ID)
+	}
+
+	if _, err := mem.GetDeviceByID(t.Context(), "00000000-0000-0000-0000-000000000000"); !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for non-existent device, got %v", err)
+	}
+	if _, err := mem.GetDeviceByID(t.Context(), "not-a-uuid"); !errors.Is(err, db.ErrInvalidID) {
+		t.Fatalf("expected ErrInvalidID for invalid UUID, got %v", err)
+	}
+}
+
+func TestMemory_GetDevices(t *testing.T) {
+	mem := newSeededMemory(t)
+
+	cases := []struct {
+		name               string
+		limit, offset      int
+		brand, state, text string
+		want               []string
+	}{
+		{"All", 10, 0, "", "", "", []string{"Alpha", "Beta", "Gamma", "AlphaX"}},
+		{"Brand", 10, 0, "BrandA", "", "", []string{"Alpha", "Gamma"}},
+		{"State", 10, 0, "", "Available", "", []string{"Alpha", "AlphaX"}},
+		{"PartialNameCaseInsensitive", 10, 0, "", "", "alp", []string{"Alpha", "AlphaX"}},
+		{"Combined", 10, 0, "BrandB", "Available", "alpha", []string{"AlphaX"}},
+		{"Limit", 2, 0, "", "", "", []string{"Alpha", "Beta"}},
+		{"Offset", 10, 3, "", "", "", []string{"AlphaX"}},
+		{"OffsetPastEnd", 10, 10, "", "", "", []string{}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			devices, err := mem.GetDevices(t.Context(), db.DeviceQuery{Brand: tc.brand, State: tc.state, Name: tc.text, Limit: tc.limit, Offset: tc.offset})
+			if err != nil {
+				t.Fatalf("expected nil error, got %v", err)
+			}
+			if len(devices) != len(tc.want) {
+				t.Fatalf("expected %d devices, got %d", len(tc.want), len(devices))
+			}
+			for i, d := range devices {
+				if d.Name != tc.want[i] {
+					t.Fatalf("expected device %d to be %v, got %v", i, tc.want[i], d.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestMemory_UpdateDevice(t *testing.T) {
+	mem := db.NewMemory()
+	device := &database.Device{Name: "UpdateMe", Brand: "BrandX", State: "Available"}
+	if err := mem.CreateDevice(t.Context(), device); err != nil {
+		t.Fatalf("failed to create device: %v", err)
+	}
+
+	if err := mem.UpdateDevice(t.Context(), &database.Device{ID: device.ID, State: "Inactive"}); err != nil {
+		t.Fatalf("expected nil error on update, got %v", err)
+	}
+	updated, _ := mem.GetDeviceByID(t.Context(), device.ID.String())
+	if updated.State != "Inactive" || updated.Name != "UpdateMe" {
+		t.Fatalf("unexpected device after update: %+v", updated)
+	}
+
+	nonExistent := database.Device{ID: uuid.MustParse("00000000-0000-0000-0000-000000000000"), State: "Inactive"}
+	if err := mem.UpdateDevice(t.Context(), &nonExistent); err == nil || err.Error() != "no device found with the given ID" {
+		t.Fatalf("expected error for non-existent device, got %v", err)
+	}
+}
+
+func TestMemory_DeleteDevice(t *testing.T) {
+	mem := newSeededMemory(t)
+	devices, _ := mem.GetDevices(t.Context(), db.DeviceQuery{Name: "Beta", Limit: 1})
+	beta := devices[0]
+
+	if err := mem.DeleteDevice(t.Context(), beta.ID.String(), 0); err != nil {
+		t.Fatalf("expected nil error on delete, got %v", err)
+	}
+	if _, err := mem.GetDeviceByID(t.Context(), beta.ID.String()); err == nil {
+		t.Fatal("expected deleted device to be hidden")
+	}
+	if devices, _ := mem.GetDevices(t.Context(), db.DeviceQuery{Limit: 10}); len(devices) != 3 {
+		t.Fatalf("expected 3 remaining devices, got %d", len(devices))
+	}
+	if err := mem.UpdateDevice(t.Context(), &database.Device{ID: beta.ID, State: "Available"}); err == nil {
+		t.Fatal("expected error when updating a deleted device")
+	}
+
+	if err := mem.DeleteDevice(t.Context(), beta.ID.String(), 0); !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for already deleted device, got %v", err)
+	}
+	if err := mem.DeleteDevice(t.Context(), "not-a-uuid", 0); !errors.Is(err, db.ErrInvalidID) {
+		t.Fatalf("expected ErrInvalidID for invalid UUID, got %v", err)
+	}
+
+	devices, _ = mem.GetDevices(t.Context(), db.DeviceQuery{State: "In-Use", Limit: 1})
+	if err := mem.DeleteDevice(t.Context(), devices[0].ID.String(), 0); !errors.Is(err, db.ErrStateViolation) {
+		t.Fatalf("expected ErrStateViolation when deleting an in use device, got %v", err)
+	}
+}
+
+func TestMemory_UniqueNameBrand(t *testing.T) {
+	mem := newSeededMemory(t)
+
+	if err := mem.CreateDevice(t.Context(), &database.Device{Name: "Alpha", Brand: "BrandA"}); !errors.Is(err, db.ErrConflict) {
+		t.Fatalf("expected ErrConflict for duplicated name and brand, got %v", err)
+	}
+	if err := mem.CreateDevice(t.Context(), &database.Device{Name: "Alpha", Brand: "BrandC"}); err != nil {
+		t.Fatalf("expected same name on another brand to be allowed, got %v", err)
+	}
+
+	devices, _ := mem.GetDevices(t.Context(), db.DeviceQuery{Brand: "BrandA", Name: "Gamma", Limit: 1})
+	gamma := devices[0]
+	if err := mem.UpdateDevice(t.Context(), &database.Device{ID: gamma.ID, Name: "Alpha"}); !errors.Is(err, db.ErrConflict) {
+		t.Fatalf("expected ErrConflict when renaming to an existing device, got %v", err)
+	}
+	if fetched, _ := mem.GetDeviceByID(t.Context(), gamma.ID.String()); fetched.Name != "Gamma" {
+		t.Fatalf("expected failed update to leave the device untouched, got %v", fetched.Name)
+	}
+}
+
+func TestMemory_Version(t *testing.T) {
+	mem := db.NewMemory()
+	device := &database.Device{Name: "Versioned", Brand: "BrandX", State: "Available"}
+	if err := mem.CreateDevice(t.Context(), device); err != nil {
+		t.Fatalf("failed to create device: %v", err)
+	}
+	if device.Version != 1 {
+		t.Fatalf("expected version 1 on create, got %d", device.Version)
+	}
+
+	update := &database.Device{ID: device.ID, State: "Inactive", Version: 1}
+	if err := mem.UpdateDevice(t.Context(), update); err != nil {
+		t.Fatalf("expected nil error on update, got %v", err)
+	}
+	if update.Version != 2 || update.Name != "Versioned" {
+		t.Fatalf("expected the updated device to be returned, got %+v", update)
+	}
+
+	stale := &database.Device{ID: device.ID, State: "Available", Version: 1}
+	if err := mem.UpdateDevice(t.Context(), stale); !errors.Is(err, db.ErrVersionMismatch) {
+		t.Fatalf("expected ErrVersionMismatch for a stale update, got %v", err)
+	}
+	if err := mem.DeleteDevice(t.Context(), device.ID.String(), 1); !errors.Is(err, db.ErrVersionMismatch) {
+		t.Fatalf("expected ErrVersionMismatch for a stale delete, got %v", err)
+	}
+	if err := mem.DeleteDevice(t.Context(), device.ID.String(), 2); err != nil {
+		t.Fatalf("expected nil error on delete, got %v", err)
+	}
+}
@@ -0,0 +1,532 @@
+package db
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// migration is a single numbered and reversible schema change.
+// Up and Down hold the queries for each supported driver, executed in order inside a transaction.
+// Check, when set, runs first on the way up, refusing to apply the migration to data it can't handle.
+type migration struct {
+	Version int
+	Name    string
+	Up      map[string][]string
+	Down    map[string][]string
+	Check   func(tx *gorm.DB) error
+}
+
+// MigrationStatus reports whether a known migration was applied to the database.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+}
+
+// schemaMigration is the row stored on the schema_migrations table for every applied migration.
+type schemaMigration struct {
+	Version   int
+	Name      string
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Arbitrary key used to serialize migrations between replicas starting at the same time
+const migrationLockKey = 727274
+
+// migrations must always be appended with the next version number, never edited once released.
+var migrations = []migration{
+	{
+		Version: 1,
+		Name:    "create_devices",
+		Up: map[string][]string{
+			// Since i'm using UUID as ID and trigram index, I need to enable both extensions on postgres
+			// Also using DO/BEGIN to create the ENUM type as a compatibility measure in case the type already
+			// exists AND the postgres version doesn't support 'IF NOT EXISTS' on types.
+			// Everything uses IF NOT EXISTS so databases created before migrations existed are adopted as is.
+			// Indexes:
+			// - idx_devices_state speeds up filtering by state
+			// - idx_devices_active_state is a partial index to optimize filtering active devices by state
+			// - idx_devices_name_trgm is a trigram index to optimize searching by partial name matches
+			driverPostgres: {
+				`CREATE EXTENSION IF NOT EXISTS "pgcrypto";`,
+				`CREATE EXTENSION IF NOT EXISTS pg_trgm;`,
+				`DO $$
+					BEGIN
+						IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'device_state') THEN
+						CREATE TYPE device_state AS ENUM ('Available', 'In-Use', 'Inactive');
+					END IF;
+				END$$;`,
+				`CREATE TABLE IF NOT EXISTS devices (
+					id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+					deleted boolean NOT NULL DEFAULT false,
+					name varchar(250) NOT NULL,
+					brand varchar(250) NOT NULL,
+					created_at timestamptz NOT NULL DEFAULT now(),
+					state device_state NOT NULL DEFAULT 'Available'
+				);`,
+				`CREATE INDEX IF NOT EXISTS idx_devices_state ON devices(state);`,
+				`CREATE INDEX IF NOT EXISTS idx_devices_active_state ON devices(state) WHERE deleted = FALSE;`,
+				`CREATE INDEX IF NOT EXISTS idx_devices_name_trgm ON devices USING gin (name gin_trgm_ops);`,
+			},
+			// SQLite has neither ENUM types nor the pgcrypto/pg_trgm extensions, so:
+			// - the id is a TEXT column and the UUID is generated on CreateDevice
+			// - the 3 valid states are enforced through a CHECK constraint
+			// - partial name search relies on LIKE, which is case-insensitive on SQLite
+			driverSQLite: {
+				`CREATE TABLE IF NOT EXISTS devices (
+					id TEXT PRIMARY KEY NOT NULL,
+					deleted BOOLEAN NOT NULL DEFAULT FALSE,
+					name VARCHAR(250) NOT NULL,
+					brand VARCHAR(250) NOT NULL,
+					created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+					state TEXT NOT NULL DEFAULT 'Available' CHECK (state IN ('Available', 'In-Use', 'Inactive'))
+				);`,
+				`CREATE INDEX IF NOT EXISTS idx_devices_state ON devices(state);`,
+				`CREATE INDEX IF NOT EXISTS idx_devices_active_state ON devices(state) WHERE deleted = FALSE;`,
+				`CREATE INDEX IF NOT EXISTS idx_devices_name ON devices(name COLLATE NOCASE);`,
+			},
+		},
+		Down: map[string][]string{
+			driverPostgres: {
+				`DROP TABLE IF EXISTS devices;`,
+				`DROP TYPE IF EXISTS device_state;`,
+			},
+			driverSQLite: {
+				`DROP TABLE IF EXISTS devices;`,
+			},
+		},
+	},
+	{
+		// Name and brand must be unique among live devices, deleted ones can be reused.
+		// Duplicates created before it existed must be resolved by hand first, see checkUniqueNameBrand.
+		Version: 2,
+		Name:    "unique_device_name_brand",
+		Check:   checkUniqueNameBrand,
+		Up: map[string][]string{
+			driverPostgres: {
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_devices_name_brand_unique ON devices(name, brand) WHERE deleted = FALSE;`,
+			},
+			driverSQLite: {
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_devices_name_brand_unique ON devices(name, brand) WHERE deleted = FALSE;`,
+			},
+		},
+		Down: map[string][]string{
+			driverPostgres: {`DROP INDEX IF EXISTS idx_devices_name_brand_unique;`},
+			driverSQLite:   {`DROP INDEX IF EXISTS idx_devices_name_brand_unique;`},
+		},
+	},
+	{
+		// Version used for optimistic concurrency control, bumped on every write
+		Version: 3,
+		Name:    "add_device_version",
+		Up: map[string][]string{
+			driverPostgres: {`ALTER TABLE devices ADD COLUMN IF NOT EXISTS version bigint NOT NULL DEFAULT 1;`},
+			driverSQLite:   {`ALTER TABLE devices ADD COLUMN version INTEGER NOT NULL DEFAULT 1;`},
+		},
+		Down: map[string][]string{
+			driverPostgres: {`ALTER TABLE devices DROP COLUMN IF EXISTS version;`},
+			driverSQLite:   {`ALTER TABLE devices DROP COLUMN version;`},
+		},
+	},
+	{
+		// Immutable audit trail of every change made to devices. There's no foreign key,
+		// so the history outlives the devices it describes.
+		Version: 4,
+		Name:    "create_device_audit",
+		Up: map[string][]string{
+			driverPostgres: {
+				`CREATE TABLE IF NOT EXISTS device_audit (
+					id bigserial PRIMARY KEY,
+					device_id uuid NOT NULL,
+					action varchar(20) NOT NULL,
+					actor varchar(250) NOT NULL,
+					request_id varchar(250) NOT NULL DEFAULT '',
+					before jsonb,
+					after jsonb NOT NULL,
+					created_at timestamptz NOT NULL DEFAULT now()
+				);`,
+				`CREATE INDEX IF NOT EXISTS idx_device_audit_device ON device_audit(device_id, id);`,
+				`CREATE INDEX IF NOT EXISTS idx_device_audit_created_at ON device_audit(created_at);`,
+				`CREATE OR REPLACE FUNCTION device_audit_immutable() RETURNS trigger AS $$
+					BEGIN
+						RAISE EXCEPTION 'device_audit records are immutable';
+					END;
+				$$ LANGUAGE plpgsql;`,
+				`DROP TRIGGER IF EXISTS device_audit_immutable ON device_audit;`,
+				`CREATE TRIGGER device_audit_immutable BEFORE UPDATE OR DELETE ON device_audit
+					FOR EACH ROW EXECUTE FUNCTION device_audit_immutable();`,
+			},
+			driverSQLite: {
+				`CREATE TABLE IF NOT EXISTS device_audit (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					device_id TEXT NOT NULL,
+					action VARCHAR(20) NOT NULL,
+					actor VARCHAR(250) NOT NULL,
+					request_id VARCHAR(250) NOT NULL DEFAULT '',
+					before TEXT,
+					after TEXT NOT NULL,
+					created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+				);`,
+				`CREATE INDEX IF NOT EXISTS idx_device_audit_device ON device_audit(device_id, id);`,
+				`CREATE INDEX IF NOT EXISTS idx_device_audit_created_at ON device_audit(created_at);`,
+				`CREATE TRIGGER IF NOT EXISTS device_audit_no_update BEFORE UPDATE ON device_audit
+					BEGIN SELECT RAISE(ABORT, 'device_audit records are immutable'); END;`,
+				`CREATE TRIGGER IF NOT EXISTS device_audit_no_delete BEFORE DELETE ON device_audit
+					BEGIN SELECT RAISE(ABORT, 'device_audit records are immutable'); END;`,
+			},
+		},
+		Down: map[string][]string{
+			driverPostgres: {
+				`DROP TABLE IF EXISTS device_audit;`,
+				`DROP FUNCTION IF EXISTS device_audit_immutable();`,
+			},
+			driverSQLite: {`DROP TABLE IF EXISTS device_audit;`},
+		},
+	},
+	{
+		// When and by whom a device was deleted. Devices deleted before this migration have no record of it,
+		// so they are considered deleted now, which only delays their purge.
+		Version: 5,
+		Name:    "add_device_deleted_at",
+		Up: map[string][]string{
+			driverPostgres: {
+				`ALTER TABLE devices ADD COLUMN IF NOT EXISTS deleted_at timestamptz;`,
+				`ALTER TABLE devices ADD COLUMN IF NOT EXISTS deleted_by varchar(250) NOT NULL DEFAULT '';`,
+				`UPDATE devices SET deleted_at = now() WHERE deleted = TRUE AND deleted_at IS NULL;`,
+				`CREATE INDEX IF NOT EXISTS idx_devices_deleted_at ON devices(deleted_at) WHERE deleted = TRUE;`,
+			},
+			driverSQLite: {
+				`ALTER TABLE devices ADD COLUMN deleted_at DATETIME;`,
+				`ALTER TABLE devices ADD COLUMN deleted_by VARCHAR(250) NOT NULL DEFAULT '';`,
+				`UPDATE devices SET deleted_at = CURRENT_TIMESTAMP WHERE deleted = TRUE AND deleted_at IS NULL;`,
+				`CREATE INDEX IF NOT EXISTS idx_devices_deleted_at ON devices(deleted_at) WHERE deleted = TRUE;`,
+			},
+		},
+		Down: map[string][]string{
+			driverPostgres: {
+				`DROP INDEX IF EXISTS idx_devices_deleted_at;`,
+				`ALTER TABLE devices DROP COLUMN IF EXISTS deleted_by;`,
+				`ALTER TABLE devices DROP COLUMN IF EXISTS deleted_at;`,
+			},
+			driverSQLite: {
+				`DROP INDEX IF EXISTS idx_devices_deleted_at;`,
+				`ALTER TABLE devices DROP COLUMN deleted_by;`,
+				`ALTER TABLE devices DROP COLUMN deleted_at;`,
+			},
+		},
+	},
+	{
+		// Purged devices can be kept here instead of being dropped for good
+		Version: 6,
+		Name:    "create_devices_archive",
+		Up: map[string][]string{
+			driverPostgres: {
+				`CREATE TABLE IF NOT EXISTS devices_archive (
+					id uuid PRIMARY KEY,
+					name varchar(250) NOT NULL,
+					brand varchar(250) NOT NULL,
+					created_at timestamptz NOT NULL,
+					state device_state NOT NULL,
+					version bigint NOT NULL,
+					deleted_at timestamptz,
+					deleted_by varchar(250) NOT NULL DEFAULT '',
+					archived_at timestamptz NOT NULL DEFAULT now()
+				);`,
+			},
+			driverSQLite: {
+				`CREATE TABLE IF NOT EXISTS devices_archive (
+					id TEXT PRIMARY KEY NOT NULL,
+					name VARCHAR(250) NOT NULL,
+					brand VARCHAR(250) NOT NULL,
+					created_at DATETIME NOT NULL,
+					state TEXT NOT NULL,
+					version INTEGER NOT NULL,
+					deleted_at DATETIME,
+					deleted_by VARCHAR(250) NOT NULL DEFAULT '',
+					archived_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+				);`,
+			},
+		},
+		Down: map[string][]string{
+			driverPostgres: {`DROP TABLE IF EXISTS devices_archive;`},
+			driverSQLite:   {`DROP TABLE IF EXISTS devices_archive;`},
+		},
+	},
+	{
+		// Keyset pagination walks the device list ordered by (created_at, id)
+		Version: 7,
+		Name:    "index_devices_created_at_id",
+		Up: map[string][]string{
+			driverPostgres: {
+				`CREATE INDEX IF NOT EXISTS idx_devices_created_at_id ON devices(created_at, id);`,
+			},
+			driverSQLite: {
+				`CREATE INDEX IF NOT EXISTS idx_devices_created_at_id ON devices(created_at, id);`,
+			},
+		},
+		Down: map[string][]string{
+			driverPostgres: {
+				`DROP INDEX IF EXISTS idx_devices_created_at_id;`,
+			},
+			driverSQLite: {
+				`DROP INDEX IF EXISTS idx_devices_created_at_id;`,
+			},
+		},
+	},
+	{
+		// Fuzzy search compares brands too, SQLite scores devices in Go instead
+		Version: 8,
+		Name:    "index_devices_brand_trgm",
+		Up: map[string][]string{
+			driverPostgres: {
+				`CREATE INDEX IF NOT EXISTS idx_devices_brand_trgm ON devices USING gin (brand gin_trgm_ops);`,
+			},
+		},
+		Down: map[string][]string{
+			driverPostgres: {
+				`DROP INDEX IF EXISTS idx_devices_brand_trgm;`,
+			},
+		},
+	},
+	{
+		// Full-text search document of every device, names weighing more than brands
+		Version: 9,
+		Name:    "add_device_search_vector",
+		Up: map[string][]string{
+			driverPostgres: {
+				`ALTER TABLE devices ADD COLUMN IF NOT EXISTS search tsvector GENERATED ALWAYS AS (
+					setweight(to_tsvector('simple', name), 'A') || setweight(to_tsvector('simple', brand), 'B')
+				) STORED;`,
+				`CREATE INDEX IF NOT EXISTS idx_devices_search ON devices USING gin (search);`,
+			},
+		},
+		Down: map[string][]string{
+			driverPostgres: {
+				`DROP INDEX IF EXISTS idx_devices_search;`,
+				`ALTER TABLE devices DROP COLUMN IF EXISTS search;`,
+			},
+		},
+	},
+}
+
+// LatestSchemaVersion is the newest schema version known by this binary.
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+func (db *DB) ensureMigrationsTable() error {
+	timestampType := "timestamptz"
+	if db.driver == driverSQLite {
+		timestampType = "DATETIME"
+	}
+
+	query := `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name VARCHAR(250) NOT NULL,
+		applied_at ` + timestampType + ` NOT NULL
+	);`
+	if err := db.Connector.Exec(query).Error; err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+	return nil
+}
+
+// SchemaVersion returns the highest migration version applied to the database, 0 when none was.
+func (db *DB) SchemaVersion() (int, error) {
+	if err := db.ensureMigrationsTable(); err != nil {
+		return 0, err
+	}
+
+	var version int
+	result := db.Connector.Model(&schemaMigration{}).Select("COALESCE(MAX(version), 0)").Scan(&version)
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to get schema version: %w", result.Error)
+	}
+
+	return version, nil
+}
+
+// checkSchemaVersion refuses to work with a database migrated by a newer binary,
+// since this one has no idea what changed on the schema.
+func (db *DB) checkSchemaVersion() error {
+	version, err := db.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	if version > LatestSchemaVersion() {
+		return fmt.Errorf("database schema version %d is newer than the latest version known by this binary (%d)", version, LatestSchemaVersion())
+	}
+	return nil
+}
+
+// MigrateUp applies every pending migration in order and returns how many were applied.
+func (db *DB) MigrateUp() (int, error) {
+	if err := db.checkSchemaVersion(); err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	for _, m := range migrations {
+		ran, err := db.runMigration(m, true)
+		if err != nil {
+			return applied, err
+		}
+		if ran {
+			log.Printf("Applied migration %d_%s", m.Version, m.Name)
+			applied++
+		}
+	}
+
+	return applied, nil
+}
+
+// MigrateDown reverts the last `steps` applied migrations and returns how many were reverted.
+func (db *DB) MigrateDown(steps int) (int, error) {
+	if err := db.checkSchemaVersion(); err != nil {
+		return 0, err
+	}
+
+	reverted := 0
+	for i := len(migrations) - 1; i >= 0 && reverted < steps; i-- {
+		ran, err := db.runMigration(migrations[i], false)
+		if err != nil {
+			return reverted, err
+		}
+		if ran {
+			log.Printf("Reverted migration %d_%s", migrations[i].Version, migrations[i].Name)
+			reverted++
+		}
+	}
+
+	return reverted, nil
+}
+
+// runMigration executes one direction of a migration and records it on schema_migrations,
+// everything within the same transaction. It returns false when there was nothing to do.
+func (db *DB) runMigration(m migration, up bool) (bool, error) {
+	queries := m.Down[db.driver]
+	if up {
+		queries = m.Up[db.driver]
+	}
+
+	ran := false
+	err := db.Connector.Transaction(func(tx *gorm.DB) error {
+		// Holding a lock until the end of the transaction, so replicas starting together
+		// don't try to apply the same migration twice
+		if db.driver == driverPostgres {
+			if err := tx.Exec(`SELECT pg_advisory_xact_lock(?)`, migrationLockKey).Error; err != nil {
+				return fmt.Errorf("failed to acquire migration lock: %w", err)
+			}
+		}
+
+		var count int64
+		if err := tx.Model(&schemaMigration{}).Where("version = ?", m.Version).Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to check migration %d: %w", m.Version, err)
+		}
+		if (count > 0) == up {
+			return nil
+		}
+
+		if up && m.Check != nil {
+			if err := m.Check(tx); err != nil {
+				return fmt.Errorf("cannot apply migration %d_%s: %w", m.Version, m.Name, err)
+			}
+		}
+		for _, query := range queries {
+			if err := tx.Exec(query).Error; err != nil {
+				return fmt.Errorf("failed to execute migration %d_%s: %w", m.Version, m.Name, err)
+			}
+		}
+
+		if up {
+			record := schemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now().UTC()}
+			if err := tx.Create(&record).Error; err != nil {
+				return fmt.Errorf("failed to record migration %d: %w", m.Version, err)
+			}
+		} else {
+			if err := tx.Delete(&schemaMigration{}, "version = ?", m.Version).Error; err != nil {
+				return fmt.Errorf("failed to remove migration %d: %w", m.Version, err)
+			}
+		}
+
+		ran = true
+		return nil
+	})
+
+	return ran, err
+}
+
+// maxListedDuplicates is the number of duplicate devices listed when the unique name and brand index can't be created.
+const maxListedDuplicates = 50
+
+// checkUniqueNameBrand refuses to create the unique name and brand index while live devices share them, which
+// concurrent creations could do before the index existed. It lists them, as there's no telling which one to keep.
+func checkUniqueNameBrand(tx *gorm.DB) error {
+	var duplicates []struct {
+		ID    string
+		Name  string
+		Brand string
+	}
+	err := tx.Raw(`SELECT CAST(d.id AS TEXT) AS id, d.name, d.brand FROM devices d
+		JOIN (SELECT name, brand FROM devices WHERE deleted = FALSE GROUP BY name, brand HAVING COUNT(*) > 1) dup
+			ON d.name = dup.name AND d.brand = dup.brand
+		WHERE d.deleted = FALSE
+		ORDER BY d.name, d.brand, d.created_at, d.id
+		LIMIT ?`, maxListedDuplicates+1).Scan(&duplicates).Error
+	if err != nil {
+		return fmt.Errorf("failed to look for duplicate devices: %w", err)
+	}
+	if len(duplicates) == 0 {
+		return nil
+	}
+
+	var list strings.Builder
+	for i, d := range duplicates {
+		if i == maxListedDuplicates {
+			list.WriteString("\n  ... and more")
+			break
+		}
+		fmt.Fprintf(&list, "\n  - %s (name %q, brand %q)", d.ID, d.Name, d.Brand)
+	}
+	return fmt.Errorf("live devices share the same name and brand, oldest first:%s\n"+
+		"Rename them, or mark all but one of each name and brand as deleted "+
+		"(UPDATE devices SET deleted = TRUE WHERE id IN (...)), then start the service again", list.String())
+}
+
+// MigrationStatus lists every migration known by this binary and whether it was applied.
+func (db *DB) MigrationStatus() ([]MigrationStatus, error) {
+	if err := db.ensureMigrationsTable(); err != nil {
+		return nil, err
+	}
+
+	var records []schemaMigration
+	if err := db.Connector.Order("version").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to get applied migrations: %w", err)
+	}
+
+	appliedAt := map[int]time.Time{}
+	for _, r := range records {
+		appliedAt[r.Version] = r.AppliedAt
+	}
+
+	status := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		s := MigrationStatus{Version: m.Version, Name: m.Name}
+		if at, ok := appliedAt[m.Version]; ok {
+			s.Applied = true
+			s.AppliedAt = &at
+		}
+		status = append(status, s)
+	}
+
+	return status, nil
+}
@@ -0,0 +1,124 @@
+package db_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lcmps/DevicesAPI/db"
+)
+
+func TestMigrations_SQLite(t *testing.T) {
+	dbInstance, err := db.NewSQLite(filepath.Join(t.TempDir(), "migrations.db"))
+	if err != nil {
+		t.Fatalf("failed to open sqlite database: %v", err)
+	}
+
+	version, err := dbInstance.SchemaVersion()
+	if err != nil || version != 0 {
+		t.Fatalf("expected version 0 on an empty database, got %d (%v)", version, err)
+	}
+
+	applied, err := dbInstance.MigrateUp()
+	if err != nil {
+		t.Fatalf("expected nil error from MigrateUp, got %v", err)
+	}
+	if applied != db.LatestSchemaVersion() {
+		t.Fatalf("expected %d migrations applied, got %d", db.LatestSchemaVersion(), applied)
+	}
+
+	applied, err = dbInstance.MigrateUp()
+	if err != nil || applied != 0 {
+		t.Fatalf("expected second MigrateUp to be a no-op, got %d (%v)", applied, err)
+	}
+
+	status, err := dbInstance.MigrationStatus()
+	if err != nil {
+		t.Fatalf("expected nil error from MigrationStatus, got %v", err)
+	}
+	for _, s := range status {
+		if !s.Applied || s.AppliedAt == nil {
+			t.Fatalf("expected migration %d to be applied", s.Version)
+		}
+	}
+
+	reverted, err := dbInstance.MigrateDown(db.LatestSchemaVersion())
+	if err != nil || reverted != db.LatestSchemaVersion() {
+		t.Fatalf("expected every migration to be reverted, got %d (%v)", reverted, err)
+	}
+	if dbInstance.Connector.Migrator().HasTable("devices") {
+		t.Fatal("expected devices table to be dropped")
+	}
+	if version, _ := dbInstance.SchemaVersion(); version != 0 {
+		t.Fatalf("expected version 0 after reverting everything, got %d", version)
+	}
+
+	if _, err := dbInstance.MigrateUp(); err != nil {
+		t.Fatalf("expected nil error when migrating up again, got %v", err)
+	}
+	if !dbInstance.Connector.Migrator().HasTable("devices") {
+		t.Fatal("expected devices table to exist")
+	}
+}
+
+func TestMigrations_DuplicateDevices(t *testing.T) {
+	dbInstance := newSQLite(t)
+	if _, err := dbInstance.MigrateDown(db.LatestSchemaVersion() - 1); err != nil {
+		t.Fatalf("failed to migrate down to the first version: %v", err)
+	}
+
+	// Left by concurrent creations before name and brand were unique
+	for _, row := range [][]any{
+		{"3fa85f64-5717-4562-b3fc-2c963f66afa6", "Alpha", "BrandA", false},
+		{"4fa85f64-5717-4562-b3fc-2c963f66afa7", "Alpha", "BrandA", false},
+		{"5fa85f64-5717-4562-b3fc-2c963f66afa8", "Alpha", "BrandA", true},
+		{"6fa85f64-5717-4562-b3fc-2c963f66afa9", "Alpha", "BrandB", false},
+	} {
+		if err := dbInstance.Connector.Exec(`INSERT INTO devices (id, name, brand, deleted) VALUES (?, ?, ?, ?)`, row...).Error; err != nil {
+			t.Fatalf("failed to insert device: %v", err)
+		}
+	}
+
+	_, err := dbInstance.MigrateUp()
+	if err == nil {
+		t.Fatal("expected MigrateUp to refuse duplicate devices, got nil")
+	}
+	for _, want := range []string{"2_unique_device_name_brand", "3fa85f64-5717-4562-b3fc-2c963f66afa6", "4fa85f64-5717-4562-b3fc-2c963f66afa7", "UPDATE devices"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected the error to mention %s, got %v", want, err)
+		}
+	}
+	// Deleted devices and other brands don't conflict
+	for _, unwanted := range []string{"5fa85f64-5717-4562-b3fc-2c963f66afa8", "6fa85f64-5717-4562-b3fc-2c963f66afa9"} {
+		if strings.Contains(err.Error(), unwanted) {
+			t.Fatalf("expected the error not to mention %s, got %v", unwanted, err)
+		}
+	}
+	if version, _ := dbInstance.SchemaVersion(); version != 1 {
+		t.Fatalf("expected the schema to stay on version 1, got %d", version)
+	}
+
+	if err := dbInstance.Connector.Exec(`UPDATE devices SET deleted = TRUE WHERE id = ?`, "4fa85f64-5717-4562-b3fc-2c963f66afa7").Error; err != nil {
+		t.Fatalf("failed to delete device: %v", err)
+	}
+	if _, err := dbInstance.MigrateUp(); err != nil {
+		t.Fatalf("expected MigrateUp to succeed once duplicates are resolved, got %v", err)
+	}
+}
+
+func TestMigrations_RefuseNewerSchema(t *testing.T) {
+	dbInstance := newSQLite(t)
+
+	err := dbInstance.Connector.Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, 'from_the_future', CURRENT_TIMESTAMP)`,
+		db.LatestSchemaVersion()+1).Error
+	if err != nil {
+		t.Fatalf("failed to insert future migration: %v", err)
+	}
+
+	if err := dbInstance.Init(); err == nil || !strings.Contains(err.Error(), "newer than the latest version") {
+		t.Fatalf("expected Init to refuse a newer schema, got %v", err)
+	}
+	if _, err := dbInstance.MigrateDown(1); err == nil {
+		t.Fatal("expected MigrateDown to refuse a newer schema, got nil")
+	}
+}
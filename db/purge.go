@@ -0,0 +1,154 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lcmps/DevicesAPI/model/database"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Purger is implemented by the stores able to permanently remove soft deleted devices.
+type Purger interface {
+	// PurgeDeleted permanently removes up to limit devices deleted before the given time, oldest deletions
+	// first, and returns them. With archive set they are moved to the archive instead of being dropped.
+	PurgeDeleted(ctx context.Context, before time.Time, limit int, archive bool) ([]database.Device, error)
+}
+
+var (
+	_ Purger = (*DB)(nil)
+	_ Purger = (*MemoryDB)(nil)
+)
+
+// PurgeConfig is the retention policy applied to soft deleted devices.
+type PurgeConfig struct {
+	// Retention is how long deleted devices are kept before being purged
+	Retention time.Duration
+	// Interval between two purges
+	Interval time.Duration
+	// BatchSize is the number of devices purged per transaction
+	BatchSize int
+	// Archive moves purged devices to devices_archive instead of dropping them
+	Archive bool
+}
+
+// PurgeReport tells what a purge did.
+type PurgeReport struct {
+	// Before is the deletion time devices had to be older than to be purged
+	Before   time.Time
+	Purged   []uuid.UUID
+	Archived bool
+}
+
+func archivedDevice(device database.Device, archivedAt time.Time) database.ArchivedDevice {
+	return database.ArchivedDevice{
+		ID:         device.ID,
+		Name:       device.Name,
+		Brand:      device.Brand,
+		CreatedAt:  device.CreatedAt,
+		State:      device.State,
+		Version:    device.Version,
+		DeletedAt:  device.DeletedAt,
+		DeletedBy:  device.DeletedBy,
+		ArchivedAt: archivedAt,
+	}
+}
+
+func (db *DB) PurgeDeleted(ctx context.Context, before time.Time, limit int, archive bool) ([]database.Device, error) {
+	var devices []database.Device
+
+	err := db.Connector.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Rows being purged by another replica are skipped instead of waited for
+		query := tx
+		if db.driver == driverPostgres {
+			query = tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+		}
+
+		// SELECT * FROM devices WHERE deleted = TRUE AND deleted_at < ? ORDER BY deleted_at LIMIT ? FOR UPDATE SKIP LOCKED
+		result := query.Where("deleted = TRUE AND deleted_at < ?", before.UTC()).Order("deleted_at").Limit(limit).Find(&devices)
+		if result.Error != nil {
+			return fmt.Errorf("failed to get deleted devices: %w", result.Error)
+		}
+		if len(devices) == 0 {
+			return nil
+		}
+
+		if archive {
+			archivedAt := time.Now().UTC()
+			archived := make([]database.ArchivedDevice, 0, len(devices))
+			for _, d := range devices {
+				archived = append(archived, archivedDevice(d, archivedAt))
+			}
+			if err := tx.Create(&archived).Error; err != nil {
+				return fmt.Errorf("failed to archive devices: %w", err)
+			}
+		}
+
+		ids := make([]uuid.UUID, 0, len(devices))
+		for _, d := range devices {
+			ids = append(ids, d.ID)
+		}
+		// DELETE FROM devices WHERE id IN (...)
+		if err := tx.Where("id IN ?", ids).Delete(&database.Device{}).Error; err != nil {
+			return fmt.Errorf("failed to purge devices: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return devices, nil
+}
+
+// Purge permanently removes every device deleted longer than the retention window ago,
+// one batch per transaction, and reports what it removed.
+func Purge(ctx context.Context, store Purger, config PurgeConfig) (PurgeReport, error) {
+	if config.BatchSize <= 0 {
+		return PurgeReport{}, errors.New("purge batch size must be positive")
+	}
+
+	report := PurgeReport{Before: time.Now().Add(-config.Retention), Archived: config.Archive}
+	for {
+		devices, err := store.PurgeDeleted(ctx, report.Before, config.BatchSize, config.Archive)
+		if err != nil {
+			return report, err
+		}
+		for _, d := range devices {
+			report.Purged = append(report.Purged, d.ID)
+		}
+		if len(devices) < config.BatchSize {
+			return report, nil
+		}
+	}
+}
+
+// RunPurgeWorker purges deleted devices right away and then on every interval, until ctx is done.
+func RunPurgeWorker(ctx context.Context, store Purger, config PurgeConfig) {
+	ticker := time.NewTicker(config.Interval)
+	defer ticker.Stop()
+
+	for {
+		report, err := Purge(ctx, store, config)
+		if err != nil {
+			log.Printf("Purge failed after removing %d device(s): %v", len(report.Purged), err)
+		} else if len(report.Purged) > 0 {
+			verb := "Purged"
+			if report.Archived {
+				verb = "Archived"
+			}
+			log.Printf("%s %d device(s) deleted before %s: %v", verb, len(report.Purged), report.Before.Format(time.RFC3339), report.Purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
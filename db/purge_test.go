@@ -0,0 +1,108 @@
+package db_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lcmps/DevicesAPI/db"
+	"github.com/lcmps/DevicesAPI/model/database"
+)
+
+type purgeStore interface {
+	db.DeviceStore
+	db.Purger
+}
+
+// testPurge runs the same purges against any store.
+func testPurge(t *testing.T, store purgeStore, archive bool) {
+	ctx := t.Context()
+
+	var devices []*database.Device
+	for _, name := range []string{"Alpha", "Beta", "Gamma", "Delta"} {
+		d := &database.Device{Name: name, Brand: "BrandA", State: "Available"}
+		if err := store.CreateDevice(ctx, d); err != nil {
+			t.Fatalf("failed to create device: %v", err)
+		}
+		devices = append(devices, d)
+	}
+	// Delta is kept alive
+	for _, d := range devices[:3] {
+		if err := store.DeleteDevice(ctx, d.ID.String(), 0); err != nil {
+			t.Fatalf("failed to delete device: %v", err)
+		}
+	}
+
+	report, err := db.Purge(ctx, store, db.PurgeConfig{Retention: time.Hour, BatchSize: 2, Archive: archive})
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if len(report.Purged) != 0 {
+		t.Fatalf("expected devices within retention to be kept, got %v", report.Purged)
+	}
+
+	if _, err := db.Purge(ctx, store, db.PurgeConfig{BatchSize: 0}); err == nil {
+		t.Fatalf("expected error on a non positive batch size")
+	}
+
+	report, err = db.Purge(ctx, store, db.PurgeConfig{BatchSize: 2, Archive: archive})
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if len(report.Purged) != 3 || report.Archived != archive {
+		t.Fatalf("expected the 3 deleted devices to be purged over 2 batches, got %+v", report)
+	}
+	for i, id := range report.Purged {
+		if id != devices[i].ID {
+			t.Fatalf("expected oldest deletions to be purged first, got %v", report.Purged)
+		}
+	}
+
+	all, _ := store.GetDevices(ctx, db.DeviceQuery{Deleted: db.IncludeDeleted, Limit: 10})
+	if len(all) != 1 || all[0].ID != devices[3].ID {
+		t.Fatalf("expected only the live device to be left, got %+v", all)
+	}
+	if _, err := store.RestoreDevice(ctx, devices[0].ID.String()); !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("expected purged device to be gone, got %v", err)
+	}
+
+	// The audit trail outlives the devices
+	records, _ := store.GetAuditRecords(ctx, db.AuditQuery{DeviceID: devices[0].ID.String(), Limit: 10})
+	if len(records) != 2 {
+		t.Fatalf("expected the purged device history to be kept, got %d records", len(records))
+	}
+
+	purged, err := store.PurgeDeleted(ctx, time.Now(), 10, archive)
+	if err != nil || len(purged) != 0 {
+		t.Fatalf("expected nothing left to purge, got %d devices and %v", len(purged), err)
+	}
+}
+
+func TestMemory_Purge(t *testing.T) {
+	testPurge(t, db.NewMemory(), true)
+}
+
+func TestSQLite_Purge(t *testing.T) {
+	dbInstance := newSQLite(t)
+	testPurge(t, dbInstance, false)
+
+	var archived int64
+	dbInstance.Connector.Model(&database.ArchivedDevice{}).Count(&archived)
+	if archived != 0 {
+		t.Fatalf("expected nothing to be archived, got %d", archived)
+	}
+}
+
+func TestSQLite_PurgeArchive(t *testing.T) {
+	dbInstance := newSQLite(t)
+	testPurge(t, dbInstance, true)
+
+	var archived []database.ArchivedDevice
+	dbInstance.Connector.Order("deleted_at").Find(&archived)
+	if len(archived) != 3 {
+		t.Fatalf("expected 3 archived devices, got %d", len(archived))
+	}
+	if archived[0].Name != "Alpha" || archived[0].DeletedAt == nil || archived[0].ArchivedAt.IsZero() || archived[0].Version != 2 {
+		t.Fatalf("unexpected archived device %+v", archived[0])
+	}
+}
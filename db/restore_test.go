@@ -0,0 +1,85 @@
+package db_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lcmps/DevicesAPI/db"
+	"github.com/lcmps/DevicesAPI/model/database"
+)
+
+// testRestoreDevice runs the same deletions and restores against any store.
+func testRestoreDevice(t *testing.T, store db.DeviceStore) {
+	ctx := db.WithAuditInfo(t.Context(), db.AuditInfo{Actor: "jane"})
+
+	alpha := &database.Device{Name: "Alpha", Brand: "BrandA", State: "Available"}
+	beta := &database.Device{Name: "Beta", Brand: "BrandA", State: "Inactive"}
+	for _, d := range []*database.Device{alpha, beta} {
+		if err := store.CreateDevice(ctx, d); err != nil {
+			t.Fatalf("failed to create device: %v", err)
+		}
+	}
+
+	if _, err := store.RestoreDevice(ctx, alpha.ID.String()); !errors.Is(err, db.ErrStateViolation) {
+		t.Fatalf("expected ErrStateViolation when restoring a live device, got %v", err)
+	}
+	if err := store.DeleteDevice(ctx, alpha.ID.String(), 0); err != nil {
+		t.Fatalf("failed to delete device: %v", err)
+	}
+
+	deleted, _ := store.GetDevices(ctx, db.DeviceQuery{Deleted: db.OnlyDeleted, Limit: 10})
+	if len(deleted) != 1 || deleted[0].ID != alpha.ID {
+		t.Fatalf("expected only the deleted device, got %+v", deleted)
+	}
+	if deleted[0].DeletedAt == nil || deleted[0].DeletedBy != "jane" {
+		t.Fatalf("expected deletion time and actor to be recorded, got %+v", deleted[0])
+	}
+	if all, _ := store.GetDevices(ctx, db.DeviceQuery{Deleted: db.IncludeDeleted, Limit: 10}); len(all) != 2 {
+		t.Fatalf("expected 2 devices including deleted ones, got %d", len(all))
+	}
+	if live, _ := store.GetDevices(ctx, db.DeviceQuery{Limit: 10}); len(live) != 1 {
+		t.Fatalf("expected 1 live device, got %d", len(live))
+	}
+
+	restored, err := store.RestoreDevice(ctx, alpha.ID.String())
+	if err != nil {
+		t.Fatalf("expected nil error on restore, got %v", err)
+	}
+	if restored.Deleted || restored.DeletedAt != nil || restored.DeletedBy != "" || restored.Version != 3 {
+		t.Fatalf("unexpected restored device %+v", restored)
+	}
+	if _, err := store.GetDeviceByID(ctx, alpha.ID.String()); err != nil {
+		t.Fatalf("expected restored device to be visible, got %v", err)
+	}
+
+	// A live device took the name and brand of the deleted one
+	if err := store.DeleteDevice(ctx, beta.ID.String(), 0); err != nil {
+		t.Fatalf("failed to delete device: %v", err)
+	}
+	if err := store.CreateDevice(ctx, &database.Device{Name: "Beta", Brand: "BrandA", State: "Available"}); err != nil {
+		t.Fatalf("failed to create device: %v", err)
+	}
+	if _, err := store.RestoreDevice(ctx, beta.ID.String()); !errors.Is(err, db.ErrConflict) {
+		t.Fatalf("expected ErrConflict when restoring a taken name and brand, got %v", err)
+	}
+
+	if _, err := store.RestoreDevice(ctx, "00000000-0000-0000-0000-000000000000"); !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := store.RestoreDevice(ctx, "not-a-uuid"); !errors.Is(err, db.ErrInvalidID) {
+		t.Fatalf("expected ErrInvalidID, got %v", err)
+	}
+
+	records, _ := store.GetAuditRecords(ctx, db.AuditQuery{DeviceID: alpha.ID.String(), Action: db.AuditRestore, Limit: 10})
+	if len(records) != 1 {
+		t.Fatalf("expected the restore to be audited, got %d records", len(records))
+	}
+}
+
+func TestMemory_RestoreDevice(t *testing.T) {
+	testRestoreDevice(t, db.NewMemory())
+}
+
+func TestSQLite_RestoreDevice(t *testing.T) {
+	testRestoreDevice(t, newSQLite(t))
+}
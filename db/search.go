@@ -0,0 +1,199 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/lcmps/DevicesAPI/model/database"
+	"gorm.io/gorm"
+)
+
+// DefaultSearchThreshold is the default similarity a device must reach to be found, same as pg_trgm.
+const DefaultSearchThreshold = 0.3
+
+// SearchMode tells how a search compares devices with its text.
+type SearchMode int
+
+const (
+	// SearchFuzzy compares device names and brands with the text through their trigram similarity,
+	// the way pg_trgm does, finding them despite typos
+	SearchFuzzy SearchMode = iota
+	// SearchText is a full-text search on the words of device names and brands (see parseTextSearch)
+	SearchText
+)
+
+// SearchQuery looks for live devices whose name or brand match Text.
+type SearchQuery struct {
+	Text string
+	Mode SearchMode
+	// Threshold is the similarity, from 0 to 1, a device must reach on its name or brand to be found by a fuzzy search
+	Threshold float64
+	Limit     int
+	Offset    int
+}
+
+// SearchResult is a device found by a search, along with its score, higher being better.
+// Full-text searches also return the name and brand of the device with the matched words
+// highlighted, HTML escaped and wrapped in <mark> tags.
+type SearchResult struct {
+	Device         database.Device `gorm:"embedded"`
+	Score          float64
+	NameHighlight  string
+	BrandHighlight string
+}
+
+func (db *DB) SearchDevices(ctx context.Context, query SearchQuery) ([]SearchResult, error) {
+	results := []SearchResult{}
+
+	var terms []textTerm
+	if query.Mode == SearchText {
+		var err error
+		if terms, err = parseTextSearch(query.Text); err != nil {
+			return results, err
+		}
+	}
+
+	// SQLite has neither pg_trgm nor text search, devices are scored here instead
+	if db.driver == driverSQLite {
+		var devices []database.Device
+		if err := db.Connector.WithContext(ctx).Where("deleted = FALSE").Find(&devices).Error; err != nil {
+			return results, fmt.Errorf("failed to search devices: %w", err)
+		}
+		return rankDevices(devices, query, terms), nil
+	}
+
+	var err error
+	if query.Mode == SearchText {
+		err = db.searchText(ctx, query, terms, &results)
+	} else {
+		err = db.searchFuzzy(ctx, query, &results)
+	}
+	if err != nil {
+		return results, fmt.Errorf("failed to search devices: %w", err)
+	}
+	for i := range results {
+		results[i].NameHighlight = markHighlight(results[i].NameHighlight)
+		results[i].BrandHighlight = markHighlight(results[i].BrandHighlight)
+	}
+
+	return results, nil
+}
+
+func (db *DB) searchFuzzy(ctx context.Context, query SearchQuery, results *[]SearchResult) error {
+	return db.Connector.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// The % operator, served by the trigram indexes, compares against this setting, only for this transaction
+		threshold := strconv.FormatFloat(query.Threshold, 'f', -1, 64)
+		if err := tx.Exec(`SELECT set_config('pg_trgm.similarity_threshold', ?, true)`, threshold).Error; err != nil {
+			return err
+		}
+
+		// SELECT devices.*, GREATEST(similarity(name, ?), similarity(brand, ?)) AS score FROM devices
+		// WHERE deleted = FALSE AND (name % ? OR brand % ?) ORDER BY score DESC, created_at, id LIMIT ? OFFSET ?
+		return tx.Model(&database.Device{}).
+			Select("devices.*, GREATEST(similarity(name, ?), similarity(brand, ?)) AS score", query.Text, query.Text).
+			Where("deleted = FALSE AND (name % ? OR brand % ?)", query.Text, query.Text).
+			Order("score DESC, created_at, id").
+			Limit(query.Limit).
+			Offset(query.Offset).
+			Scan(results).Error
+	})
+}
+
+func (db *DB) searchText(ctx context.Context, query SearchQuery, terms []textTerm, results *[]SearchResult) error {
+	// Highlighting every match of the (short) fields, with markers escaped afterwards
+	headline := "HighlightAll=true, StartSel=" + highlightStart + ", StopSel=" + highlightStop
+
+	// The search column is generated from name and brand, served by idx_devices_search
+	return db.Connector.WithContext(ctx).Raw(`SELECT devices.*, ts_rank(search, query) AS score,
+			ts_headline('simple', name, query, @headline) AS name_highlight,
+			ts_headline('simple', brand, query, @headline) AS brand_highlight
+		FROM devices, to_tsquery('simple', @query) query
+		WHERE deleted = FALSE AND search @@ query
+		ORDER BY score DESC, created_at, id
+		LIMIT @limit OFFSET @offset`,
+		sql.Named("headline", headline),
+		sql.Named("query", tsquery(terms)),
+		sql.Named("limit", query.Limit),
+		sql.Named("offset", query.Offset),
+	).Scan(results).Error
+}
+
+// rankDevices scores the devices against the search, keeping the ones matching it, best first, then
+// paginates them. terms is the parsed text of full-text searches.
+func rankDevices(devices []database.Device, query SearchQuery, terms []textTerm) []SearchResult {
+	results := []SearchResult{}
+	for _, device := range devices {
+		if query.Mode == SearchFuzzy {
+			score := max(similarity(device.Name, query.Text), similarity(device.Brand, query.Text))
+			if score >= query.Threshold {
+				results = append(results, SearchResult{Device: device, Score: score})
+			}
+			continue
+		}
+
+		if score, ok := scoreText(device, terms); ok {
+			results = append(results, SearchResult{
+				Device:         device,
+				Score:          score,
+				NameHighlight:  markHighlight(highlightText(device.Name, terms)),
+				BrandHighlight: markHighlight(highlightText(device.Brand, terms)),
+			})
+		}
+	}
+
+	// Same as ORDER BY score DESC, created_at, id
+	keys, _ := sortKeys(nil)
+	slices.SortFunc(results, func(a, b SearchResult) int {
+		if a.Score != b.Score {
+			if a.Score > b.Score {
+				return -1
+			}
+			return 1
+		}
+		return CursorOf(a.Device).Compare(CursorOf(b.Device), keys)
+	})
+
+	results = results[min(max(query.Offset, 0), len(results)):]
+	if query.Limit >= 0 && len(results) > query.Limit {
+		results = results[:query.Limit]
+	}
+	return results
+}
+
+// trigrams extracts the trigrams of a text the way pg_trgm does: every word (run of letters and digits),
+// lowercased and padded with two spaces before and one after, gives its three characters long substrings.
+func trigrams(text string) map[string]struct{} {
+	set := map[string]struct{}{}
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			set[string(padded[i:i+3])] = struct{}{}
+		}
+	}
+	return set
+}
+
+// similarity is the number of trigrams shared by both texts over the number of distinct trigrams
+// among them, pg_trgm's similarity().
+func similarity(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for t := range ta {
+		if _, ok := tb[t]; ok {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(ta)+len(tb)-shared)
+}
@@ -0,0 +1,158 @@
+package db_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/lcmps/DevicesAPI/db"
+	"github.com/lcmps/DevicesAPI/model/database"
+)
+
+// testSearchDevices runs the same fuzzy searches against any store, scores following pg_trgm.
+func testSearchDevices(t *testing.T, store db.DeviceStore) {
+	ctx := t.Context()
+
+	var deleted *database.Device
+	for _, d := range []*database.Device{
+		{Name: "Alpha", Brand: "Brand", State: "Available"},
+		{Name: "Gamma", Brand: "Acme", State: "Available"},
+		{Name: "Gamma Ray", Brand: "Brand", State: "In-Use"},
+		{Name: "Gamma", Brand: "Brand", State: "Inactive"},
+	} {
+		if err := store.CreateDevice(ctx, d); err != nil {
+			t.Fatalf("failed to create device: %v", err)
+		}
+		deleted = d
+	}
+	if err := store.DeleteDevice(ctx, deleted.ID.String(), 0); err != nil {
+		t.Fatalf("failed to delete device: %v", err)
+	}
+
+	type match struct {
+		name, brand string
+		score       float64
+	}
+	cases := []struct {
+		name      string
+		text      string
+		threshold float64
+		want      []match
+	}{
+		// "gamm" shares 4 trigrams out of the 7 of "gamma" ("  g", " ga", "gam", "amm", "mma", "ma ", "mm ")
+		{"NameTypo", "Gamm", 0.3, []match{{"Gamma", "Acme", 4.0 / 7}, {"Gamma Ray", "Brand", 4.0 / 11}}},
+		{"HigherThreshold", "Gamm", 0.5, []match{{"Gamma", "Acme", 4.0 / 7}}},
+		// "brnd" shares "  b", " br" and "nd " out of the 8 trigrams of both
+		{"BrandTypo", "Brnd", 0.3, []match{{"Alpha", "Brand", 3.0 / 8}, {"Gamma Ray", "Brand", 3.0 / 8}}},
+		{"CaseAndPunctuation", "gamma-ray!", 0.9, []match{{"Gamma Ray", "Brand", 1}}},
+		{"NoMatch", "Zzz", 0.1, nil},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			results, err := store.SearchDevices(ctx, db.SearchQuery{Text: tc.text, Threshold: tc.threshold, Limit: 10})
+			if err != nil {
+				t.Fatalf("expected nil error, got %v", err)
+			}
+			if len(results) != len(tc.want) {
+				t.Fatalf("expected %d results, got %+v", len(tc.want), results)
+			}
+			for i, want := range tc.want {
+				got := results[i]
+				if got.Device.Name != want.name || got.Device.Brand != want.brand || math.Abs(got.Score-want.score) > 1e-6 {
+					t.Fatalf("expected result %d to be %+v, got %s/%s scoring %v", i, want, got.Device.Name, got.Device.Brand, got.Score)
+				}
+			}
+		})
+	}
+
+	page, _ := store.SearchDevices(ctx, db.SearchQuery{Text: "Gamm", Threshold: 0.3, Limit: 1, Offset: 1})
+	if len(page) != 1 || page[0].Device.Name != "Gamma Ray" {
+		t.Fatalf("expected the second best result, got %+v", page)
+	}
+}
+
+func TestMemory_SearchDevices(t *testing.T) {
+	testSearchDevices(t, db.NewMemory())
+}
+
+func TestSQLite_SearchDevices(t *testing.T) {
+	testSearchDevices(t, newSQLite(t))
+}
+
+// testTextSearch runs the same full-text searches against any store.
+func testTextSearch(t *testing.T, store db.DeviceStore) {
+	ctx := t.Context()
+
+	for _, d := range []*database.Device{
+		{Name: "Gamma Ray Scanner", Brand: "Acme", State: "Available"},
+		{Name: "Ray Gamma", Brand: "Acme", State: "Available"},
+		{Name: "Router <b>", Brand: "Gamma Labs", State: "In-Use"},
+		{Name: "Scanner", Brand: "Brand", State: "Inactive"},
+	} {
+		if err := store.CreateDevice(ctx, d); err != nil {
+			t.Fatalf("failed to create device: %v", err)
+		}
+	}
+
+	cases := []struct {
+		name string
+		text string
+		want []string
+	}{
+		// Name matches weigh more than brand ones
+		{"Word", "gamma", []string{"Gamma Ray Scanner", "Ray Gamma", "Router <b>"}},
+		{"AllWords", "ray GAMMA", []string{"Gamma Ray Scanner", "Ray Gamma"}},
+		{"Phrase", `"gamma ray"`, []string{"Gamma Ray Scanner"}},
+		{"Prefix", "scan*", []string{"Gamma Ray Scanner", "Scanner"}},
+		{"PhrasePrefix", `"ray sca"*`, []string{"Gamma Ray Scanner"}},
+		{"Negation", "gamma -ray", []string{"Router <b>"}},
+		{"NoMatch", "laser", nil},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			results, err := store.SearchDevices(ctx, db.SearchQuery{Text: tc.text, Mode: db.SearchText, Limit: 10})
+			if err != nil {
+				t.Fatalf("expected nil error, got %v", err)
+			}
+			var got []string
+			for _, r := range results {
+				got = append(got, r.Device.Name)
+				if r.Score <= 0 {
+					t.Fatalf("expected a positive score, got %+v", r)
+				}
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+			for i := range tc.want {
+				if got[i] != tc.want[i] {
+					t.Fatalf("expected %v, got %v", tc.want, got)
+				}
+			}
+		})
+	}
+
+	results, _ := store.SearchDevices(ctx, db.SearchQuery{Text: "gamma rout*", Mode: db.SearchText, Limit: 10})
+	if len(results) != 1 {
+		t.Fatalf("expected a single result, got %+v", results)
+	}
+	if results[0].NameHighlight != "<mark>Router</mark> &lt;b&gt;" || results[0].BrandHighlight != "<mark>Gamma</mark> Labs" {
+		t.Fatalf("unexpected highlights %q and %q", results[0].NameHighlight, results[0].BrandHighlight)
+	}
+
+	for _, text := range []string{"", "-gamma", "!!! ***"} {
+		if _, err := store.SearchDevices(ctx, db.SearchQuery{Text: text, Mode: db.SearchText, Limit: 10}); !errors.Is(err, db.ErrInvalidSearch) {
+			t.Fatalf("expected ErrInvalidSearch on %q, got %v", text, err)
+		}
+	}
+}
+
+func TestMemory_TextSearch(t *testing.T) {
+	testTextSearch(t, db.NewMemory())
+}
+
+func TestSQLite_TextSearch(t *testing.T) {
+	testTextSearch(t, newSQLite(t))
+}
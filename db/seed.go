@@ -0,0 +1,80 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lcmps/DevicesAPI/model"
+	"github.com/lcmps/DevicesAPI/model/database"
+	"go.yaml.in/yaml/v3"
+)
+
+// Seeder is implemented by the stores able to load fixture devices.
+// Seeding must be idempotent: devices with the same name and brand of a live one are skipped.
+type Seeder interface {
+	SeedDevices(ctx context.Context, devices []database.Device) (int, error)
+}
+
+var (
+	_ Seeder = (*DB)(nil)
+	_ Seeder = (*MemoryDB)(nil)
+)
+
+// SeedFromFile loads the fixture at path into the store and returns how many devices were created.
+func SeedFromFile(ctx context.Context, store Seeder, path string) (int, error) {
+	devices, err := LoadFixture(path)
+	if err != nil {
+		return 0, err
+	}
+	return store.SeedDevices(ctx, devices)
+}
+
+// fixtureDevice is a single entry of a seed fixture file.
+type fixtureDevice struct {
+	Name  string `json:"name" yaml:"name"`
+	Brand string `json:"brand" yaml:"brand"`
+	State string `json:"state" yaml:"state"`
+}
+
+// LoadFixture reads the devices to be seeded from a JSON or YAML file, chosen by its extension.
+// The file must contain a list of objects with name, brand and (optionally) state.
+func LoadFixture(path string) ([]database.Device, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture file: %w", err)
+	}
+
+	var fixtures []fixtureDevice
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(content, &fixtures)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &fixtures)
+	default:
+		return nil, fmt.Errorf("unsupported fixture file %q, should be .json, .yaml or .yml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse fixture file: %w", err)
+	}
+
+	devices := make([]database.Device, 0, len(fixtures))
+	for i, f := range fixtures {
+		if f.Name == "" || f.Brand == "" {
+			return nil, fmt.Errorf("invalid fixture entry %d: name and brand are required fields", i)
+		}
+		if f.State == "" {
+			f.State = model.StateAvailable
+		}
+		if !model.IsValidState(f.State) {
+			return nil, fmt.Errorf("invalid fixture entry %d: invalid state %q, should be one of: %s",
+				i, f.State, strings.Join(model.States, ", "))
+		}
+		devices = append(devices, database.Device{Name: f.Name, Brand: f.Brand, State: f.State})
+	}
+
+	return devices, nil
+}
@@ -0,0 +1,93 @@
+package db_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lcmps/DevicesAPI/db"
+)
+
+func writeFixture(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+	return path
+}
+
+func TestLoadFixture(t *testing.T) {
+	cases := []struct {
+		name    string
+		file    string
+		content string
+		want    int
+		wantErr bool
+	}{
+		{"JSON", "devices.json", `[{"name":"Alpha","brand":"BrandA","state":"In-Use"},{"name":"Beta","brand":"BrandB"}]`, 2, false},
+		{"YAML", "devices.yaml", "- name: Alpha\n  brand: BrandA\n  state: Inactive\n", 1, false},
+		{"YML", "devices.yml", "- name: Alpha\n  brand: BrandA\n", 1, false},
+		{"UnsupportedExtension", "devices.txt", "Alpha,BrandA", 0, true},
+		{"Malformed", "devices.json", `[{"name":`, 0, true},
+		{"MissingBrand", "devices.json", `[{"name":"Alpha"}]`, 0, true},
+		{"InvalidState", "devices.yaml", "- name: Alpha\n  brand: BrandA\n  state: Broken\n", 0, true},
+		{"LowercaseState", "devices.json", `[{"name":"Alpha","brand":"BrandA","state":"in-use"}]`, 0, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			devices, err := db.LoadFixture(writeFixture(t, tc.file, tc.content))
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected nil error, got %v", err)
+			}
+			if len(devices) != tc.want {
+				t.Fatalf("expected %d devices, got %d", tc.want, len(devices))
+			}
+			for _, d := range devices {
+				if d.State == "" {
+					t.Fatal("expected state to default to Available")
+				}
+			}
+		})
+	}
+
+	if _, err := db.LoadFixture(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Fatal("expected error for missing file, got nil")
+	}
+}
+
+func TestSeedFromFile(t *testing.T) {
+	fixture := writeFixture(t, "devices.yaml", "- name: Alpha\n  brand: BrandA\n- name: Beta\n  brand: BrandB\n  state: Inactive\n")
+
+	stores := map[string]db.Seeder{
+		"Memory": db.NewMemory(),
+		"SQLite": newSQLite(t),
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			created, err := db.SeedFromFile(t.Context(), store, fixture)
+			if err != nil || created != 2 {
+				t.Fatalf("expected 2 devices seeded, got %d (%v)", created, err)
+			}
+
+			// Seeding again must not duplicate anything
+			created, err = db.SeedFromFile(t.Context(), store, fixture)
+			if err != nil || created != 0 {
+				t.Fatalf("expected second seed to be a no-op, got %d (%v)", created, err)
+			}
+
+			devices, err := store.(db.DeviceStore).GetDevices(t.Context(), db.DeviceQuery{Limit: 10})
+			if err != nil || len(devices) != 2 {
+				t.Fatalf("expected 2 stored devices, got %d (%v)", len(devices), err)
+			}
+		})
+	}
+}
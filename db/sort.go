@@ -0,0 +1,163 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lcmps/DevicesAPI/model/database"
+)
+
+// Columns the device list can be sorted and filtered by
+const (
+	ColumnName      = "name"
+	ColumnBrand     = "brand"
+	ColumnState     = "state"
+	ColumnCreatedAt = "created_at"
+)
+
+var columns = []string{ColumnName, ColumnBrand, ColumnState, ColumnCreatedAt}
+
+// SortField is one of the keys the device list is ordered by.
+type SortField struct {
+	Column string
+	Desc   bool
+}
+
+// defaultSort lists the oldest devices first.
+var defaultSort = []SortField{{Column: ColumnCreatedAt}}
+
+// Cursor is a position on the device list, used for keyset pagination.
+// It holds every sortable value of the device, so it works with any sort.
+type Cursor struct {
+	Name      string
+	Brand     string
+	State     string
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// CursorOf returns the position of the device on the device list.
+func CursorOf(device database.Device) Cursor {
+	return Cursor{Name: device.Name, Brand: device.Brand, State: device.State, CreatedAt: device.CreatedAt, ID: device.ID}
+}
+
+// sortKeys validates the sort and completes it with the id, which makes the order total.
+func sortKeys(sort []SortField) ([]SortField, error) {
+	if len(sort) == 0 {
+		sort = defaultSort
+	}
+	keys := make([]SortField, 0, len(sort)+1)
+	for _, field := range sort {
+		if !slices.Contains(columns, field.Column) {
+			return nil, fmt.Errorf("unknown sort column %q", field.Column)
+		}
+		keys = append(keys, field)
+	}
+	return append(keys, SortField{Column: "id"}), nil
+}
+
+// value returns the value of the column at the cursor.
+func (c Cursor) value(column string) any {
+	switch column {
+	case ColumnName:
+		return c.Name
+	case ColumnBrand:
+		return c.Brand
+	case ColumnState:
+		return c.State
+	case ColumnCreatedAt:
+		return c.CreatedAt.UTC()
+	default:
+		return c.ID
+	}
+}
+
+// compare orders two cursors on a single column, ascending.
+func (c Cursor) compare(other Cursor, column string) int {
+	switch column {
+	case ColumnName:
+		return strings.Compare(c.Name, other.Name)
+	case ColumnBrand:
+		return strings.Compare(c.Brand, other.Brand)
+	case ColumnState:
+		return strings.Compare(c.State, other.State)
+	case ColumnCreatedAt:
+		return c.CreatedAt.Compare(other.CreatedAt)
+	default:
+		return bytes.Compare(c.ID[:], other.ID[:])
+	}
+}
+
+// Compare orders cursors the same way devices are listed with the given keys (see sortKeys).
+func (c Cursor) Compare(other Cursor, keys []SortField) int {
+	for _, key := range keys {
+		n := c.compare(other, key.Column)
+		if key.Desc {
+			n = -n
+		}
+		if n != 0 {
+			return n
+		}
+	}
+	return 0
+}
+
+// orderClause builds the ORDER BY clause of the keys, reversed when walking backwards.
+func orderClause(keys []SortField, reverse bool) string {
+	clauses := make([]string, 0, len(keys))
+	for _, key := range keys {
+		direction := "ASC"
+		if key.Desc != reverse {
+			direction = "DESC"
+		}
+		clauses = append(clauses, key.Column+" "+direction)
+	}
+	return strings.Join(clauses, ", ")
+}
+
+// keysetCondition builds the WHERE condition selecting the devices after the cursor on the given keys,
+// or before it. Keys sharing the same direction are compared at once through row values, which
+// Postgres serves through a multicolumn index, mixed ones are expanded into
+// (a > ?) OR (a = ? AND b < ?) OR ...
+func keysetCondition(keys []SortField, cursor Cursor, after bool) (string, []any) {
+	operator := func(desc bool) string {
+		if after != desc {
+			return ">"
+		}
+		return "<"
+	}
+
+	uniform := true
+	for _, key := range keys {
+		uniform = uniform && key.Desc == keys[0].Desc
+	}
+	if uniform {
+		columns := make([]string, 0, len(keys))
+		placeholders := make([]string, 0, len(keys))
+		args := make([]any, 0, len(keys))
+		for _, key := range keys {
+			columns = append(columns, key.Column)
+			placeholders = append(placeholders, "?")
+			args = append(args, cursor.value(key.Column))
+		}
+		return fmt.Sprintf("(%s) %s (%s)", strings.Join(columns, ", "), operator(keys[0].Desc), strings.Join(placeholders, ", ")), args
+	}
+
+	var conditions []string
+	var args []any
+	for i, key := range keys {
+		parts := make([]string, 0, i+1)
+		for _, previous := range keys[:i] {
+			parts = append(parts, previous.Column+" = ?")
+			args = append(args, cursor.value(previous.Column))
+		}
+		parts = append(parts, fmt.Sprintf("%s %s ?", key.Column, operator(key.Desc)))
+		args = append(args, cursor.value(key.Column))
+		conditions = append(conditions, "("+strings.Join(parts, " AND ")+")")
+	}
+	return strings.Join(conditions, " OR "), args
+}
@@ -0,0 +1,31 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// NewSQLite opens (or creates) the SQLite database file at path.
+// It uses a pure Go driver, so the binary can still be built with CGO_ENABLED=0.
+func NewSQLite(path string) (*DB, error) {
+	if path == "" {
+		return nil, fmt.Errorf("failed to connect to database: empty sqlite path")
+	}
+
+	// busy_timeout avoids "database is locked" errors when a write is waiting for another one
+	conn, err := gorm.Open(sqlite.Open(path+"?_pragma=busy_timeout(5000)"), &gorm.Config{TranslateError: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	// SQLite only allows a single writer, so there's no point in having more than one connection
+	sqlDB, err := conn.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+
+	return &DB{Connector: conn, driver: driverSQLite}, nil
+}
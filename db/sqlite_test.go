@@ -0,0 +1,270 @@
+package db_test
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/lcmps/DevicesAPI/db"
+	"github.com/lcmps/DevicesAPI/model/database"
+)
+
+func newSQLite(t *testing.T) *db.DB {
+	t.Helper()
+	dbInstance, err := db.NewSQLite(filepath.Join(t.TempDir(), "devices.db"))
+	if err != nil {
+		t.Fatalf("failed to open sqlite database: %v", err)
+	}
+	if _, err := dbInstance.MigrateUp(); err != nil {
+		t.Fatalf("expected nil error from MigrateUp, got %v", err)
+	}
+	t.Cleanup(func() {
+		sqlDB, _ := dbInstance.Connector.DB()
+		_ = sqlDB.Close()
+	})
+	return dbInstance
+}
+
+func TestNewSQLite(t *testing.T) {
+	if _, err := db.NewSQLite(""); err == nil {
+		t.Fatal("expected error for empty path, got nil")
+	}
+
+	dbInstance := newSQLite(t)
+	// Init must be safe to run against an already migrated file, and must not seed anything
+	if err := dbInstance.Init(); err != nil {
+		t.Fatalf("expected nil error from Init, got %v", err)
+	}
+	if devices, _ := dbInstance.GetDevices(t.Context(), db.DeviceQuery{Limit: 10}); len(devices) != 0 {
+		t.Fatalf("expected no devices after Init, got %d", len(devices))
+	}
+}
+
+func TestSQLite_CreateDevice(t *testing.T) {
+	dbInstance := newSQLite(t)
+
+	device := &database.Device{Name: "TestDevice", Brand: "TestBrand", State: "Available"}
+	if err := dbInstance.CreateDevice(t.Context(), device); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if device.ID == uuid.Nil {
+		t.Fatal("expected ID to be generated")
+	}
+	if device.CreatedAt.IsZero() {
+		t.Fatal("expected CreatedAt to be set")
+	}
+
+	invalid := &database.Device{Name: "Broken", Brand: "TestBrand", State: "Broken"}
+	if err := dbInstance.CreateDevice(t.Context(), invalid); err == nil {
+		t.Fatal("expected CHECK constraint error for invalid state, got nil")
+	}
+
+	if err := dbInstance.CreateDevice(t.Context(), nil); err == nil {
+		t.Fatal("expected error when creating device with nil pointer, got nil")
+	}
+}
+
+func TestSQLite_GetDeviceByID(t *testing.T) {
+	dbInstance := newSQLite(t)
+
+	device := &database.Device{Name: "FetchMe", Brand: "BrandY", State: "Available"}
+	if err := dbInstance.CreateDevice(t.Context(), device); err != nil {
+		t.Fatalf("failed to create device for fetch: %v", err)
+	}
+
+	fetched, err := dbInstance.GetDeviceByID(t.Context(), device.ID.String())
+	if err != nil {
+		t.Fatalf("expected nil error on fetch, got %v", err)
+	}
+	if fetched.ID != device.ID || fetched.Name != "FetchMe" || fetched.CreatedAt.IsZero() {
+		t.Fatalf("unexpected fetched device %+v", fetched)
+	}
+
+	if _, err := dbInstance.GetDeviceByID(t.Context(), "00000000-0000-0000-0000-000000000000"); !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for non-existent device, got %v", err)
+	}
+	if _, err := dbInstance.GetDeviceByID(t.Context(), "not-a-uuid"); !errors.Is(err, db.ErrInvalidID) {
+		t.Fatalf("expected ErrInvalidID for invalid UUID, got %v", err)
+	}
+}
+
+func TestSQLite_GetDevices(t *testing.T) {
+	dbInstance := newSQLite(t)
+
+	for _, d := range []*database.Device{
+		{Name: "Alpha", Brand: "BrandA", State: "Available"},
+		{Name: "Beta", Brand: "BrandB", State: "Inactive"},
+		{Name: "Gamma", Brand: "BrandA", State: "In-Use"},
+	} {
+		if err := dbInstance.CreateDevice(t.Context(), d); err != nil {
+			t.Fatalf("failed to create device: %v", err)
+		}
+	}
+
+	cases := []struct {
+		name               string
+		brand, state, text string
+		want               int
+	}{
+		{"All", "", "", "", 3},
+		{"Brand", "BrandA", "", "", 2},
+		{"State", "", "Inactive", "", 1},
+		{"PartialNameCaseInsensitive", "", "", "AMM", 1},
+		{"NoMatch", "BrandB", "", "alpha", 0},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			devices, err := dbInstance.GetDevices(t.Context(), db.DeviceQuery{Brand: tc.brand, State: tc.state, Name: tc.text, Limit: 10})
+			if err != nil {
+				t.Fatalf("expected nil error, got %v", err)
+			}
+			if len(devices) != tc.want {
+				t.Fatalf("expected %d devices, got %d", tc.want, len(devices))
+			}
+		})
+	}
+}
+
+func TestSQLite_UpdateAndDeleteDevice(t *testing.T) {
+	dbInstance := newSQLite(t)
+
+	device := &database.Device{Name: "UpdateMe", Brand: "BrandX", State: "Available"}
+	if err := dbInstance.CreateDevice(t.Context(), device); err != nil {
+		t.Fatalf("failed to create device: %v", err)
+	}
+
+	device.State = "Inactive"
+	if err := dbInstance.UpdateDevice(t.Context(), device); err != nil {
+		t.Fatalf("expected nil error on update, got %v", err)
+	}
+	fetched, _ := dbInstance.GetDeviceByID(t.Context(), device.ID.String())
+	if fetched.State != "Inactive" {
+		t.Fatalf("expected state Inactive, got %v", fetched.State)
+	}
+
+	device.State = "Broken"
+	if err := dbInstance.UpdateDevice(t.Context(), device); err == nil || !strings.Contains(err.Error(), "failed to update device") {
+		t.Fatalf("expected CHECK constraint error on update, got %v", err)
+	}
+
+	inUse := &database.Device{Name: "InUse", Brand: "BrandX", State: "In-Use"}
+	if err := dbInstance.CreateDevice(t.Context(), inUse); err != nil {
+		t.Fatalf("failed to create device: %v", err)
+	}
+	if err := dbInstance.DeleteDevice(t.Context(), inUse.ID.String(), 0); !errors.Is(err, db.ErrStateViolation) {
+		t.Fatalf("expected ErrStateViolation when deleting an in use device, got %v", err)
+	}
+
+	if err := dbInstance.DeleteDevice(t.Context(), device.ID.String(), 0); err != nil {
+		t.Fatalf("expected nil error on delete, got %v", err)
+	}
+	if _, err := dbInstance.GetDeviceByID(t.Context(), device.ID.String()); !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("expected deleted device to be hidden, got %v", err)
+	}
+	if err := dbInstance.DeleteDevice(t.Context(), device.ID.String(), 0); !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for already deleted device, got %v", err)
+	}
+	if err := dbInstance.DeleteDevice(t.Context(), "not-a-uuid", 0); !errors.Is(err, db.ErrInvalidID) {
+		t.Fatalf("expected ErrInvalidID for invalid UUID, got %v", err)
+	}
+	device.State = "Available"
+	if err := dbInstance.UpdateDevice(t.Context(), device); !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound when updating a deleted device, got %v", err)
+	}
+}
+
+func TestSQLite_ClosedDatabase(t *testing.T) {
+	dbInstance := newSQLite(t)
+
+	device := &database.Device{Name: "Closed", Brand: "BrandC", State: "Available"}
+	if err := dbInstance.CreateDevice(t.Context(), device); err != nil {
+		t.Fatalf("failed to create device: %v", err)
+	}
+	sqlDB, _ := dbInstance.Connector.DB()
+	_ = sqlDB.Close()
+
+	// The transaction can't even begin, which is reported like any other failed write
+	id := device.ID.String()
+	_, restoreErr := dbInstance.RestoreDevice(t.Context(), id)
+	cases := map[string]error{
+		"failed to create device":  dbInstance.CreateDevice(t.Context(), &database.Device{Name: "Other", Brand: "BrandC", State: "Available"}),
+		"failed to update device":  dbInstance.UpdateDevice(t.Context(), device),
+		"failed to delete device":  dbInstance.DeleteDevice(t.Context(), id, 0),
+		"failed to restore device": restoreErr,
+	}
+	for want, err := range cases {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q error, got %v", want, err)
+		}
+	}
+}
+
+func TestSQLite_UniqueNameBrand(t *testing.T) {
+	dbInstance := newSQLite(t)
+
+	alpha := &database.Device{Name: "Alpha", Brand: "BrandA", State: "Available"}
+	if err := dbInstance.CreateDevice(t.Context(), alpha); err != nil {
+		t.Fatalf("failed to create device: %v", err)
+	}
+
+	if err := dbInstance.CreateDevice(t.Context(), &database.Device{Name: "Alpha", Brand: "BrandA", State: "Inactive"}); !errors.Is(err, db.ErrConflict) {
+		t.Fatalf("expected ErrConflict for duplicated name and brand, got %v", err)
+	}
+	if err := dbInstance.CreateDevice(t.Context(), &database.Device{Name: "Alpha", Brand: "BrandB", State: "Available"}); err != nil {
+		t.Fatalf("expected same name on another brand to be allowed, got %v", err)
+	}
+
+	beta := &database.Device{Name: "Beta", Brand: "BrandA", State: "Available"}
+	if err := dbInstance.CreateDevice(t.Context(), beta); err != nil {
+		t.Fatalf("failed to create device: %v", err)
+	}
+	beta.Name = "Alpha"
+	if err := dbInstance.UpdateDevice(t.Context(), beta); !errors.Is(err, db.ErrConflict) {
+		t.Fatalf("expected ErrConflict when renaming to an existing device, got %v", err)
+	}
+
+	// Deleted devices don't hold their name and brand anymore
+	if err := dbInstance.DeleteDevice(t.Context(), alpha.ID.String(), 0); err != nil {
+		t.Fatalf("failed to delete device: %v", err)
+	}
+	if err := dbInstance.UpdateDevice(t.Context(), beta); err != nil {
+		t.Fatalf("expected rename to succeed after deletion, got %v", err)
+	}
+}
+
+func TestSQLite_Version(t *testing.T) {
+	dbInstance := newSQLite(t)
+
+	device := &database.Device{Name: "Versioned", Brand: "BrandX", State: "Available"}
+	if err := dbInstance.CreateDevice(t.Context(), device); err != nil {
+		t.Fatalf("failed to create device: %v", err)
+	}
+	if device.Version != 1 {
+		t.Fatalf("expected version 1 on create, got %d", device.Version)
+	}
+
+	update := &database.Device{ID: device.ID, State: "Inactive", Version: 1}
+	if err := dbInstance.UpdateDevice(t.Context(), update); err != nil {
+		t.Fatalf("expected nil error on update, got %v", err)
+	}
+	if update.Version != 2 || update.Name != "Versioned" {
+		t.Fatalf("expected the updated device to be returned, got %+v", update)
+	}
+
+	stale := &database.Device{ID: device.ID, State: "Available", Version: 1}
+	if err := dbInstance.UpdateDevice(t.Context(), stale); !errors.Is(err, db.ErrVersionMismatch) {
+		t.Fatalf("expected ErrVersionMismatch for a stale update, got %v", err)
+	}
+	if fetched, _ := dbInstance.GetDeviceByID(t.Context(), device.ID.String()); fetched.State != "Inactive" {
+		t.Fatalf("expected stale update to leave the device untouched, got %v", fetched.State)
+	}
+	if err := dbInstance.DeleteDevice(t.Context(), device.ID.String(), 1); !errors.Is(err, db.ErrVersionMismatch) {
+		t.Fatalf("expected ErrVersionMismatch for a stale delete, got %v", err)
+	}
+	if err := dbInstance.DeleteDevice(t.Context(), device.ID.String(), 2); err != nil {
+		t.Fatalf("expected nil error on delete, got %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package db
+
+import (
+	"context"
+
+	"github.com/lcmps/DevicesAPI/model/database"
+)
+
+// DeviceStore is the storage contract used by the web layer.
+// Any backend able to persist devices (Postgres, in-memory fakes for tests, etc.)
+// only needs to implement these methods to be plugged into web.New.
+// Implementations must report failures through the errors declared on errors.go
+// (ErrNotFound, ErrInvalidID, ...) so the web layer can map them to the right status code.
+// Every change must be recorded on the audit trail along with the AuditInfo carried by ctx, atomically.
+type DeviceStore interface {
+	CreateDevice(ctx context.Context, device *database.Device) error
+	// CreateDevices creates several devices at once and returns the error of each one, nil when it was
+	// created. With BulkAtomic, either every device is created or none is, the ones that didn't fail
+	// being reported with ErrBulkAborted. The last error is a failure of the whole write.
+	CreateDevices(ctx context.Context, devices []database.Device, mode BulkMode) ([]error, error)
+	// ChangeDevices moves several devices to another state, or deletes them, at once and returns the outcome
+	// of each one. With BulkAtomic, either every device is changed or none is.
+	ChangeDevices(ctx context.Context, change BulkChange) ([]BulkOutcome, error)
+	GetDeviceByID(ctx context.Context, id string) (database.Device, error)
+	GetDevices(ctx context.Context, query DeviceQuery) ([]database.Device, error)
+	// ExportDevices calls fn with every device matching the query, in its order and regardless of its pagination,
+	// without loading them all in memory. It stops on the first error returned by fn, returning it.
+	ExportDevices(ctx context.Context, query DeviceQuery, fn func(device database.Device) error) error
+	// CountDevices returns how many devices match the filters of the query, regardless of its pagination.
+	// With CountEstimate, stores may answer from statistics instead, reporting it through estimated.
+	CountDevices(ctx context.Context, query DeviceQuery, mode CountMode) (count int64, estimated bool, err error)
+	// UpdateDevice changes the non-empty fields of the device. When device.Version is set, the write only
+	// happens if the stored version still matches it (ErrVersionMismatch otherwise). On success, device
+	// holds the stored values, including its new version.
+	UpdateDevice(ctx context.Context, device *database.Device) error
+	// DeleteDevice soft deletes the device, conditioned to the given version unless it is 0.
+	DeleteDevice(ctx context.Context, id string, version int64) error
+	// RestoreDevice brings a soft deleted device back and returns it. It fails with ErrConflict
+	// when a live device took its name and brand in the meantime.
+	RestoreDevice(ctx context.Context, id string) (database.Device, error)
+	// SearchDevices returns the live devices whose name or brand are similar to the search text, best match first.
+	SearchDevices(ctx context.Context, query SearchQuery) ([]SearchResult, error)
+	// GetAuditRecords returns the audit records matching the query, newest first.
+	GetAuditRecords(ctx context.Context, query AuditQuery) ([]database.AuditRecord, error)
+}
+
+// DeletedFilter tells which devices to list, according to whether they were deleted.
+type DeletedFilter int
+
+const (
+	ExcludeDeleted DeletedFilter = iota
+	IncludeDeleted
+	OnlyDeleted
+)
+
+// DeviceQuery filters and paginates the device list, empty filters match every device.
+// Name is a case-insensitive partial match, the others must match exactly. A negative Limit lists every device.
+// Devices are listed following Sort, oldest first when empty, and then by id. After only lists the devices
+// following the cursor, while Before lists the ones right before it (still in the same order).
+type DeviceQuery struct {
+	Brand   string
+	State   string
+	Name    string
+	Deleted DeletedFilter
+	// Filter is an expression combined with the filters above
+	Filter *Filter
+	Limit  int
+	Offset int
+	Sort   []SortField
+	After  *Cursor
+	Before *Cursor
+}
+
+// CountMode tells how devices are counted.
+type CountMode int
+
+const (
+	CountExact CountMode = iota
+	CountEstimate
+)
+
+// Making sure the Postgres implementation always satisfies the interface.
+var _ DeviceStore = (*DB)(nil)
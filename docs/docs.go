@@ -15,11 +15,96 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/audit": {
+            "get": {
+                "description": "List the changes made to every device, newest first.",
+                "produces": [
+                    "application/json",
+                    "text/xml",
+                    "application/msgpack",
+                    "application/yaml"
+                ],
+                "tags": [
+                    "audit"
+                ],
+                "summary": "Query the audit trail",
+                "parameters": [
+                    {
+                        "enum": [
+                            "create",
+                            "update",
+                            "state_change",
+                            "delete",
+                            "restore"
+                        ],
+                        "type": "string",
+                        "description": "Action",
+                        "name": "action",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only changes made at or after this time (RFC 3339)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only changes made before this time (RFC 3339)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 50,
+                        "description": "Maximum number of records",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 0,
+                        "description": "Number of records to skip",
+                        "name": "start",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.AuditList"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "Not Acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            }
+        },
         "/device": {
             "get": {
-                "description": "List devices with optional filters for name, brand, and state. Supports pagination.",
+                "description": "List devices with optional filters for name, brand, and state, oldest first unless sorted. Supports pagination,\neither through start or through the next and prev cursors of a previous page.\nAdmins can also list deleted devices through includeDeleted or onlyDeleted.",
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "text/xml",
+                    "application/msgpack",
+                    "application/yaml"
                 ],
                 "tags": [
                     "devices"
@@ -28,7 +113,7 @@ const docTemplate = `{
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Number of records to return (default: 50)",
+                        "description": "Number of records to return (default: 50, at most 500)",
                         "name": "limit",
                         "in": "query"
                     },
@@ -38,6 +123,24 @@ const docTemplate = `{
                         "name": "start",
                         "in": "query"
                     },
+                    {
+                        "type": "string",
+                        "description": "Next or prev cursor of a previous page, replacing start",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Fields to sort by (name, brand, state, createdAt), descending when prefixed by -, e.g. brand,-createdAt",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "How the total is computed: exact (default), estimate or none",
+                        "name": "count",
+                        "in": "query"
+                    },
                     {
                         "type": "string",
                         "description": "Filter by device name (partial match)",
@@ -46,15 +149,45 @@ const docTemplate = `{
                     },
                     {
                         "type": "string",
-                        "description": "Filter by device brand",
+                        "description": "Filter by device brands, comma separated: Brand* matches by prefix, *rand* partially, a leading ! negates them",
                         "name": "brand",
                         "in": "query"
                     },
                     {
                         "type": "string",
-                        "description": "Filter by device state (Available, In-Use, Inactive)",
+                        "description": "Filter by device states (Available, In-Use, Inactive), comma separated, a leading ! negates them",
                         "name": "state",
                         "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only devices created at or after this RFC 3339 time",
+                        "name": "createdAfter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only devices created before this RFC 3339 time",
+                        "name": "createdBefore",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter expression, e.g. brand:BrandA AND createdAt\u003e=2025-09-01T00:00:00Z AND NOT state:Inactive",
+                        "name": "filter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Also list deleted devices (admins only)",
+                        "name": "includeDeleted",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "List only deleted devices (admins only)",
+                        "name": "onlyDeleted",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -67,13 +200,25 @@ const docTemplate = `{
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/model.RestError"
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "Not Acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/model.RestError"
+                            "$ref": "#/definitions/model.Problem"
                         }
                     }
                 }
@@ -81,10 +226,16 @@ const docTemplate = `{
             "post": {
                 "description": "Create a new device entry. Name, brand, and state are required. State must be one of:",
                 "consumes": [
-                    "application/json"
+                    "application/json",
+                    "text/xml",
+                    "application/msgpack",
+                    "application/yaml"
                 ],
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "text/xml",
+                    "application/msgpack",
+                    "application/yaml"
                 ],
                 "tags": [
                     "devices"
@@ -111,91 +262,189 @@ const docTemplate = `{
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/model.RestError"
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "Not Acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
                         }
                     },
                     "409": {
                         "description": "Conflict",
                         "schema": {
-                            "$ref": "#/definitions/model.RestError"
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/model.RestError"
+                            "$ref": "#/definitions/model.Problem"
                         }
                     }
                 }
             }
         },
-        "/device/{id}": {
-            "get": {
-                "description": "Fetch a single device by its ID.",
+        "/device/bulk": {
+            "post": {
+                "description": "Create up to 1000 devices at once, following the same rules as POST /device.\nEach device gets its own result, in the order they were sent, with the status code it would have got\non its own request along with the created device or the problem found. The response is 201 when\nevery device was created and 207 otherwise. Atomic requests create either every device or none of\nthem, reporting the ones that didn't fail with 424. Devices are only checked for conflicts once\nevery device of an atomic request is valid.",
+                "consumes": [
+                    "application/json",
+                    "text/xml",
+                    "application/msgpack",
+                    "application/yaml"
+                ],
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "text/xml",
+                    "application/msgpack",
+                    "application/yaml"
                 ],
                 "tags": [
                     "devices"
                 ],
-                "summary": "Get device by ID",
+                "summary": "Create several devices",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Device ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "atomic (default) or best-effort",
+                        "name": "mode",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Devices to create",
+                        "name": "devices",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.Device"
+                            }
+                        }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "201": {
+                        "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/model.Device"
+                            "$ref": "#/definitions/model.BulkResults"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "207": {
+                        "description": "Multi-Status",
+                        "schema": {
+                            "$ref": "#/definitions/model.BulkResults"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "Not Acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
                         "schema": {
-                            "$ref": "#/definitions/model.RestError"
+                            "$ref": "#/definitions/model.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/model.RestError"
+                            "$ref": "#/definitions/model.Problem"
                         }
                     }
                 }
-            },
-            "put": {
-                "description": "Fully or partially update an existing device. Name and brand cannot be changed if device is in use.",
+            }
+        },
+        "/device/bulk/delete": {
+            "post": {
+                "description": "Delete up to 1000 devices, devices in use being refused. Devices are selected by ids, or through\nthe same filters as GET /device when none is given, and deleted within a single transaction.\nEach device gets its own result, with the status code it would have got on its own request along\nwith the deleted device or the problem found. The response is 200 when no device failed and 207\notherwise. Atomic requests delete either every device or none of them, reporting the ones that\ndidn't fail with 424. Dry runs report the same results without deleting anything.",
                 "consumes": [
-                    "application/json"
+                    "application/json",
+                    "text/xml",
+                    "application/msgpack",
+                    "application/yaml"
                 ],
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "text/xml",
+                    "application/msgpack",
+                    "application/yaml"
                 ],
                 "tags": [
                     "devices"
                 ],
-                "summary": "Update an existing device",
+                "summary": "Delete several devices",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Device ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "atomic (default) or best-effort",
+                        "name": "mode",
+                        "in": "query"
                     },
                     {
-                        "description": "Device fields to update",
-                        "name": "device",
+                        "type": "boolean",
+                        "description": "Only report what would be deleted",
+                        "name": "dryRun",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Brand of the devices to delete, when no ids are given",
+                        "name": "brand",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "State of the devices to delete, when no ids are given",
+                        "name": "state",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Partial name of the devices to delete, when no ids are given",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only delete devices created at or after this RFC 3339 time",
+                        "name": "createdAfter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only delete devices created before this RFC 3339 time",
+                        "name": "createdBefore",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter expression, as on GET /device",
+                        "name": "filter",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Devices to delete, filters are used when omitted",
+                        "name": "selection",
                         "in": "body",
-                        "required": true,
                         "schema": {
-                            "$ref": "#/definitions/model.Device"
+                            "$ref": "#/definitions/model.BulkSelection"
                         }
                     }
                 ],
@@ -203,81 +452,1174 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/model.Device"
+                            "$ref": "#/definitions/model.BulkChangeResults"
+                        }
+                    },
+                    "207": {
+                        "description": "Multi-Status",
+                        "schema": {
+                            "$ref": "#/definitions/model.BulkChangeResults"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/model.RestError"
+                            "$ref": "#/definitions/model.Problem"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "406": {
+                        "description": "Not Acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
                         "schema": {
-                            "$ref": "#/definitions/model.RestError"
+                            "$ref": "#/definitions/model.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/model.RestError"
+                            "$ref": "#/definitions/model.Problem"
                         }
                     }
                 }
-            },
-            "delete": {
-                "description": "Soft delete a device by ID. Devices in use cannot be deleted.",
+            }
+        },
+        "/device/bulk/state": {
+            "post": {
+                "description": "Move up to 1000 devices to the given state, following the allowed transitions of each one. Devices\nare selected by ids, or through the same filters as GET /device when none is given, and changed\nwithin a single transaction. Each device gets its own result, with the status code it would have got\non its own request along with the device or the problem found. The response is 200 when no device\nfailed and 207 otherwise. Atomic requests change either every device or none of them, reporting the\nones that didn't fail with 424. Dry runs report the same results without changing anything.",
+                "consumes": [
+                    "application/json",
+                    "text/xml",
+                    "application/msgpack",
+                    "application/yaml"
+                ],
+                "produces": [
+                    "application/json",
+                    "text/xml",
+                    "application/msgpack",
+                    "application/yaml"
+                ],
                 "tags": [
                     "devices"
                 ],
-                "summary": "Delete a device",
+                "summary": "Change the state of several devices",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Device ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "atomic (default) or best-effort",
+                        "name": "mode",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only report what would change",
+                        "name": "dryRun",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Brand of the devices to change, when no ids are given",
+                        "name": "brand",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Current state of the devices to change, when no ids are given",
+                        "name": "state",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Partial name of the devices to change, when no ids are given",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only change devices created at or after this RFC 3339 time",
+                        "name": "createdAfter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only change devices created before this RFC 3339 time",
+                        "name": "createdBefore",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter expression, as on GET /device",
+                        "name": "filter",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Devices to change and their new state",
+                        "name": "change",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.BulkStateChange"
+                        }
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "No Content"
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.BulkChangeResults"
+                        }
+                    },
+                    "207": {
+                        "description": "Multi-Status",
+                        "schema": {
+                            "$ref": "#/definitions/model.BulkChangeResults"
+                        }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/model.RestError"
+                            "$ref": "#/definitions/model.Problem"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "406": {
+                        "description": "Not Acceptable",
                         "schema": {
-                            "$ref": "#/definitions/model.RestError"
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/model.RestError"
+                            "$ref": "#/definitions/model.Problem"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "model.Device": {
-            "type": "object",
-            "properties": {
-                "brand": {
-                    "type": "string"
-                },
+        },
+        "/device/export": {
+            "get": {
+                "description": "Stream every device matching the filters, with no pagination, as CSV (with a header) or as NDJSON\n(one JSON device per line). The format is chosen through format or, when absent, the Accept header,\nCSV being the default. Devices are sorted and filtered the same way as GET /device.",
+                "produces": [
+                    "text/csv",
+                    "application/x-ndjson"
+                ],
+                "tags": [
+                    "devices"
+                ],
+                "summary": "Export devices",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "csv or ndjson, instead of the Accept header",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Fields to sort by (name, brand, state, createdAt), descending when prefixed by -, e.g. brand,-createdAt",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by device name (partial match)",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by device brands, comma separated: Brand* matches by prefix, *rand* partially, a leading ! negates them",
+                        "name": "brand",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by device states (Available, In-Use, Inactive), comma separated, a leading ! negates them",
+                        "name": "state",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only devices created at or after this RFC 3339 time",
+                        "name": "createdAfter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only devices created before this RFC 3339 time",
+                        "name": "createdBefore",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter expression, e.g. brand:BrandA AND createdAt\u003e=2025-09-01T00:00:00Z AND NOT state:Inactive",
+                        "name": "filter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Also export deleted devices (admins only)",
+                        "name": "includeDeleted",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Export only deleted devices (admins only)",
+                        "name": "onlyDeleted",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Devices, as CSV or NDJSON",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "Not Acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/device/import": {
+            "post": {
+                "description": "Create the devices listed on a CSV file, one per row, whose header tells which columns hold the name,\nbrand and state (other columns are ignored). The file is read as it is received and written in\nbatches, each row following the same rules as POST /device. Invalid rows, and the ones conflicting with\na live device, are rejected while the others are imported. With upsert, conflicting rows update the\nstate of the device instead, following its allowed transitions. The report lists the first 1000\nrejected rows and why, downloadable as a CSV file by asking for text/csv. If the store fails, the\nimport stops and the report of what was written so far is sent with failedLine, the line to resume from.",
+                "consumes": [
+                    "text/csv"
+                ],
+                "produces": [
+                    "application/json",
+                    "text/xml",
+                    "application/msgpack",
+                    "application/yaml",
+                    "text/csv"
+                ],
+                "tags": [
+                    "devices"
+                ],
+                "summary": "Import devices from a CSV file",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Update the state of existing devices with the same name and brand",
+                        "name": "upsert",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Header of the name column (default: name)",
+                        "name": "nameColumn",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Header of the brand column (default: brand)",
+                        "name": "brandColumn",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Header of the state column (default: state)",
+                        "name": "stateColumn",
+                        "in": "query"
+                    },
+                    {
+                        "description": "CSV file, starting with its header",
+                        "name": "file",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.ImportReport"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "Not Acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "The store failed, reporting what was imported before",
+                        "schema": {
+                            "$ref": "#/definitions/model.ImportReport"
+                        }
+                    }
+                }
+            }
+        },
+        "/device/search": {
+            "get": {
+                "description": "Search devices by name and brand, best match first. Deleted devices are never found.\nFuzzy searches (the default) find devices despite typos, scoring them by trigram similarity, from 0 to 1.\nFull-text searches (mode=text) look for every word, \"quoted phrase\" and prefix* given, and none\nof the -negated ones, returning the name and brand with the matches highlighted through \u003cmark\u003e tags.",
+                "produces": [
+                    "application/json",
+                    "text/xml",
+                    "application/msgpack",
+                    "application/yaml"
+                ],
+                "tags": [
+                    "devices"
+                ],
+                "summary": "Search devices",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Text to look for, e.g. Gamm, or \\",
+                        "name": "q",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "fuzzy (default) or text",
+                        "name": "mode",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Similarity a device must reach on fuzzy searches, between 0 and 1 (default: 0.3)",
+                        "name": "threshold",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of records to return (default: 50, at most 500)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Starting index (default: 0)",
+                        "name": "start",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.SearchResults"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "Not Acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/device/{id}": {
+            "get": {
+                "description": "Fetch a single device by its ID.",
+                "produces": [
+                    "application/json",
+                    "text/xml",
+                    "application/msgpack",
+                    "application/yaml"
+                ],
+                "tags": [
+                    "devices"
+                ],
+                "summary": "Get device by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Device ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Device"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Version of the device, to be sent on If-Match"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "Not Acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Replace every field of an existing device. Name, brand and state are required, id and createdAt are read-only.\nName and brand cannot be changed if device is in use.\nState changes must follow the allowed transitions, listed by /device/{id}/transitions.",
+                "consumes": [
+                    "application/json",
+                    "text/xml",
+                    "application/msgpack",
+                    "application/yaml"
+                ],
+                "produces": [
+                    "application/json",
+                    "text/xml",
+                    "application/msgpack",
+                    "application/yaml"
+                ],
+                "tags": [
+                    "devices"
+                ],
+                "summary": "Replace an existing device",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Device ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the device, the update fails with 412 if it changed",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "description": "New representation of the device",
+                        "name": "device",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.Device"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Device"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Version of the device"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "Not Acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "428": {
+                        "description": "Precondition Required",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Soft delete a device by ID. Devices in use cannot be deleted.",
+                "tags": [
+                    "devices"
+                ],
+                "summary": "Delete a device",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Device ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the device, the deletion fails with 412 if it changed",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "Not Acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "428": {
+                        "description": "Precondition Required",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Apply a JSON Merge Patch (application/merge-patch+json) or a JSON Patch (application/json-patch+json)\nto the device representation. The result must be a valid device, following the same rules as PUT.\nFailed JSON Patch \"test\" operations are rejected with 409.",
+                "consumes": [
+                    "application/merge-patch+json",
+                    "application/json-patch+json"
+                ],
+                "produces": [
+                    "application/json",
+                    "text/xml",
+                    "application/msgpack",
+                    "application/yaml"
+                ],
+                "tags": [
+                    "devices"
+                ],
+                "summary": "Partially update a device",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Device ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the device, the update fails with 412 if it changed",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Merge patch document or list of JSON Patch operations",
+                        "name": "patch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.Device"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Device"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Version of the device"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "Not Acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "428": {
+                        "description": "Precondition Required",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/device/{id}/history": {
+            "get": {
+                "description": "List the changes made to a device, newest first. Deleted devices keep their history.",
+                "produces": [
+                    "application/json",
+                    "text/xml",
+                    "application/msgpack",
+                    "application/yaml"
+                ],
+                "tags": [
+                    "audit"
+                ],
+                "summary": "Get device history",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Device ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "default": 50,
+                        "description": "Maximum number of records",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 0,
+                        "description": "Number of records to skip",
+                        "name": "start",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.AuditList"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "Not Acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/device/{id}/restore": {
+            "post": {
+                "description": "Bring a soft deleted device back. Fails with 409 if a live device took its name and brand. Admins only.",
+                "produces": [
+                    "application/json",
+                    "text/xml",
+                    "application/msgpack",
+                    "application/yaml"
+                ],
+                "tags": [
+                    "devices"
+                ],
+                "summary": "Restore a deleted device",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Device ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Device"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Version of the device"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "Not Acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/device/{id}/transitions": {
+            "get": {
+                "description": "List the states a device can be moved to from its current state.",
+                "produces": [
+                    "application/json",
+                    "text/xml",
+                    "application/msgpack",
+                    "application/yaml"
+                ],
+                "tags": [
+                    "devices"
+                ],
+                "summary": "List allowed state transitions",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Device ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.DeviceTransitions"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "Not Acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "model.AuditList": {
+            "type": "object",
+            "properties": {
+                "hasMore": {
+                    "type": "boolean"
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "records": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.AuditRecord"
+                    }
+                },
+                "start": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.AuditRecord": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string",
+                    "example": "state_change"
+                },
+                "actor": {
+                    "type": "string",
+                    "example": "jane.doe"
+                },
+                "after": {
+                    "$ref": "#/definitions/model.Device"
+                },
+                "before": {
+                    "$ref": "#/definitions/model.Device"
+                },
+                "createdAt": {
+                    "type": "string",
+                    "example": "2023-10-05T14:48:00Z"
+                },
+                "deviceId": {
+                    "type": "string",
+                    "example": "3fa85f64-5717-4562-b3fc-2c963f66afa6"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "requestId": {
+                    "type": "string",
+                    "example": "5d1c6a62-7a43-4e5e-9a0c-4b4d2f5f2c11"
+                }
+            }
+        },
+        "model.BulkChangeResult": {
+            "type": "object",
+            "properties": {
+                "changed": {
+                    "description": "Changed is false when there was nothing to do, e.g. the device was already on the given state",
+                    "type": "boolean"
+                },
+                "device": {
+                    "description": "Device is the device after the change, only set on success",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.Device"
+                        }
+                    ]
+                },
+                "error": {
+                    "$ref": "#/definitions/model.Problem"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "3fa85f64-5717-4562-b3fc-2c963f66afa6"
+                },
+                "status": {
+                    "description": "Status is the status code the device would have got on its own request",
+                    "type": "integer",
+                    "example": 200
+                }
+            }
+        },
+        "model.BulkChangeResults": {
+            "type": "object",
+            "properties": {
+                "changed": {
+                    "type": "integer"
+                },
+                "dryRun": {
+                    "type": "boolean"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.BulkChangeResult"
+                    }
+                },
+                "unchanged": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.BulkResult": {
+            "type": "object",
+            "properties": {
+                "device": {
+                    "description": "Device is the created device, only set on success",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.Device"
+                        }
+                    ]
+                },
+                "error": {
+                    "description": "Error tells why the item failed",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    ]
+                },
+                "index": {
+                    "description": "Index is the position of the item on the request",
+                    "type": "integer",
+                    "example": 0
+                },
+                "status": {
+                    "description": "Status is the status code the item would have got on its own request",
+                    "type": "integer",
+                    "example": 201
+                }
+            }
+        },
+        "model.BulkResults": {
+            "type": "object",
+            "properties": {
+                "created": {
+                    "type": "integer"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.BulkResult"
+                    }
+                }
+            }
+        },
+        "model.BulkSelection": {
+            "type": "object",
+            "properties": {
+                "ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "3fa85f64-5717-4562-b3fc-2c963f66afa6"
+                    ]
+                }
+            }
+        },
+        "model.BulkStateChange": {
+            "type": "object",
+            "properties": {
+                "ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "3fa85f64-5717-4562-b3fc-2c963f66afa6"
+                    ]
+                },
+                "state": {
+                    "type": "string",
+                    "example": "Available"
+                }
+            }
+        },
+        "model.Device": {
+            "type": "object",
+            "properties": {
+                "brand": {
+                    "type": "string"
+                },
                 "createdAt": {
                     "type": "string",
                     "example": "2023-10-05T14:48:00Z"
                 },
+                "deletedAt": {
+                    "description": "Only set on deleted devices",
+                    "type": "string",
+                    "example": "2023-10-06T09:12:00Z"
+                },
+                "deletedBy": {
+                    "type": "string",
+                    "example": "jane.doe"
+                },
                 "id": {
                     "type": "string",
                     "example": "3fa85f64-5717-4562-b3fc-2c963f66afa6"
@@ -300,17 +1642,239 @@ const docTemplate = `{
                         "$ref": "#/definitions/model.Device"
                     }
                 },
+                "hasMore": {
+                    "type": "boolean"
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "next": {
+                    "description": "Next and Prev are the cursors of the following and preceding pages, when there are any",
+                    "type": "string"
+                },
+                "prev": {
+                    "type": "string"
+                },
+                "start": {
+                    "type": "integer"
+                },
                 "total": {
+                    "description": "Total is the number of devices matching the filters, omitted when not counted",
                     "type": "integer"
+                },
+                "totalEstimated": {
+                    "description": "TotalEstimated is set when Total comes from the database statistics instead of an exact count",
+                    "type": "boolean"
+                }
+            }
+        },
+        "model.DeviceTransitions": {
+            "type": "object",
+            "properties": {
+                "allowedStates": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "Available"
+                    ]
+                },
+                "id": {
+                    "type": "string",
+                    "example": "3fa85f64-5717-4562-b3fc-2c963f66afa6"
+                },
+                "state": {
+                    "type": "string",
+                    "example": "Inactive"
                 }
             }
         },
-        "model.RestError": {
+        "model.FieldError": {
             "type": "object",
             "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "invalid_value"
+                },
+                "field": {
+                    "type": "string",
+                    "example": "state"
+                },
                 "message": {
                     "type": "string",
-                    "example": "here is the error message"
+                    "example": "state should be one of: Available, In-Use, Inactive"
+                }
+            }
+        },
+        "model.Highlight": {
+            "type": "object",
+            "properties": {
+                "brand": {
+                    "type": "string",
+                    "example": "Acme"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "\u003cmark\u003eGamma\u003c/mark\u003e Ray"
+                }
+            }
+        },
+        "model.ImportReport": {
+            "type": "object",
+            "properties": {
+                "created": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "failedLine": {
+                    "description": "FailedLine is set when the import stopped because the store failed while writing the row of that line, Error\ntelling why. The counts above cover what was written before, the rows from that line on must be sent again.",
+                    "type": "integer",
+                    "example": 502
+                },
+                "rejected": {
+                    "type": "integer"
+                },
+                "rejectedRows": {
+                    "description": "RejectedRows are listed in the order they were read, only the first ones when RejectedRowsTruncated is set",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.RejectedRow"
+                    }
+                },
+                "rejectedRowsTruncated": {
+                    "type": "boolean"
+                },
+                "unchanged": {
+                    "type": "integer"
+                },
+                "updated": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.Problem": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "device_not_found"
+                },
+                "detail": {
+                    "type": "string",
+                    "example": "no device found with the given ID"
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.FieldError"
+                    }
+                },
+                "instance": {
+                    "type": "string",
+                    "example": "/api/device/3fa85f64-5717-4562-b3fc-2c963f66afa6"
+                },
+                "status": {
+                    "type": "integer",
+                    "example": 404
+                },
+                "title": {
+                    "type": "string",
+                    "example": "Not Found"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "/problems/device_not_found"
+                }
+            }
+        },
+        "model.RejectedRow": {
+            "type": "object",
+            "properties": {
+                "brand": {
+                    "type": "string"
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.FieldError"
+                    }
+                },
+                "line": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "name": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string",
+                    "example": "state should be one of: Available, In-Use, Inactive"
+                },
+                "state": {
+                    "type": "string",
+                    "example": "Broken"
+                }
+            }
+        },
+        "model.SearchResult": {
+            "type": "object",
+            "properties": {
+                "brand": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string",
+                    "example": "2023-10-05T14:48:00Z"
+                },
+                "deletedAt": {
+                    "description": "Only set on deleted devices",
+                    "type": "string",
+                    "example": "2023-10-06T09:12:00Z"
+                },
+                "deletedBy": {
+                    "type": "string",
+                    "example": "jane.doe"
+                },
+                "highlight": {
+                    "$ref": "#/definitions/model.Highlight"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "3fa85f64-5717-4562-b3fc-2c963f66afa6"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "score": {
+                    "type": "number",
+                    "example": 0.57
+                },
+                "state": {
+                    "type": "string",
+                    "example": "Available"
+                }
+            }
+        },
+        "model.SearchResults": {
+            "type": "object",
+            "properties": {
+                "hasMore": {
+                    "type": "boolean"
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.SearchResult"
+                    }
+                },
+                "start": {
+                    "type": "integer"
                 }
             }
         }
@@ -1,21 +1,272 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/lcmps/DevicesAPI/db"
+	"github.com/lcmps/DevicesAPI/model"
 	"github.com/lcmps/DevicesAPI/web"
 )
 
 func main() {
-	database, err := db.New()
+	driver := os.Getenv("STORAGE_DRIVER")
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(driver, os.Args[2:]); err != nil {
+			log.Fatalf("migrate: %v", err)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "seed" {
+		if err := runSeed(driver, os.Args[2:]); err != nil {
+			log.Fatalf("seed: %v", err)
+		}
+		return
+	}
+
+	store, err := newStore(driver)
 	if err != nil {
 		log.Fatalf("failed to initialize database: %v", err)
 	}
 
+	// Seeding is opt-in, meant for demo and test environments (and the only way to get data in memory)
+	if seedFile := os.Getenv("SEED_FILE"); seedFile != "" {
+		if err := seed(store, seedFile); err != nil {
+			log.Fatalf("failed to seed database: %v", err)
+		}
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		log.Fatalf("invalid configuration: %v", err)
+	}
+
+	if err := startPurgeWorker(store); err != nil {
+		log.Fatalf("invalid purge configuration: %v", err)
+	}
+
+	web.New(store, config).Serve()
+}
+
+// loadConfig reads the optional API settings from the environment:
+// - STATE_TRANSITIONS: JSON object with the allowed transitions, e.g. {"Available": ["In-Use"], "In-Use": ["Available"]}
+// - REQUIRE_IF_MATCH: "true" to reject updates and deletions sent without an If-Match header
+// - ADMIN_ACTORS: comma separated actors (X-Actor header) allowed to list and restore deleted devices, nobody when unset
+// - SEARCH_THRESHOLD: similarity devices must reach to be found by a search, between 0 and 1 (default: 0.3)
+func loadConfig() (web.Config, error) {
+	var config web.Config
+
+	if spec := os.Getenv("STATE_TRANSITIONS"); spec != "" {
+		states, err := model.ParseStateMachine(spec)
+		if err != nil {
+			return config, err
+		}
+		config.States = states
+	}
+
+	if value := os.Getenv("REQUIRE_IF_MATCH"); value != "" {
+		required, err := strconv.ParseBool(value)
+		if err != nil {
+			return config, fmt.Errorf("invalid REQUIRE_IF_MATCH %q: %w", value, err)
+		}
+		config.RequireIfMatch = required
+	}
+
+	if admins := os.Getenv("ADMIN_ACTORS"); admins != "" {
+		for _, admin := range strings.Split(admins, ",") {
+			if admin = strings.TrimSpace(admin); admin != "" {
+				config.Admins = append(config.Admins, admin)
+			}
+		}
+	}
+
+	if value := os.Getenv("SEARCH_THRESHOLD"); value != "" {
+		threshold, err := strconv.ParseFloat(value, 64)
+		if err != nil || threshold <= 0 || threshold > 1 {
+			return config, fmt.Errorf("invalid SEARCH_THRESHOLD %q, should be a number greater than 0, up to 1", value)
+		}
+		config.SearchThreshold = threshold
+	}
+
+	return config, nil
+}
+
+// startPurgeWorker starts purging deleted devices in the background when PURGE_RETENTION is set:
+// - PURGE_RETENTION: how long deleted devices are kept, e.g. 720h
+// - PURGE_INTERVAL: how often the purge runs (default: 1h)
+// - PURGE_BATCH_SIZE: devices purged per transaction (default: 100)
+// - PURGE_ARCHIVE: "true" to move purged devices to devices_archive instead of dropping them
+func startPurgeWorker(store db.DeviceStore) error {
+	value := os.Getenv("PURGE_RETENTION")
+	if value == "" {
+		return nil
+	}
+
+	purger, ok := store.(db.Purger)
+	if !ok {
+		return fmt.Errorf("storage driver does not support purging")
+	}
+
+	config := db.PurgeConfig{Interval: time.Hour, BatchSize: 100}
+	retention, err := time.ParseDuration(value)
+	if err != nil || retention < 0 {
+		return fmt.Errorf("invalid PURGE_RETENTION %q, should be a duration such as 720h", value)
+	}
+	config.Retention = retention
+
+	if value := os.Getenv("PURGE_INTERVAL"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil || interval <= 0 {
+			return fmt.Errorf("invalid PURGE_INTERVAL %q, should be a positive duration", value)
+		}
+		config.Interval = interval
+	}
+
+	if value := os.Getenv("PURGE_BATCH_SIZE"); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil || size < 1 {
+			return fmt.Errorf("invalid PURGE_BATCH_SIZE %q, should be a positive integer", value)
+		}
+		config.BatchSize = size
+	}
+
+	if value := os.Getenv("PURGE_ARCHIVE"); value != "" {
+		archive, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid PURGE_ARCHIVE %q: %w", value, err)
+		}
+		config.Archive = archive
+	}
+
+	log.Printf("Purging devices deleted for longer than %s every %s", config.Retention, config.Interval)
+	go db.RunPurgeWorker(context.Background(), purger, config)
+	return nil
+}
+
+// newStore picks the storage backend through the STORAGE_DRIVER variable.
+// Postgres is used when nothing is set, "sqlite" stores everything on the SQLITE_PATH file
+// and "memory" runs the API without any database.
+func newStore(driver string) (db.DeviceStore, error) {
+	if driver == "memory" {
+		log.Println("Using in-memory storage, data will be lost on shutdown")
+		return db.NewMemory(), nil
+	}
+
+	database, err := openDatabase(driver)
+	if err != nil {
+		return nil, err
+	}
 	if err := database.Init(); err != nil {
-		log.Fatalf("failed to run migrations/init: %v", err)
+		return nil, fmt.Errorf("failed to run migrations/init: %w", err)
+	}
+	return database, nil
+}
+
+// openDatabase connects to one of the SQL backends without touching the schema.
+func openDatabase(driver string) (*db.DB, error) {
+	switch driver {
+	case "", "postgres":
+		return db.New()
+	case "sqlite":
+		path := os.Getenv("SQLITE_PATH")
+		if path == "" {
+			path = "devices.db"
+		}
+		return db.NewSQLite(path)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q, should be one of: postgres, sqlite, memory", driver)
+	}
+}
+
+// runMigrate handles the "migrate up|down [steps]|status" command.
+func runMigrate(driver string, args []string) error {
+	if driver == "memory" {
+		return fmt.Errorf("migrations are not available for the memory storage driver")
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("usage: migrate up|down [steps]|status")
+	}
+
+	database, err := openDatabase(driver)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := database.MigrateUp()
+		if err != nil {
+			return err
+		}
+		log.Printf("%d migration(s) applied", applied)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("steps must be a positive integer")
+			}
+		}
+		reverted, err := database.MigrateDown(steps)
+		if err != nil {
+			return err
+		}
+		log.Printf("%d migration(s) reverted", reverted)
+	case "status":
+		status, err := database.MigrationStatus()
+		if err != nil {
+			return err
+		}
+		for _, s := range status {
+			appliedAt := "pending"
+			if s.Applied {
+				appliedAt = "applied at " + s.AppliedAt.Format("2006-01-02T15:04:05Z07:00")
+			}
+			fmt.Printf("%04d_%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %q, usage: migrate up|down [steps]|status", args[0])
+	}
+
+	return nil
+}
+
+// runSeed handles the "seed <fixture file>" command.
+func runSeed(driver string, args []string) error {
+	if driver == "memory" {
+		return fmt.Errorf("the memory storage driver can only be seeded on startup, through SEED_FILE")
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("usage: seed <fixture file>")
 	}
 
-	web.New(database).Serve()
+	store, err := newStore(driver)
+	if err != nil {
+		return err
+	}
+	return seed(store, args[0])
+}
+
+func seed(store db.DeviceStore, path string) error {
+	seeder, ok := store.(db.Seeder)
+	if !ok {
+		return fmt.Errorf("storage driver does not support seeding")
+	}
+
+	// Seeded devices are recorded on the audit trail as created by the seed itself
+	ctx := db.WithAuditInfo(context.Background(), db.AuditInfo{Actor: "seed:" + filepath.Base(path)})
+	created, err := db.SeedFromFile(ctx, seeder, path)
+	if err != nil {
+		return err
+	}
+	log.Printf("%d device(s) seeded from %s", created, path)
+	return nil
 }
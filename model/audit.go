@@ -0,0 +1,64 @@
+package model
+
+import (
+	"encoding/json"
+
+	"github.com/lcmps/DevicesAPI/model/database"
+)
+
+// AuditRecord is a single change made to a device, along with who made it.
+// Before is absent when the device was created.
+type AuditRecord struct {
+	ID        int64   `json:"id" xml:"id" example:"1"`
+	DeviceID  string  `json:"deviceId" xml:"deviceId" example:"3fa85f64-5717-4562-b3fc-2c963f66afa6"`
+	Action    string  `json:"action" xml:"action" example:"state_change"`
+	Actor     string  `json:"actor" xml:"actor" example:"jane.doe"`
+	RequestID string  `json:"requestId,omitempty" xml:"requestId,omitempty" example:"5d1c6a62-7a43-4e5e-9a0c-4b4d2f5f2c11"`
+	Before    *Device `json:"before,omitempty" xml:"before,omitempty"`
+	After     *Device `json:"after" xml:"after"`
+	CreatedAt string  `json:"createdAt" xml:"createdAt" example:"2023-10-05T14:48:00Z"`
+}
+
+func (r *AuditRecord) TranslateToAPI(a database.AuditRecord) {
+	*r = AuditRecord{
+		ID:        a.ID,
+		DeviceID:  a.DeviceID.String(),
+		Action:    a.Action,
+		Actor:     a.Actor,
+		RequestID: a.RequestID,
+		After:     snapshotToAPI(a.After),
+		CreatedAt: a.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+	if a.Before != nil {
+		r.Before = snapshotToAPI(*a.Before)
+	}
+}
+
+// snapshotToAPI translates a device snapshot stored on the audit trail.
+func snapshotToAPI(snapshot string) *Device {
+	var d database.Device
+	if err := json.Unmarshal([]byte(snapshot), &d); err != nil {
+		return nil
+	}
+	var device Device
+	device.TranslateToAPI(d)
+	return &device
+}
+
+// AuditList is a page of the audit trail, HasMore telling whether there are records after it.
+type AuditList struct {
+	Limit   int           `json:"limit" xml:"limit"`
+	Start   int           `json:"start" xml:"start"`
+	HasMore bool          `json:"hasMore" xml:"hasMore"`
+	Records []AuditRecord `json:"records" xml:"records>record"`
+}
+
+func (l *AuditList) TranslateToAPI(a []database.AuditRecord) {
+	l.Records = make([]AuditRecord, 0, len(a))
+
+	for _, a := range a {
+		var record AuditRecord
+		record.TranslateToAPI(a)
+		l.Records = append(l.Records, record)
+	}
+}
@@ -0,0 +1,53 @@
+package model
+
+// BulkResult is the outcome of a single item of a bulk request, listed in the same order they were sent.
+type BulkResult struct {
+	// Index is the position of the item on the request
+	Index int `json:"index" xml:"index" example:"0"`
+	// Status is the status code the item would have got on its own request
+	Status int `json:"status" xml:"status" example:"201"`
+	// Device is the created device, only set on success
+	Device *Device `json:"device,omitempty" xml:"device,omitempty"`
+	// Error tells why the item failed
+	Error *Problem `json:"error,omitempty" xml:"error,omitempty"`
+}
+
+// BulkResults is the response of a bulk request, Created and Failed count its items.
+type BulkResults struct {
+	Created int          `json:"created" xml:"created"`
+	Failed  int          `json:"failed" xml:"failed"`
+	Results []BulkResult `json:"results" xml:"results>result"`
+}
+
+// BulkSelection picks the devices of a bulk change by ID. When empty, they are selected through
+// the same filters as the device list instead.
+type BulkSelection struct {
+	IDs []string `json:"ids" xml:"ids>id" example:"3fa85f64-5717-4562-b3fc-2c963f66afa6"`
+}
+
+// BulkStateChange moves the selected devices to State.
+type BulkStateChange struct {
+	BulkSelection `yaml:",inline"`
+	State         string `json:"state" xml:"state" example:"Available"`
+}
+
+// BulkChangeResult is the outcome of a bulk change on a single device.
+type BulkChangeResult struct {
+	ID string `json:"id" xml:"id" example:"3fa85f64-5717-4562-b3fc-2c963f66afa6"`
+	// Status is the status code the device would have got on its own request
+	Status int `json:"status" xml:"status" example:"200"`
+	// Changed is false when there was nothing to do, e.g. the device was already on the given state
+	Changed bool `json:"changed" xml:"changed"`
+	// Device is the device after the change, only set on success
+	Device *Device  `json:"device,omitempty" xml:"device,omitempty"`
+	Error  *Problem `json:"error,omitempty" xml:"error,omitempty"`
+}
+
+// BulkChangeResults is the response of a bulk change, DryRun telling nothing was actually written.
+type BulkChangeResults struct {
+	DryRun    bool               `json:"dryRun" xml:"dryRun"`
+	Changed   int                `json:"changed" xml:"changed"`
+	Unchanged int                `json:"unchanged" xml:"unchanged"`
+	Failed    int                `json:"failed" xml:"failed"`
+	Results   []BulkChangeResult `json:"results" xml:"results>result"`
+}
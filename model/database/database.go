@@ -13,4 +13,43 @@ type Device struct {
 	Brand     string    `gorm:"type:varchar(250);not null" json:"brand"`
 	CreatedAt time.Time `gorm:"type:timestamptz;not null;default:now()" json:"created_at"`
 	State     string    `gorm:"type:device_state;not null;default:'Available'" json:"state"`
+	// Version is increased on every write, used for optimistic concurrency control
+	Version int64 `gorm:"not null;default:1" json:"version"`
+	// DeletedAt and DeletedBy tell when, and by whom, the device was soft deleted
+	DeletedAt *time.Time `gorm:"type:timestamptz" json:"deleted_at"`
+	DeletedBy string     `gorm:"type:varchar(250);not null;default:''" json:"deleted_by"`
+}
+
+// AuditRecord is an immutable entry of the devices audit trail, written along with every change.
+// Before and After hold JSON snapshots of the device, Before is empty when it was created.
+type AuditRecord struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	DeviceID  uuid.UUID `gorm:"type:uuid;not null" json:"device_id"`
+	Action    string    `gorm:"type:varchar(20);not null" json:"action"`
+	Actor     string    `gorm:"type:varchar(250);not null" json:"actor"`
+	RequestID string    `gorm:"type:varchar(250);not null" json:"request_id"`
+	Before    *string   `gorm:"type:jsonb" json:"before"`
+	After     string    `gorm:"type:jsonb;not null" json:"after"`
+	CreatedAt time.Time `gorm:"type:timestamptz;not null" json:"created_at"`
+}
+
+func (AuditRecord) TableName() string {
+	return "device_audit"
+}
+
+// ArchivedDevice is a soft deleted device moved to the devices_archive table when purged.
+type ArchivedDevice struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	Name       string     `gorm:"type:varchar(250);not null" json:"name"`
+	Brand      string     `gorm:"type:varchar(250);not null" json:"brand"`
+	CreatedAt  time.Time  `gorm:"type:timestamptz;not null" json:"created_at"`
+	State      string     `gorm:"type:device_state;not null" json:"state"`
+	Version    int64      `gorm:"not null" json:"version"`
+	DeletedAt  *time.Time `gorm:"type:timestamptz" json:"deleted_at"`
+	DeletedBy  string     `gorm:"type:varchar(250);not null" json:"deleted_by"`
+	ArchivedAt time.Time  `gorm:"type:timestamptz;not null" json:"archived_at"`
+}
+
+func (ArchivedDevice) TableName() string {
+	return "devices_archive"
 }
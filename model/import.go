@@ -0,0 +1,26 @@
+package model
+
+// ImportReport tells what a CSV import did, along with the rows it rejected and why.
+type ImportReport struct {
+	Created   int `json:"created" xml:"created"`
+	Updated   int `json:"updated" xml:"updated"`
+	Unchanged int `json:"unchanged" xml:"unchanged"`
+	Rejected  int `json:"rejected" xml:"rejected"`
+	// RejectedRows are listed in the order they were read, only the first ones when RejectedRowsTruncated is set
+	RejectedRows          []RejectedRow `json:"rejectedRows" xml:"rejectedRows>row"`
+	RejectedRowsTruncated bool          `json:"rejectedRowsTruncated,omitempty" xml:"rejectedRowsTruncated,omitempty"`
+	// FailedLine is set when the import stopped because the store failed while writing the row of that line, Error
+	// telling why. The counts above cover what was written before, the rows from that line on must be sent again.
+	FailedLine int    `json:"failedLine,omitempty" xml:"failedLine,omitempty" example:"502"`
+	Error      string `json:"error,omitempty" xml:"error,omitempty"`
+}
+
+// RejectedRow is a row of a CSV import that wasn't imported, Line being its line on the file.
+type RejectedRow struct {
+	Line   int          `json:"line" xml:"line" example:"2"`
+	Name   string       `json:"name" xml:"name"`
+	Brand  string       `json:"brand" xml:"brand"`
+	State  string       `json:"state" xml:"state" example:"Broken"`
+	Reason string       `json:"reason" xml:"reason" example:"state should be one of: Available, In-Use, Inactive"`
+	Errors []FieldError `json:"errors,omitempty" xml:"errors>error,omitempty"`
+}
@@ -6,11 +6,14 @@ import (
 )
 
 type Device struct {
-	ID        string `json:"id" example:"3fa85f64-5717-4562-b3fc-2c963f66afa6"`
-	Name      string `json:"name"`
-	Brand     string `json:"brand"`
-	State     string `json:"state" example:"Available"`
-	CreatedAt string `json:"createdAt" example:"2023-10-05T14:48:00Z"`
+	ID        string `json:"id" xml:"id" example:"3fa85f64-5717-4562-b3fc-2c963f66afa6"`
+	Name      string `json:"name" xml:"name"`
+	Brand     string `json:"brand" xml:"brand"`
+	State     string `json:"state" xml:"state" example:"Available"`
+	CreatedAt string `json:"createdAt" xml:"createdAt" example:"2023-10-05T14:48:00Z"`
+	// Only set on deleted devices
+	DeletedAt string `json:"deletedAt,omitempty" xml:"deletedAt,omitempty" example:"2023-10-06T09:12:00Z"`
+	DeletedBy string `json:"deletedBy,omitempty" xml:"deletedBy,omitempty" example:"jane.doe"`
 }
 
 func (dvc *Device) TranslateToAPI(d database.Device) {
@@ -20,6 +23,10 @@ func (dvc *Device) TranslateToAPI(d database.Device) {
 		Brand:     d.Brand,
 		State:     d.State,
 		CreatedAt: d.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		DeletedBy: d.DeletedBy,
+	}
+	if d.DeletedAt != nil {
+		dvc.DeletedAt = d.DeletedAt.Format("2006-01-02T15:04:05Z07:00")
 	}
 }
 
@@ -34,13 +41,21 @@ func (dvc *Device) TranslateToDB() database.Device {
 }
 
 type DeviceList struct {
-	Total   int      `json:"total"`
-	Devices []Device `json:"devices"`
+	// Total is the number of devices matching the filters, omitted when not counted
+	Total *int64 `json:"total,omitempty" xml:"total,omitempty"`
+	// TotalEstimated is set when Total comes from the database statistics instead of an exact count
+	TotalEstimated bool     `json:"totalEstimated,omitempty" xml:"totalEstimated,omitempty"`
+	Limit          int      `json:"limit" xml:"limit"`
+	Start          int      `json:"start" xml:"start"`
+	HasMore        bool     `json:"hasMore" xml:"hasMore"`
+	Devices        []Device `json:"devices" xml:"devices>device"`
+	// Next and Prev are the cursors of the following and preceding pages, when there are any
+	Next string `json:"next,omitempty" xml:"next,omitempty"`
+	Prev string `json:"prev,omitempty" xml:"prev,omitempty"`
 }
 
 func (dvc *DeviceList) TranslateToAPI(d []database.Device) {
-	dvc.Total = len(d)
-
+	dvc.Devices = make([]Device, 0, len(d))
 	for _, d := range d {
 		var device Device
 		device.TranslateToAPI(d)
@@ -48,6 +63,22 @@ func (dvc *DeviceList) TranslateToAPI(d []database.Device) {
 	}
 }
 
-type RestError struct {
-	Message string `json:"message" example:"here is the error message"`
+// Problem is the error body of every failed request, following RFC 7807 (application/problem+json, or
+// application/problem+xml when the client asked for XML).
+// Code is a stable, machine-readable identifier clients can branch on instead of parsing Detail.
+type Problem struct {
+	Type     string       `json:"type" xml:"type" example:"/problems/device_not_found"`
+	Title    string       `json:"title" xml:"title" example:"Not Found"`
+	Status   int          `json:"status" xml:"status" example:"404"`
+	Detail   string       `json:"detail,omitempty" xml:"detail,omitempty" example:"no device found with the given ID"`
+	Instance string       `json:"instance,omitempty" xml:"instance,omitempty" example:"/api/device/3fa85f64-5717-4562-b3fc-2c963f66afa6"`
+	Code     string       `json:"code" xml:"code" example:"device_not_found"`
+	Errors   []FieldError `json:"errors,omitempty" xml:"errors>error,omitempty"`
+}
+
+// FieldError details why a single field of the request was rejected.
+type FieldError struct {
+	Field   string `json:"field" xml:"field" example:"state"`
+	Code    string `json:"code" xml:"code" example:"invalid_value"`
+	Message string `json:"message" xml:"message" example:"state should be one of: Available, In-Use, Inactive"`
 }
@@ -84,9 +84,6 @@ func TestDeviceList_TranslateToAPI(t *testing.T) {
 	var dvcList model.DeviceList
 	dvcList.TranslateToAPI(dbDevices)
 
-	if dvcList.Total != 2 {
-		t.Fatalf("expected Total 2, got %d", dvcList.Total)
-	}
 	if len(dvcList.Devices) != 2 {
 		t.Fatalf("expected 2 devices, got %d", len(dvcList.Devices))
 	}
@@ -0,0 +1,32 @@
+package model
+
+import "github.com/lcmps/DevicesAPI/model/database"
+
+// SearchResult is a device found by a search, along with its score, higher being better: the similarity with
+// the search text, from 0 to 1, on fuzzy searches. Full-text searches also return its name and brand with the
+// matched words wrapped in <mark> tags, HTML escaped.
+type SearchResult struct {
+	Device    `yaml:",inline"`
+	Score     float64    `json:"score" xml:"score" example:"0.57"`
+	Highlight *Highlight `json:"highlight,omitempty" xml:"highlight,omitempty"`
+}
+
+type Highlight struct {
+	Name  string `json:"name" xml:"name" example:"<mark>Gamma</mark> Ray"`
+	Brand string `json:"brand" xml:"brand" example:"Acme"`
+}
+
+func (r *SearchResult) TranslateToAPI(d database.Device, score float64, name, brand string) {
+	r.Device.TranslateToAPI(d)
+	r.Score = score
+	if name != "" || brand != "" {
+		r.Highlight = &Highlight{Name: name, Brand: brand}
+	}
+}
+
+type SearchResults struct {
+	Limit   int            `json:"limit" xml:"limit"`
+	Start   int            `json:"start" xml:"start"`
+	HasMore bool           `json:"hasMore" xml:"hasMore"`
+	Results []SearchResult `json:"results" xml:"results>result"`
+}
@@ -0,0 +1,96 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+)
+
+// Device states, matching the device_state ENUM on the database
+const (
+	StateAvailable = "Available"
+	StateInUse     = "In-Use"
+	StateInactive  = "Inactive"
+)
+
+// States lists every valid device state.
+var States = []string{StateAvailable, StateInUse, StateInactive}
+
+func IsValidState(state string) bool {
+	return slices.Contains(States, state)
+}
+
+// DefaultTransitions is the lifecycle used when none is configured:
+// a device must go back to Available before being used again or retired,
+// e.g. Inactive cannot jump straight to In-Use.
+func DefaultTransitions() map[string][]string {
+	return map[string][]string{
+		StateAvailable: {StateInUse, StateInactive},
+		StateInUse:     {StateAvailable},
+		StateInactive:  {StateAvailable},
+	}
+}
+
+// StateMachine holds the allowed transitions between device states.
+type StateMachine struct {
+	transitions map[string][]string
+}
+
+// NewStateMachine validates the given transitions (current state -> allowed next states).
+// States missing from the map can't transition anywhere.
+func NewStateMachine(transitions map[string][]string) (*StateMachine, error) {
+	for from, targets := range transitions {
+		if !IsValidState(from) {
+			return nil, fmt.Errorf("invalid state %q on transitions", from)
+		}
+		for _, to := range targets {
+			if !IsValidState(to) {
+				return nil, fmt.Errorf("invalid state %q on transitions from %q", to, from)
+			}
+		}
+	}
+	return &StateMachine{transitions: transitions}, nil
+}
+
+// DefaultStateMachine returns a StateMachine using DefaultTransitions.
+func DefaultStateMachine() *StateMachine {
+	return &StateMachine{transitions: DefaultTransitions()}
+}
+
+// ParseStateMachine builds a StateMachine from its JSON representation, e.g.
+// {"Available": ["In-Use", "Inactive"], "In-Use": ["Available"], "Inactive": ["Available"]}
+func ParseStateMachine(spec string) (*StateMachine, error) {
+	var transitions map[string][]string
+	if err := json.Unmarshal([]byte(spec), &transitions); err != nil {
+		return nil, fmt.Errorf("failed to parse state transitions: %w", err)
+	}
+	return NewStateMachine(transitions)
+}
+
+// CanTransition reports whether a device can go from one state to another.
+// Staying on the same state is always allowed.
+func (sm *StateMachine) CanTransition(from, to string) bool {
+	if from == to {
+		return IsValidState(to)
+	}
+	return slices.Contains(sm.transitions[from], to)
+}
+
+// AllowedTransitions lists the states a device can go to from its current state.
+func (sm *StateMachine) AllowedTransitions(from string) []string {
+	allowed := []string{}
+	// Following the order of States, so responses are stable
+	for _, state := range States {
+		if state != from && sm.CanTransition(from, state) {
+			allowed = append(allowed, state)
+		}
+	}
+	return allowed
+}
+
+// DeviceTransitions is the response of the transitions endpoint.
+type DeviceTransitions struct {
+	ID            string   `json:"id" xml:"id" example:"3fa85f64-5717-4562-b3fc-2c963f66afa6"`
+	State         string   `json:"state" xml:"state" example:"Inactive"`
+	AllowedStates []string `json:"allowedStates" xml:"allowedStates>state" example:"Available"`
+}
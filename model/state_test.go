@@ -0,0 +1,61 @@
+package model_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/lcmps/DevicesAPI/model"
+)
+
+func TestStateMachine_Default(t *testing.T) {
+	sm := model.DefaultStateMachine()
+
+	cases := []struct {
+		from, to string
+		want     bool
+	}{
+		{model.StateAvailable, model.StateInUse, true},
+		{model.StateAvailable, model.StateInactive, true},
+		{model.StateInUse, model.StateAvailable, true},
+		{model.StateInUse, model.StateInactive, false},
+		{model.StateInactive, model.StateAvailable, true},
+		{model.StateInactive, model.StateInUse, false},
+		{model.StateInactive, model.StateInactive, true},
+		{model.StateAvailable, "Broken", false},
+	}
+
+	for _, tc := range cases {
+		if got := sm.CanTransition(tc.from, tc.to); got != tc.want {
+			t.Fatalf("expected %s -> %s to be %v, got %v", tc.from, tc.to, tc.want, got)
+		}
+	}
+
+	if got := sm.AllowedTransitions(model.StateAvailable); !reflect.DeepEqual(got, []string{model.StateInUse, model.StateInactive}) {
+		t.Fatalf("unexpected transitions from Available: %v", got)
+	}
+	if got := sm.AllowedTransitions(model.StateInactive); !reflect.DeepEqual(got, []string{model.StateAvailable}) {
+		t.Fatalf("unexpected transitions from Inactive: %v", got)
+	}
+}
+
+func TestParseStateMachine(t *testing.T) {
+	sm, err := model.ParseStateMachine(`{"Inactive": ["In-Use"], "In-Use": ["Inactive"]}`)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if !sm.CanTransition(model.StateInactive, model.StateInUse) {
+		t.Fatal("expected configured transition to be allowed")
+	}
+	if sm.CanTransition(model.StateInactive, model.StateAvailable) {
+		t.Fatal("expected transition missing from the configuration to be refused")
+	}
+	if got := sm.AllowedTransitions(model.StateAvailable); len(got) != 0 {
+		t.Fatalf("expected no transitions from Available, got %v", got)
+	}
+
+	for _, spec := range []string{`not json`, `{"Broken": ["Available"]}`, `{"Available": ["Broken"]}`} {
+		if _, err := model.ParseStateMachine(spec); err == nil {
+			t.Fatalf("expected error for %s, got nil", spec)
+		}
+	}
+}
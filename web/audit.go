@@ -0,0 +1,153 @@
+package web
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lcmps/DevicesAPI/db"
+	"github.com/lcmps/DevicesAPI/model"
+)
+
+// Headers identifying who made a request and the request itself, both recorded on the audit trail
+const (
+	actorHeader     = "X-Actor"
+	requestIDHeader = "X-Request-ID"
+)
+
+// Actor recorded for requests that don't say who made them
+const anonymousActor = "anonymous"
+
+// Longest actor and request ID kept, matching their columns on the audit table
+const maxAuditInfoLength = 250
+
+// auditInfo passes the actor and request ID of every request down to the store, through its context.
+// A request ID is generated when the client doesn't send one, and it's always sent back.
+func auditInfo() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		actor := ctx.GetHeader(actorHeader)
+		if actor == "" {
+			actor = anonymousActor
+		}
+		requestID := ctx.GetHeader(requestIDHeader)
+		if requestID == "" {
+			requestID = uuid.NewString()
+		}
+		ctx.Header(requestIDHeader, requestID)
+
+		info := db.AuditInfo{Actor: truncate(actor, maxAuditInfoLength), RequestID: truncate(requestID, maxAuditInfoLength)}
+		ctx.Request = ctx.Request.WithContext(db.WithAuditInfo(ctx.Request.Context(), info))
+		ctx.Next()
+	}
+}
+
+// truncate cuts value to at most length bytes without splitting a character, dropping any invalid UTF-8
+// beforehand, which Postgres would refuse to store.
+func truncate(value string, length int) string {
+	value = strings.ToValidUTF8(value, "")
+	if len(value) <= length {
+		return value
+	}
+	for length > 0 && !utf8.RuneStart(value[length]) {
+		length--
+	}
+	return value[:length]
+}
+
+// @Summary      Get device history
+// @Description  List the changes made to a device, newest first. Deleted devices keep their history.
+// @Tags         audit
+// @Produce      json,xml,application/msgpack,application/yaml
+// @Param        id     path      string  true   "Device ID"
+// @Param        limit  query     int     false  "Maximum number of records"  default(50)
+// @Param        start  query     int     false  "Number of records to skip"  default(0)
+// @Success      200    {object}  model.AuditList
+// @Failure      400    {object}  model.Problem
+// @Failure      406    {object}  model.Problem
+// @Failure      500    {object}  model.Problem
+// @Router       /device/{id}/history [get]
+func (w *Web) getDeviceHistory(ctx *gin.Context) {
+	limit, start, ok := pagination(ctx)
+	if !ok {
+		return
+	}
+
+	query := db.AuditQuery{DeviceID: ctx.Param("id"), Limit: limit, Offset: start}
+	w.respondAudit(ctx, query)
+}
+
+// @Summary      Query the audit trail
+// @Description  List the changes made to every device, newest first.
+// @Tags         audit
+// @Produce      json,xml,application/msgpack,application/yaml
+// @Param        action  query     string  false  "Action"  Enums(create, update, state_change, delete, restore)
+// @Param        from    query     string  false  "Only changes made at or after this time (RFC 3339)"
+// @Param        to      query     string  false  "Only changes made before this time (RFC 3339)"
+// @Param        limit   query     int     false  "Maximum number of records"  default(50)
+// @Param        start   query     int     false  "Number of records to skip"  default(0)
+// @Success      200     {object}  model.AuditList
+// @Failure      400     {object}  model.Problem
+// @Failure      406     {object}  model.Problem
+// @Failure      500     {object}  model.Problem
+// @Router       /audit [get]
+func (w *Web) getAuditRecords(ctx *gin.Context) {
+	limit, start, ok := pagination(ctx)
+	if !ok {
+		return
+	}
+
+	query := db.AuditQuery{Action: ctx.Query("action"), Limit: limit, Offset: start}
+	if query.Action != "" && !slices.Contains(db.AuditActions, query.Action) {
+		message := "action should be one of: " + strings.Join(db.AuditActions, ", ")
+		respondProblem(ctx, http.StatusBadRequest, codeInvalidQuery, message,
+			model.FieldError{Field: "action", Code: fieldCodeInvalid, Message: message})
+		return
+	}
+
+	if query.From, ok = timeQuery(ctx, "from"); !ok {
+		return
+	}
+	if query.To, ok = timeQuery(ctx, "to"); !ok {
+		return
+	}
+
+	w.respondAudit(ctx, query)
+}
+
+// respondAudit writes the page of the audit trail asked for by the query.
+func (w *Web) respondAudit(ctx *gin.Context, query db.AuditQuery) {
+	limit := query.Limit
+	// One more record than asked for tells whether there's another page
+	query.Limit++
+	records, err := w.DB.GetAuditRecords(ctx.Request.Context(), query)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	list := model.AuditList{Limit: limit, Start: query.Offset, HasMore: len(records) > limit}
+	list.TranslateToAPI(records[:min(limit, len(records))])
+
+	respond(ctx, http.StatusOK, list)
+}
+
+// timeQuery reads an optional RFC 3339 timestamp from the query, responding with 400 when it is invalid.
+func timeQuery(ctx *gin.Context, field string) (time.Time, bool) {
+	value := ctx.Query(field)
+	if value == "" {
+		return time.Time{}, true
+	}
+
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		message := field + " must be an RFC 3339 timestamp, e.g. 2023-10-05T14:48:00Z"
+		respondProblem(ctx, http.StatusBadRequest, codeInvalidQuery, message,
+			model.FieldError{Field: field, Code: fieldCodeInvalid, Message: message})
+		return time.Time{}, false
+	}
+	return parsed, true
+}
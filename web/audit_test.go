@@ -0,0 +1,169 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/lcmps/DevicesAPI/db"
+	"github.com/lcmps/DevicesAPI/model"
+)
+
+func TestAuditTrail_Memory(t *testing.T) {
+	w := newTestWeb(db.NewMemory())
+
+	// The actor and request ID sent by the client end up on the audit trail
+	req, _ := http.NewRequest(http.MethodPost, "/api/device/", strings.NewReader(`{"name": "Alpha", "brand": "BrandA", "state": "Available"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(actorHeader, "jane")
+	req.Header.Set(requestIDHeader, "req-1")
+	rec := httptest.NewRecorder()
+	w.Router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, rec.Code)
+	}
+	assert.Equal(t, "req-1", rec.Header().Get(requestIDHeader))
+	var created model.Device
+	_ = json.Unmarshal(rec.Body.Bytes(), &created)
+
+	rec = doRequest(w, http.MethodPut, "/api/device/"+created.ID, model.Device{Name: "Alpha", Brand: "BrandA", State: "In-Use"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	// A request ID is generated when none is sent
+	if rec.Header().Get(requestIDHeader) == "" {
+		t.Fatal("expected a generated request ID")
+	}
+
+	rec = doRequest(w, http.MethodGet, "/api/device/"+created.ID+"/history", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	var history model.AuditList
+	_ = json.Unmarshal(rec.Body.Bytes(), &history)
+	if len(history.Records) != 2 || history.HasMore {
+		t.Fatalf("expected 2 records, got %+v", history)
+	}
+	change := history.Records[0]
+	assert.Equal(t, db.AuditStateChange, change.Action)
+	assert.Equal(t, anonymousActor, change.Actor)
+	assert.Equal(t, "Available", change.Before.State)
+	assert.Equal(t, "In-Use", change.After.State)
+	assert.Equal(t, "jane", history.Records[1].Actor)
+	assert.Equal(t, "req-1", history.Records[1].RequestID)
+
+	rec = doRequest(w, http.MethodGet, "/api/audit?action=create", nil)
+	var list model.AuditList
+	_ = json.Unmarshal(rec.Body.Bytes(), &list)
+	if len(list.Records) != 1 || list.Records[0].DeviceID != created.ID {
+		t.Fatalf("expected the creation record, got %+v", list)
+	}
+
+	// Pages tell whether there are records after them, not how many match
+	rec = doRequest(w, http.MethodGet, "/api/audit?limit=1", nil)
+	list = model.AuditList{}
+	_ = json.Unmarshal(rec.Body.Bytes(), &list)
+	if len(list.Records) != 1 || !list.HasMore || list.Limit != 1 || list.Start != 0 {
+		t.Fatalf("expected a page of a single record with more after it, got %+v", list)
+	}
+	rec = doRequest(w, http.MethodGet, "/api/audit?limit=1&start=1", nil)
+	list = model.AuditList{}
+	_ = json.Unmarshal(rec.Body.Bytes(), &list)
+	if len(list.Records) != 1 || list.HasMore || list.Start != 1 {
+		t.Fatalf("expected the last page, got %+v", list)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	cases := []struct {
+		name, value string
+		length      int
+		want        string
+	}{
+		{"Short", "jane", 10, "jane"},
+		{"ASCII", "janedoe", 4, "jane"},
+		{"RuneBoundary", "ééé", 4, "éé"},
+		{"MidRune", "ééé", 5, "éé"},
+		{"InvalidUTF8", "ja\xffne", 10, "jane"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, truncate(tc.value, tc.length))
+		})
+	}
+}
+
+func TestAuditTrail_MultiByteHeaders(t *testing.T) {
+	w := newTestWeb(db.NewMemory())
+
+	// An odd number of bytes before the multi-byte characters, so the limit falls in the middle of one
+	actor := "x" + strings.Repeat("é", maxAuditInfoLength)
+	req, _ := http.NewRequest(http.MethodPost, "/api/device/", strings.NewReader(`{"name": "Alpha", "brand": "BrandA", "state": "Available"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(actorHeader, actor)
+	req.Header.Set(requestIDHeader, "req-\xff1")
+	rec := httptest.NewRecorder()
+	w.Router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, rec.Code)
+	}
+
+	rec = doRequest(w, http.MethodGet, "/api/audit", nil)
+	var list model.AuditList
+	_ = json.Unmarshal(rec.Body.Bytes(), &list)
+	if len(list.Records) != 1 {
+		t.Fatalf("expected the creation record, got %+v", list)
+	}
+	record := list.Records[0]
+	if !utf8.ValidString(record.Actor) || len(record.Actor) > maxAuditInfoLength || !strings.HasPrefix(actor, record.Actor) {
+		t.Fatalf("expected the actor cut on a character boundary, got %q", record.Actor)
+	}
+	assert.Equal(t, maxAuditInfoLength-1, len(record.Actor))
+	assert.Equal(t, "req-1", record.RequestID)
+}
+
+func TestGetAuditRecords(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		auditErr   error
+		wantStatus int
+		wantCode   string
+	}{
+		{"All", "", nil, http.StatusOK, ""},
+		{"Filtered", "?action=delete&from=2023-10-05T14:48:00Z&to=2023-10-06T00:00:00-03:00", nil, http.StatusOK, ""},
+		{"Invalid action", "?action=purge", nil, http.StatusBadRequest, codeInvalidQuery},
+		{"Invalid from", "?from=yesterday", nil, http.StatusBadRequest, codeInvalidQuery},
+		{"Invalid limit", "?limit=ten", nil, http.StatusBadRequest, codeInvalidQuery},
+		{"Negative start", "?start=-5", nil, http.StatusBadRequest, codeInvalidQuery},
+		{"Store error", "", errors.New("boom"), http.StatusInternalServerError, codeInternal},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			store := seededStore()
+			store.auditErr = tc.auditErr
+			rec := doRequest(newTestWeb(store), http.MethodGet, "/api/audit"+tc.query, nil)
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("expected status %d, got %d", tc.wantStatus, rec.Code)
+			}
+			if tc.wantCode != "" {
+				var resp model.Problem
+				_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+				assert.Equal(t, tc.wantCode, resp.Code)
+			}
+		})
+	}
+}
+
+func TestGetDeviceHistory_InvalidID(t *testing.T) {
+	rec := doRequest(newTestWeb(db.NewMemory()), http.MethodGet, "/api/device/not-a-uuid/history", nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
@@ -0,0 +1,371 @@
+package web
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lcmps/DevicesAPI/db"
+	"github.com/lcmps/DevicesAPI/model"
+	"github.com/lcmps/DevicesAPI/model/database"
+)
+
+// maxBulkSize is the maximum number of items of a bulk request.
+const maxBulkSize = 1000
+
+// Values of the mode query parameter of bulk requests
+const (
+	bulkModeAtomic     = "atomic"
+	bulkModeBestEffort = "best-effort"
+)
+
+// bulkMode reads the mode query parameter, rejecting the request when it is invalid.
+func bulkMode(ctx *gin.Context) (db.BulkMode, bool) {
+	switch ctx.DefaultQuery("mode", bulkModeAtomic) {
+	case bulkModeAtomic:
+		return db.BulkAtomic, true
+	case bulkModeBestEffort:
+		return db.BulkBestEffort, true
+	default:
+		message := "mode must be one of: atomic, best-effort"
+		respondProblem(ctx, http.StatusBadRequest, codeInvalidQuery, message,
+			model.FieldError{Field: "mode", Code: fieldCodeInvalid, Message: message})
+		return 0, false
+	}
+}
+
+// deviceBatch is the body of a bulk creation. Its XML representation lists the devices as device elements,
+// within a root element of any name, e.g. devices.
+type deviceBatch []model.Device
+
+func (b *deviceBatch) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var batch struct {
+		Devices []model.Device `xml:"device"`
+	}
+	if err := d.DecodeElement(&batch, &start); err != nil {
+		return err
+	}
+	*b = batch.Devices
+	return nil
+}
+
+// respondBulk writes the results of a bulk request, with 201 when every item succeeded and 207 otherwise.
+func respondBulk(ctx *gin.Context, results model.BulkResults) {
+	status := http.StatusCreated
+	if results.Failed > 0 {
+		status = http.StatusMultiStatus
+	}
+	respond(ctx, status, results)
+}
+
+// createDevices
+// accepts the following query parameters:
+// - mode: atomic (default), creating every device or none of them, or best-effort, creating the valid ones
+//
+// @Summary      Create several devices
+// @Description  Create up to 1000 devices at once, following the same rules as POST /device.
+// @Description  Each device gets its own result, in the order they were sent, with the status code it would have got
+// @Description  on its own request along with the created device or the problem found. The response is 201 when
+// @Description  every device was created and 207 otherwise. Atomic requests create either every device or none of
+// @Description  them, reporting the ones that didn't fail with 424. Devices are only checked for conflicts once
+// @Description  every device of an atomic request is valid.
+// @Tags         devices
+// @Accept       json,xml,application/msgpack,application/yaml
+// @Produce      json,xml,application/msgpack,application/yaml
+// @Param        mode     query     string          false  "atomic (default) or best-effort"
+// @Param        devices  body      []model.Device  true   "Devices to create"
+// @Success      201      {object}  model.BulkResults
+// @Success      207      {object}  model.BulkResults
+// @Failure      400      {object}  model.Problem
+// @Failure      406      {object}  model.Problem
+// @Failure      415      {object}  model.Problem
+// @Failure      500      {object}  model.Problem
+// @Router       /device/bulk [post]
+func (w *Web) createDevices(ctx *gin.Context) {
+	mode, ok := bulkMode(ctx)
+	if !ok {
+		return
+	}
+
+	var requestBody deviceBatch
+	if !bindBody(ctx, &requestBody) {
+		return
+	}
+	if len(requestBody) == 0 || len(requestBody) > maxBulkSize {
+		respondProblem(ctx, http.StatusBadRequest, codeInvalidBody, fmt.Sprintf("between 1 and %d devices must be sent", maxBulkSize))
+		return
+	}
+
+	results := model.BulkResults{Results: make([]model.BulkResult, len(requestBody))}
+	// devices are the ones to create, valid their position on the request
+	var devices []database.Device
+	var valid []int
+	for i, device := range requestBody {
+		results.Results[i].Index = i
+		if detail, fieldErrors := validateNewDevice(device); detail != "" {
+			problem := newProblem(ctx, http.StatusBadRequest, codeValidation, detail, fieldErrors...)
+			results.Results[i].Status = problem.Status
+			results.Results[i].Error = &problem
+			continue
+		}
+		newDevice := model.Device{Name: device.Name, Brand: device.Brand, State: device.State}
+		devices = append(devices, newDevice.TranslateToDB())
+		valid = append(valid, i)
+	}
+
+	errs := make([]error, len(devices))
+	if mode == db.BulkAtomic && len(valid) < len(requestBody) {
+		// Nothing is created when a device is invalid, no need to ask the store
+		for i := range errs {
+			errs[i] = db.ErrBulkAborted
+		}
+	} else if len(devices) > 0 {
+		var err error
+		if errs, err = w.DB.CreateDevices(ctx.Request.Context(), devices, mode); err != nil {
+			respondError(ctx, err)
+			return
+		}
+	}
+
+	for i, err := range errs {
+		result := &results.Results[valid[i]]
+		if err != nil {
+			status, code := errorStatus(err)
+			problem := newProblem(ctx, status, code, err.Error())
+			result.Status = status
+			result.Error = &problem
+			continue
+		}
+		var dvc model.Device
+		dvc.TranslateToAPI(devices[i])
+		result.Status = http.StatusCreated
+		result.Device = &dvc
+	}
+
+	for _, result := range results.Results {
+		if result.Error != nil {
+			results.Failed++
+		} else {
+			results.Created++
+		}
+	}
+	respondBulk(ctx, results)
+}
+
+// bulkChange reads what a bulk change applies to: the given IDs, or the devices matching the filters of
+// the device list (brand, state, name, createdAfter, createdBefore and filter), along with its mode and
+// whether it is a dry run. It responds with 400 and returns false when the request is invalid.
+func (w *Web) bulkChange(ctx *gin.Context, selection model.BulkSelection) (db.BulkChange, bool) {
+	var change db.BulkChange
+	mode, ok := bulkMode(ctx)
+	if !ok {
+		return change, false
+	}
+	change.Mode = mode
+
+	if value := ctx.Query("dryRun"); value != "" {
+		dryRun, err := strconv.ParseBool(value)
+		if err != nil {
+			message := "dryRun must be true or false"
+			respondProblem(ctx, http.StatusBadRequest, codeInvalidQuery, message,
+				model.FieldError{Field: "dryRun", Code: fieldCodeInvalid, Message: message})
+			return change, false
+		}
+		change.DryRun = dryRun
+	}
+
+	filter, ok := listFilter(ctx)
+	if !ok {
+		return change, false
+	}
+	name := ctx.Query("name")
+	filtered := filter != nil || name != ""
+
+	switch {
+	case len(selection.IDs) > 0 && filtered:
+		respondProblem(ctx, http.StatusBadRequest, codeInvalidBody, "devices must be selected either by ids or by filters, not both")
+		return change, false
+	case len(selection.IDs) > 0:
+		if len(selection.IDs) > maxBulkSize {
+			respondProblem(ctx, http.StatusBadRequest, codeInvalidBody, fmt.Sprintf("at most %d devices can be changed at once", maxBulkSize))
+			return change, false
+		}
+		for i, id := range selection.IDs {
+			if slices.Contains(selection.IDs[:i], id) {
+				message := "ids must not be repeated"
+				respondValidation(ctx, message, model.FieldError{Field: "ids", Code: fieldCodeInvalid, Message: message})
+				return change, false
+			}
+		}
+		change.IDs = selection.IDs
+	case filtered:
+		// Refusing the change upfront, instead of silently leaving the extra devices aside
+		change.Query = db.DeviceQuery{Name: name, Filter: filter, Limit: maxBulkSize}
+		count, _, err := w.DB.CountDevices(ctx.Request.Context(), change.Query, db.CountExact)
+		if err != nil {
+			respondError(ctx, err)
+			return change, false
+		}
+		if count > maxBulkSize {
+			respondProblem(ctx, http.StatusBadRequest, codeInvalidQuery,
+				fmt.Sprintf("filters match %d devices, at most %d can be changed at once", count, maxBulkSize))
+			return change, false
+		}
+	default:
+		respondProblem(ctx, http.StatusBadRequest, codeInvalidBody, "devices must be selected either by ids or by filters")
+		return change, false
+	}
+	return change, true
+}
+
+// applyBulkChange applies the change and writes the outcome of every device, with 200 when none
+// of them failed and 207 otherwise.
+func (w *Web) applyBulkChange(ctx *gin.Context, change db.BulkChange) {
+	outcomes, err := w.DB.ChangeDevices(ctx.Request.Context(), change)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	results := model.BulkChangeResults{DryRun: change.DryRun, Results: make([]model.BulkChangeResult, 0, len(outcomes))}
+	for _, outcome := range outcomes {
+		result := model.BulkChangeResult{ID: outcome.ID, Status: http.StatusOK, Changed: outcome.Changed}
+		switch {
+		case outcome.Err != nil:
+			status, code := errorStatus(outcome.Err)
+			problem := newProblem(ctx, status, code, outcome.Err.Error())
+			result.Status, result.Changed, result.Error = status, false, &problem
+			results.Failed++
+		case outcome.Changed:
+			results.Changed++
+		default:
+			results.Unchanged++
+		}
+		if outcome.Err == nil {
+			var dvc model.Device
+			dvc.TranslateToAPI(outcome.Device)
+			result.Device = &dvc
+		}
+		results.Results = append(results.Results, result)
+	}
+
+	status := http.StatusOK
+	if results.Failed > 0 {
+		status = http.StatusMultiStatus
+	}
+	respond(ctx, status, results)
+}
+
+// changeDevicesState
+// accepts the following query parameters:
+// - mode: atomic (default), changing every device or none of them, or best-effort, changing the ones it can
+// - dryRun: true to only report what would change
+// - brand, state, name, createdAfter, createdBefore and filter: select the devices as the device list does,
+// when no ids are given
+//
+// @Summary      Change the state of several devices
+// @Description  Move up to 1000 devices to the given state, following the allowed transitions of each one. Devices
+// @Description  are selected by ids, or through the same filters as GET /device when none is given, and changed
+// @Description  within a single transaction. Each device gets its own result, with the status code it would have got
+// @Description  on its own request along with the device or the problem found. The response is 200 when no device
+// @Description  failed and 207 otherwise. Atomic requests change either every device or none of them, reporting the
+// @Description  ones that didn't fail with 424. Dry runs report the same results without changing anything.
+// @Tags         devices
+// @Accept       json,xml,application/msgpack,application/yaml
+// @Produce      json,xml,application/msgpack,application/yaml
+// @Param        mode           query     string                 false  "atomic (default) or best-effort"
+// @Param        dryRun         query     bool                   false  "Only report what would change"
+// @Param        brand          query     string                 false  "Brand of the devices to change, when no ids are given"
+// @Param        state          query     string                 false  "Current state of the devices to change, when no ids are given"
+// @Param        name           query     string                 false  "Partial name of the devices to change, when no ids are given"
+// @Param        createdAfter   query     string                 false  "Only change devices created at or after this RFC 3339 time"
+// @Param        createdBefore  query     string                 false  "Only change devices created before this RFC 3339 time"
+// @Param        filter         query     string                 false  "Filter expression, as on GET /device"
+// @Param        change         body      model.BulkStateChange  true   "Devices to change and their new state"
+// @Success      200            {object}  model.BulkChangeResults
+// @Success      207            {object}  model.BulkChangeResults
+// @Failure      400            {object}  model.Problem
+// @Failure      406            {object}  model.Problem
+// @Failure      415            {object}  model.Problem
+// @Failure      500            {object}  model.Problem
+// @Router       /device/bulk/state [post]
+func (w *Web) changeDevicesState(ctx *gin.Context) {
+	var requestBody model.BulkStateChange
+	if !bindBody(ctx, &requestBody) {
+		return
+	}
+	if requestBody.State == "" {
+		respondValidation(ctx, "state is required", model.FieldError{Field: "state", Code: fieldCodeRequired, Message: "state is required"})
+		return
+	}
+	if !isValidState(requestBody.State) {
+		respondValidation(ctx, invalidStateMessage, invalidStateError)
+		return
+	}
+
+	change, ok := w.bulkChange(ctx, requestBody.BulkSelection)
+	if !ok {
+		return
+	}
+	change.State = requestBody.State
+	change.Check = func(device database.Device) error {
+		return w.checkTransition(device, requestBody.State)
+	}
+
+	w.applyBulkChange(ctx, change)
+}
+
+// deleteDevices
+// accepts the same query parameters as changeDevicesState.
+//
+// @Summary      Delete several devices
+// @Description  Delete up to 1000 devices, devices in use being refused. Devices are selected by ids, or through
+// @Description  the same filters as GET /device when none is given, and deleted within a single transaction.
+// @Description  Each device gets its own result, with the status code it would have got on its own request along
+// @Description  with the deleted device or the problem found. The response is 200 when no device failed and 207
+// @Description  otherwise. Atomic requests delete either every device or none of them, reporting the ones that
+// @Description  didn't fail with 424. Dry runs report the same results without deleting anything.
+// @Tags         devices
+// @Accept       json,xml,application/msgpack,application/yaml
+// @Produce      json,xml,application/msgpack,application/yaml
+// @Param        mode           query     string               false  "atomic (default) or best-effort"
+// @Param        dryRun         query     bool                 false  "Only report what would be deleted"
+// @Param        brand          query     string               false  "Brand of the devices to delete, when no ids are given"
+// @Param        state          query     string               false  "State of the devices to delete, when no ids are given"
+// @Param        name           query     string               false  "Partial name of the devices to delete, when no ids are given"
+// @Param        createdAfter   query     string               false  "Only delete devices created at or after this RFC 3339 time"
+// @Param        createdBefore  query     string               false  "Only delete devices created before this RFC 3339 time"
+// @Param        filter         query     string               false  "Filter expression, as on GET /device"
+// @Param        selection      body      model.BulkSelection  false  "Devices to delete, filters are used when omitted"
+// @Success      200            {object}  model.BulkChangeResults
+// @Success      207            {object}  model.BulkChangeResults
+// @Failure      400            {object}  model.Problem
+// @Failure      406            {object}  model.Problem
+// @Failure      415            {object}  model.Problem
+// @Failure      500            {object}  model.Problem
+// @Router       /device/bulk/delete [post]
+func (w *Web) deleteDevices(ctx *gin.Context) {
+	// The body is optional when selecting devices through filters
+	var requestBody model.BulkSelection
+	b, ok := bodyBinding(ctx)
+	if !ok {
+		return
+	}
+	if err := ctx.ShouldBindWith(&requestBody, b); err != nil && !errors.Is(err, io.EOF) {
+		respondProblem(ctx, http.StatusBadRequest, codeInvalidBody, err.Error())
+		return
+	}
+
+	change, ok := w.bulkChange(ctx, requestBody)
+	if !ok {
+		return
+	}
+	change.Delete = true
+
+	w.applyBulkChange(ctx, change)
+}
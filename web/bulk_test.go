@@ -0,0 +1,293 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/google/uuid"
+	"github.com/lcmps/DevicesAPI/db"
+	"github.com/lcmps/DevicesAPI/model"
+	"github.com/lcmps/DevicesAPI/model/database"
+)
+
+func TestCreateDevices(t *testing.T) {
+	devices := []model.Device{
+		{Name: "Delta", Brand: "BrandD", State: "Available"},
+		{Name: "Alpha", Brand: "BrandA", State: "Available"},
+		{Name: "Epsilon", Brand: "BrandE", State: "Broken"},
+		{Name: "Zeta", Brand: "BrandZ", State: "In-Use"},
+	}
+
+	cases := []struct {
+		name         string
+		query        string
+		body         interface{}
+		wantStatus   int
+		wantStatuses []int
+		wantDevices  int
+	}{
+		{"AllCreated", "", []model.Device{devices[0], devices[3]}, http.StatusCreated, []int{201, 201}, 5},
+		{"AtomicInvalid", "", devices, http.StatusMultiStatus, []int{424, 424, 400, 424}, 3},
+		{"AtomicConflict", "?mode=atomic", []model.Device{devices[0], devices[1]}, http.StatusMultiStatus, []int{424, 409}, 3},
+		{"BestEffort", "?mode=best-effort", devices, http.StatusMultiStatus, []int{201, 409, 400, 201}, 5},
+		{"DuplicateWithinRequest", "?mode=best-effort", []model.Device{devices[0], devices[0]}, http.StatusMultiStatus, []int{201, 409}, 4},
+		{"InvalidMode", "?mode=partial", devices, http.StatusBadRequest, nil, 3},
+		{"Empty", "", []model.Device{}, http.StatusBadRequest, nil, 3},
+		{"NotAList", "", devices[0], http.StatusBadRequest, nil, 3},
+		{"TooMany", "", make([]model.Device, maxBulkSize+1), http.StatusBadRequest, nil, 3},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			store := seededStore()
+			w := newTestWeb(store)
+			rec := doRequest(w, http.MethodPost, "/api/device/bulk"+c.query, c.body)
+			assert.Equal(t, c.wantStatus, rec.Code)
+			assert.Equal(t, c.wantDevices, len(store.devices))
+			if c.wantStatuses == nil {
+				return
+			}
+
+			var results model.BulkResults
+			if err := json.Unmarshal(rec.Body.Bytes(), &results); err != nil {
+				t.Fatalf("failed to unmarshal response: %v", err)
+			}
+			assert.Equal(t, len(c.wantStatuses), len(results.Results))
+			created := 0
+			for i, result := range results.Results {
+				assert.Equal(t, i, result.Index)
+				assert.Equal(t, c.wantStatuses[i], result.Status)
+				if result.Status == http.StatusCreated {
+					created++
+					assert.NotEqual(t, "", result.Device.ID)
+					assert.Equal(t, (*model.Problem)(nil), result.Error)
+					continue
+				}
+				assert.Equal(t, (*model.Device)(nil), result.Device)
+				assert.Equal(t, result.Status, result.Error.Status)
+			}
+			assert.Equal(t, created, results.Created)
+			assert.Equal(t, len(c.wantStatuses)-created, results.Failed)
+		})
+	}
+
+	t.Run("ItemProblems", func(t *testing.T) {
+		w := newTestWeb(seededStore())
+		rec := doRequest(w, http.MethodPost, "/api/device/bulk", devices[:3])
+		var results model.BulkResults
+		_ = json.Unmarshal(rec.Body.Bytes(), &results)
+		assert.Equal(t, codeBulkAborted, results.Results[0].Error.Code)
+		assert.Equal(t, codeBulkAborted, results.Results[1].Error.Code)
+		assert.Equal(t, codeValidation, results.Results[2].Error.Code)
+		assert.Equal(t, "state", results.Results[2].Error.Errors[0].Field)
+	})
+
+	t.Run("ConflictProblem", func(t *testing.T) {
+		w := newTestWeb(seededStore())
+		rec := doRequest(w, http.MethodPost, "/api/device/bulk", devices[:2])
+		var results model.BulkResults
+		_ = json.Unmarshal(rec.Body.Bytes(), &results)
+		assert.Equal(t, codeBulkAborted, results.Results[0].Error.Code)
+		assert.Equal(t, codeConflict, results.Results[1].Error.Code)
+		assert.Equal(t, "/api/device/bulk", results.Results[1].Error.Instance)
+	})
+
+	t.Run("StoreError", func(t *testing.T) {
+		w := newTestWeb(&mockStore{createErr: errors.New("connection refused")})
+		rec := doRequest(w, http.MethodPost, "/api/device/bulk", devices[:1])
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Equal(t, false, strings.Contains(rec.Body.String(), "connection refused"))
+	})
+
+	t.Run("Memory", func(t *testing.T) {
+		store := db.NewMemory()
+		w := newTestWeb(store)
+		rec := doRequest(w, http.MethodPost, "/api/device/bulk?mode=best-effort", devices)
+		assert.Equal(t, http.StatusMultiStatus, rec.Code)
+		count, _, _ := store.CountDevices(t.Context(), db.DeviceQuery{}, db.CountExact)
+		assert.Equal(t, int64(3), count)
+	})
+}
+
+// bulkStore returns a memory store holding the given devices, along with their IDs.
+func bulkStore(t *testing.T, devices ...model.Device) (*Web, []string) {
+	w := newTestWeb(db.NewMemory())
+	var ids []string
+	for _, d := range devices {
+		rec := doRequest(w, http.MethodPost, "/api/device/", d)
+		var created model.Device
+		if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+			t.Fatalf("failed to create device: %v", err)
+		}
+		ids = append(ids, created.ID)
+	}
+	return w, ids
+}
+
+func decodeChangeResults(t *testing.T, body []byte) model.BulkChangeResults {
+	var results model.BulkChangeResults
+	if err := json.Unmarshal(body, &results); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	return results
+}
+
+func TestChangeDevicesState(t *testing.T) {
+	devices := []model.Device{
+		{Name: "Alpha", Brand: "BrandA", State: "Available"},
+		{Name: "Beta", Brand: "BrandA", State: "Inactive"},
+		{Name: "Gamma", Brand: "BrandB", State: "In-Use"},
+	}
+	statuses := func(results model.BulkChangeResults) []int {
+		var statuses []int
+		for _, result := range results.Results {
+			statuses = append(statuses, result.Status)
+		}
+		return statuses
+	}
+
+	t.Run("Atomic", func(t *testing.T) {
+		w, ids := bulkStore(t, devices...)
+		rec := doRequest(w, http.MethodPost, "/api/device/bulk/state", model.BulkStateChange{BulkSelection: model.BulkSelection{IDs: ids}, State: "In-Use"})
+		assert.Equal(t, http.StatusMultiStatus, rec.Code)
+		results := decodeChangeResults(t, rec.Body.Bytes())
+		assert.Equal(t, []int{424, 400, 424}, statuses(results))
+		assert.Equal(t, codeStateViolation, results.Results[1].Error.Code)
+		assert.Equal(t, codeBulkAborted, results.Results[0].Error.Code)
+
+		rec = doRequest(w, http.MethodGet, "/api/device/"+ids[0], nil)
+		assert.Equal(t, true, strings.Contains(rec.Body.String(), `"state":"Available"`))
+	})
+
+	t.Run("BestEffort", func(t *testing.T) {
+		w, ids := bulkStore(t, devices...)
+		rec := doRequest(w, http.MethodPost, "/api/device/bulk/state?mode=best-effort", model.BulkStateChange{BulkSelection: model.BulkSelection{IDs: ids}, State: "In-Use"})
+		assert.Equal(t, http.StatusMultiStatus, rec.Code)
+		results := decodeChangeResults(t, rec.Body.Bytes())
+		assert.Equal(t, []int{200, 400, 200}, statuses(results))
+		assert.Equal(t, 1, results.Changed)
+		assert.Equal(t, 1, results.Unchanged)
+		assert.Equal(t, 1, results.Failed)
+		assert.Equal(t, "In-Use", results.Results[0].Device.State)
+		assert.Equal(t, false, results.Results[2].Changed)
+	})
+
+	t.Run("Filter", func(t *testing.T) {
+		w, ids := bulkStore(t, devices...)
+		rec := doRequest(w, http.MethodPost, "/api/device/bulk/state?brand=BrandA", model.BulkStateChange{State: "Available"})
+		assert.Equal(t, http.StatusOK, rec.Code)
+		results := decodeChangeResults(t, rec.Body.Bytes())
+		assert.Equal(t, 2, len(results.Results))
+		assert.Equal(t, ids[0], results.Results[0].ID)
+		assert.Equal(t, false, results.Results[0].Changed)
+		assert.Equal(t, true, results.Results[1].Changed)
+		assert.Equal(t, "Available", results.Results[1].Device.State)
+	})
+
+	t.Run("DryRun", func(t *testing.T) {
+		w, ids := bulkStore(t, devices...)
+		rec := doRequest(w, http.MethodPost, "/api/device/bulk/state?dryRun=true", model.BulkStateChange{BulkSelection: model.BulkSelection{IDs: ids[1:2]}, State: "Available"})
+		assert.Equal(t, http.StatusOK, rec.Code)
+		results := decodeChangeResults(t, rec.Body.Bytes())
+		assert.Equal(t, true, results.DryRun)
+		assert.Equal(t, 1, results.Changed)
+		assert.Equal(t, "Available", results.Results[0].Device.State)
+
+		rec = doRequest(w, http.MethodGet, "/api/device/"+ids[1], nil)
+		assert.Equal(t, true, strings.Contains(rec.Body.String(), `"state":"Inactive"`))
+	})
+
+	invalid := []struct {
+		name       string
+		query      string
+		body       interface{}
+		wantStatus int
+	}{
+		{"MissingState", "", model.BulkStateChange{BulkSelection: model.BulkSelection{IDs: []string{"a"}}}, http.StatusBadRequest},
+		{"InvalidState", "", model.BulkStateChange{BulkSelection: model.BulkSelection{IDs: []string{"a"}}, State: "Broken"}, http.StatusBadRequest},
+		{"NoSelection", "", model.BulkStateChange{State: "Available"}, http.StatusBadRequest},
+		{"IDsAndFilters", "?brand=BrandA", model.BulkStateChange{BulkSelection: model.BulkSelection{IDs: []string{"a"}}, State: "Available"}, http.StatusBadRequest},
+		{"RepeatedIDs", "", model.BulkStateChange{BulkSelection: model.BulkSelection{IDs: []string{"a", "a"}}, State: "Available"}, http.StatusBadRequest},
+		{"TooManyIDs", "", model.BulkStateChange{BulkSelection: model.BulkSelection{IDs: make([]string, maxBulkSize+1)}, State: "Available"}, http.StatusBadRequest},
+		{"InvalidFilter", "?filter=color:red", model.BulkStateChange{State: "Available"}, http.StatusBadRequest},
+		{"InvalidMode", "?mode=partial", model.BulkStateChange{BulkSelection: model.BulkSelection{IDs: []string{"a"}}, State: "Available"}, http.StatusBadRequest},
+		{"InvalidDryRun", "?dryRun=maybe", model.BulkStateChange{BulkSelection: model.BulkSelection{IDs: []string{"a"}}, State: "Available"}, http.StatusBadRequest},
+		{"UnknownIDs", "", model.BulkStateChange{BulkSelection: model.BulkSelection{IDs: []string{"a", "00000000-0000-0000-0000-000000000000"}}, State: "Available"}, http.StatusMultiStatus},
+	}
+	for _, c := range invalid {
+		t.Run(c.name, func(t *testing.T) {
+			w := newTestWeb(seededStore())
+			rec := doRequest(w, http.MethodPost, "/api/device/bulk/state"+c.query, c.body)
+			assert.Equal(t, c.wantStatus, rec.Code)
+		})
+	}
+
+	t.Run("FilterTooBroad", func(t *testing.T) {
+		store := &mockStore{}
+		for i := range maxBulkSize + 1 {
+			store.devices = append(store.devices, database.Device{ID: uuid.New(), Name: fmt.Sprint(i), Brand: "BrandA", State: "Available"})
+		}
+		w := newTestWeb(store)
+		rec := doRequest(w, http.MethodPost, "/api/device/bulk/state?brand=BrandA", model.BulkStateChange{State: "Inactive"})
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("StoreError", func(t *testing.T) {
+		w := newTestWeb(&mockStore{updateErr: errors.New("connection refused")})
+		rec := doRequest(w, http.MethodPost, "/api/device/bulk/state", model.BulkStateChange{BulkSelection: model.BulkSelection{IDs: []string{"a"}}, State: "Available"})
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+}
+
+func TestDeleteDevices(t *testing.T) {
+	devices := []model.Device{
+		{Name: "Alpha", Brand: "BrandA", State: "Available"},
+		{Name: "Beta", Brand: "BrandA", State: "In-Use"},
+		{Name: "Gamma", Brand: "BrandB", State: "Inactive"},
+	}
+
+	t.Run("BestEffort", func(t *testing.T) {
+		w, ids := bulkStore(t, devices...)
+		rec := doRequest(w, http.MethodPost, "/api/device/bulk/delete?mode=best-effort", model.BulkSelection{IDs: ids[:2]})
+		assert.Equal(t, http.StatusMultiStatus, rec.Code)
+		results := decodeChangeResults(t, rec.Body.Bytes())
+		assert.Equal(t, http.StatusOK, results.Results[0].Status)
+		assert.NotEqual(t, "", results.Results[0].Device.DeletedAt)
+		assert.Equal(t, codeStateViolation, results.Results[1].Error.Code)
+
+		assert.Equal(t, http.StatusNotFound, doRequest(w, http.MethodGet, "/api/device/"+ids[0], nil).Code)
+		assert.Equal(t, http.StatusOK, doRequest(w, http.MethodGet, "/api/device/"+ids[1], nil).Code)
+	})
+
+	t.Run("FilterWithoutBody", func(t *testing.T) {
+		w, ids := bulkStore(t, devices...)
+		rec := doRequest(w, http.MethodPost, "/api/device/bulk/delete?state=Available,Inactive", nil)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		results := decodeChangeResults(t, rec.Body.Bytes())
+		assert.Equal(t, 2, results.Changed)
+
+		assert.Equal(t, http.StatusNotFound, doRequest(w, http.MethodGet, "/api/device/"+ids[2], nil).Code)
+	})
+
+	t.Run("DryRun", func(t *testing.T) {
+		w, ids := bulkStore(t, devices...)
+		rec := doRequest(w, http.MethodPost, "/api/device/bulk/delete?dryRun=true&brand=BrandA", nil)
+		assert.Equal(t, http.StatusMultiStatus, rec.Code)
+		results := decodeChangeResults(t, rec.Body.Bytes())
+		assert.Equal(t, true, results.DryRun)
+		assert.Equal(t, http.StatusFailedDependency, results.Results[0].Status)
+		assert.Equal(t, http.StatusBadRequest, results.Results[1].Status)
+
+		assert.Equal(t, http.StatusOK, doRequest(w, http.MethodGet, "/api/device/"+ids[0], nil).Code)
+	})
+
+	t.Run("NoSelection", func(t *testing.T) {
+		w := newTestWeb(seededStore())
+		assert.Equal(t, http.StatusBadRequest, doRequest(w, http.MethodPost, "/api/device/bulk/delete", nil).Code)
+	})
+}
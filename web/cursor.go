@@ -0,0 +1,74 @@
+package web
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lcmps/DevicesAPI/db"
+	"github.com/lcmps/DevicesAPI/model/database"
+)
+
+// maxPageSize is the largest page a list endpoint returns, so a single request can't dump a whole table.
+const maxPageSize = 500
+
+// Values of the count query parameter of the device list
+const (
+	countExact    = "exact"
+	countEstimate = "estimate"
+	countNone     = "none"
+)
+
+var countModes = []string{countExact, countEstimate, countNone}
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// pageCursor is the content of the opaque cursor tokens handed out by the device list: the position of a
+// device, the sort it was issued for and whether the page is the one after (next) or before (prev) it.
+type pageCursor struct {
+	Name      string    `json:"n"`
+	Brand     string    `json:"b"`
+	State     string    `json:"s"`
+	CreatedAt time.Time `json:"t"`
+	ID        uuid.UUID `json:"i"`
+	Sort      string    `json:"o,omitempty"`
+	Prev      bool      `json:"p,omitempty"`
+}
+
+// encodeCursor builds the token pointing at the page after the device, or before it when prev is set.
+func encodeCursor(device database.Device, sort string, prev bool) string {
+	data, _ := json.Marshal(pageCursor{
+		Name:      device.Name,
+		Brand:     device.Brand,
+		State:     device.State,
+		CreatedAt: device.CreatedAt,
+		ID:        device.ID,
+		Sort:      sort,
+		Prev:      prev,
+	})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(token string) (pageCursor, error) {
+	var cursor pageCursor
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return cursor, errInvalidCursor
+	}
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == uuid.Nil || cursor.CreatedAt.IsZero() {
+		return cursor, errInvalidCursor
+	}
+	return cursor, nil
+}
+
+// apply sets the cursor as the starting point of the query.
+func (c pageCursor) apply(query *db.DeviceQuery) {
+	position := db.Cursor{Name: c.Name, Brand: c.Brand, State: c.State, CreatedAt: c.CreatedAt, ID: c.ID}
+	if c.Prev {
+		query.Before = &position
+	} else {
+		query.After = &position
+	}
+}
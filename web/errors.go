@@ -0,0 +1,105 @@
+package web
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/lcmps/DevicesAPI/db"
+	"github.com/lcmps/DevicesAPI/model"
+)
+
+const problemContentType = "application/problem+json"
+
+// Machine-readable error codes, sent on the "code" field of every problem
+const (
+	codeNotFound             = "device_not_found"
+	codeInvalidID            = "invalid_device_id"
+	codeConflict             = "device_conflict"
+	codeStateViolation       = "state_violation"
+	codeInvalidBody          = "invalid_body"
+	codeValidation           = "validation_failed"
+	codeInvalidQuery         = "invalid_query"
+	codeInternal             = "internal_error"
+	codeRouteNotFound        = "route_not_found"
+	codeForbidden            = "forbidden"
+	codePreconditionRequired = "precondition_required"
+	codePreconditionFailed   = "precondition_failed"
+	codeConcurrentUpdate     = "concurrent_update"
+	codeUnsupportedMedia     = "unsupported_media_type"
+	codeNotAcceptable        = "not_acceptable"
+	codeInvalidPatch         = "invalid_patch"
+	codePatchTestFailed      = "patch_test_failed"
+	codeBulkAborted          = "bulk_aborted"
+	fieldCodeRequired        = "required"
+	fieldCodeInvalid         = "invalid_value"
+	fieldCodeNotInteger      = "not_integer"
+	fieldCodeReadOnly        = "read_only"
+)
+
+// errorStatus maps the errors returned by the store to their HTTP status code and error code.
+// Anything unknown is a real failure, so it ends up as 500.
+func errorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		return http.StatusNotFound, codeNotFound
+	case errors.Is(err, db.ErrInvalidID):
+		return http.StatusBadRequest, codeInvalidID
+	case errors.Is(err, db.ErrConflict):
+		return http.StatusConflict, codeConflict
+	case errors.Is(err, db.ErrStateViolation):
+		return http.StatusBadRequest, codeStateViolation
+	case errors.Is(err, db.ErrVersionMismatch):
+		return http.StatusPreconditionFailed, codePreconditionFailed
+	case errors.Is(err, db.ErrBulkAborted):
+		return http.StatusFailedDependency, codeBulkAborted
+	case errors.Is(err, db.ErrInvalidSearch):
+		return http.StatusBadRequest, codeInvalidQuery
+	default:
+		return http.StatusInternalServerError, codeInternal
+	}
+}
+
+// respondProblem writes an RFC 7807 problem to the client, in the representation it asked for.
+func respondProblem(ctx *gin.Context, status int, code, detail string, fieldErrors ...model.FieldError) {
+	// respond keeps the content type when one was set before, the problem types only exist for JSON and XML
+	switch representation(ctx) {
+	case binding.MIMEJSON:
+		ctx.Header("Content-Type", problemContentType)
+	case binding.MIMEXML, binding.MIMEXML2:
+		ctx.Header("Content-Type", problemXMLContentType)
+	}
+	respond(ctx, status, newProblem(ctx, status, code, detail, fieldErrors...))
+}
+
+// newProblem builds the RFC 7807 problem of the request.
+func newProblem(ctx *gin.Context, status int, code, detail string, fieldErrors ...model.FieldError) model.Problem {
+	return model.Problem{
+		Type:     "/problems/" + code,
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: ctx.Request.URL.Path,
+		Code:     code,
+		Errors:   fieldErrors,
+	}
+}
+
+// respondError writes err to the client with the status code matching its kind.
+// Unexpected errors are only logged, so driver messages never reach the client.
+func respondError(ctx *gin.Context, err error) {
+	status, code := errorStatus(err)
+	detail := err.Error()
+	if status == http.StatusInternalServerError {
+		log.Printf("%s %s failed: %v", ctx.Request.Method, ctx.Request.URL.Path, err)
+		detail = "an unexpected error occurred while processing the request"
+	}
+	respondProblem(ctx, status, code, detail)
+}
+
+// respondValidation rejects the request with 400, listing every invalid field.
+func respondValidation(ctx *gin.Context, detail string, fieldErrors ...model.FieldError) {
+	respondProblem(ctx, http.StatusBadRequest, codeValidation, detail, fieldErrors...)
+}
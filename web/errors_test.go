@@ -0,0 +1,88 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/lcmps/DevicesAPI/db"
+	"github.com/lcmps/DevicesAPI/model"
+)
+
+func TestErrorStatus(t *testing.T) {
+	cases := []struct {
+		name     string
+		err      error
+		want     int
+		wantCode string
+	}{
+		{"NotFound", db.ErrNotFound, http.StatusNotFound, codeNotFound},
+		{"WrappedNotFound", fmt.Errorf("failed to get device: %w", db.ErrNotFound), http.StatusNotFound, codeNotFound},
+		{"InvalidID", db.ErrInvalidID, http.StatusBadRequest, codeInvalidID},
+		{"Conflict", db.ErrConflict, http.StatusConflict, codeConflict},
+		{"StateViolation", &db.StateViolationError{Reason: "device is currently in use"}, http.StatusBadRequest, codeStateViolation},
+		{"VersionMismatch", db.ErrVersionMismatch, http.StatusPreconditionFailed, codePreconditionFailed},
+		{"Unknown", errors.New("connection refused"), http.StatusInternalServerError, codeInternal},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			status, code := errorStatus(tc.err)
+			assert.Equal(t, tc.want, status)
+			assert.Equal(t, tc.wantCode, code)
+		})
+	}
+}
+
+func TestRespondError(t *testing.T) {
+	t.Run("Problem", func(t *testing.T) {
+		rec := doRequest(newTestWeb(seededStore()), http.MethodGet, "/api/device/00000000-0000-0000-0000-000000000000", nil)
+
+		assert.Equal(t, problemContentType, rec.Header().Get("Content-Type"))
+		var problem model.Problem
+		if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+			t.Fatalf("failed to decode problem: %v", err)
+		}
+		assert.Equal(t, "/problems/"+codeNotFound, problem.Type)
+		assert.Equal(t, "Not Found", problem.Title)
+		assert.Equal(t, http.StatusNotFound, problem.Status)
+		assert.Equal(t, codeNotFound, problem.Code)
+		assert.Equal(t, "/api/device/00000000-0000-0000-0000-000000000000", problem.Instance)
+	})
+
+	t.Run("InternalErrorsAreHidden", func(t *testing.T) {
+		store := seededStore()
+		store.getErr = errors.New("pq: password authentication failed")
+		rec := doRequest(newTestWeb(store), http.MethodGet, "/api/device/3fa85f64-5717-4562-b3fc-2c963f66afa6", nil)
+
+		var problem model.Problem
+		_ = json.Unmarshal(rec.Body.Bytes(), &problem)
+		assert.Equal(t, http.StatusInternalServerError, problem.Status)
+		assert.Equal(t, codeInternal, problem.Code)
+		if strings.Contains(problem.Detail, "password") {
+			t.Fatalf("expected driver message to be hidden, got %q", problem.Detail)
+		}
+	})
+
+	t.Run("FieldErrors", func(t *testing.T) {
+		rec := doRequest(newTestWeb(&mockStore{}), http.MethodPost, "/api/device/", model.Device{State: "Available"})
+
+		var problem model.Problem
+		_ = json.Unmarshal(rec.Body.Bytes(), &problem)
+		assert.Equal(t, codeValidation, problem.Code)
+		assert.Equal(t, []model.FieldError{
+			{Field: "name", Code: fieldCodeRequired, Message: "name is required"},
+			{Field: "brand", Code: fieldCodeRequired, Message: "brand is required"},
+		}, problem.Errors)
+	})
+
+	t.Run("UnknownRoute", func(t *testing.T) {
+		rec := doRequest(newTestWeb(&mockStore{}), http.MethodGet, "/api/unknown", nil)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, problemContentType, rec.Header().Get("Content-Type"))
+	})
+}
@@ -0,0 +1,63 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lcmps/DevicesAPI/db"
+	"github.com/lcmps/DevicesAPI/model/database"
+)
+
+// etag builds the entity tag of a device from its version, which changes on every write.
+func etag(device database.Device) string {
+	return fmt.Sprintf(`"%d"`, device.Version)
+}
+
+// ifMatches reports whether one of the entity tags on an If-Match header matches the current one.
+// If-Match uses the strong comparison, so weak tags (W/"...") never match.
+func ifMatches(header, current string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == current {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch validates the If-Match header of a write against the current device.
+// It responds and returns false when the request must not go on.
+func (w *Web) checkIfMatch(ctx *gin.Context, device database.Device) bool {
+	header := ctx.GetHeader("If-Match")
+	if header == "" {
+		if w.RequireIfMatch {
+			respondProblem(ctx, http.StatusPreconditionRequired, codePreconditionRequired,
+				"the If-Match header is required, use the ETag returned when fetching the device")
+			return false
+		}
+		return true
+	}
+
+	if !ifMatches(header, etag(device)) {
+		respondProblem(ctx, http.StatusPreconditionFailed, codePreconditionFailed,
+			"the device was modified, its current ETag is "+etag(device))
+		return false
+	}
+	return true
+}
+
+// respondWriteError writes the error of a write made on the version of the device read by the request.
+// Losing the race against another write only fails a precondition the client sent: without one, or with
+// the "*" wildcard, it is a conflict the client can retry.
+func respondWriteError(ctx *gin.Context, err error) {
+	header := ctx.GetHeader("If-Match")
+	if errors.Is(err, db.ErrVersionMismatch) && (header == "" || ifMatches(header, "*")) {
+		respondProblem(ctx, http.StatusConflict, codeConcurrentUpdate,
+			"the device was modified by another request in the meantime, fetch it and try again")
+		return
+	}
+	respondError(ctx, err)
+}
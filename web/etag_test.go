@@ -0,0 +1,141 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/lcmps/DevicesAPI/db"
+	"github.com/lcmps/DevicesAPI/model"
+)
+
+func TestIfMatches(t *testing.T) {
+	cases := []struct {
+		name   string
+		header string
+		want   bool
+	}{
+		{"Same", `"3"`, true},
+		{"Different", `"2"`, false},
+		{"Wildcard", `*`, true},
+		{"List", `"1", "3"`, true},
+		{"Weak", `W/"3"`, false},
+		{"Unquoted", `3`, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, ifMatches(tc.header, `"3"`))
+		})
+	}
+}
+
+// doConditionalRequest sends a JSON request with the given If-Match header, skipping it when empty.
+func doConditionalRequest(w *Web, method, path, ifMatch string, body interface{}) *httptest.ResponseRecorder {
+	bodyBytes, _ := json.Marshal(body)
+	req, _ := http.NewRequest(method, path, bytes.NewReader(bodyBytes))
+	req.Header.Set("Content-Type", "application/json")
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	rec := httptest.NewRecorder()
+	w.Router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestConditionalRequests(t *testing.T) {
+	const alpha = "/api/device/3fa85f64-5717-4562-b3fc-2c963f66afa6"
+
+	t.Run("ETagOnGet", func(t *testing.T) {
+		rec := doRequest(newTestWeb(seededStore()), http.MethodGet, alpha, nil)
+		assert.Equal(t, `"1"`, rec.Header().Get("ETag"))
+	})
+
+	t.Run("UpdateBumpsETag", func(t *testing.T) {
+		w := newTestWeb(seededStore())
+		rec := doConditionalRequest(w, http.MethodPut, alpha, `"1"`, model.Device{Name: "Alpha 2", Brand: "BrandA", State: "Available"})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+		}
+		assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+
+		// The same ETag can't be used twice
+		rec = doConditionalRequest(w, http.MethodPut, alpha, `"1"`, model.Device{Name: "Alpha 3", Brand: "BrandA", State: "Available"})
+		if rec.Code != http.StatusPreconditionFailed {
+			t.Fatalf("expected status %d, got %d", http.StatusPreconditionFailed, rec.Code)
+		}
+		var resp model.Problem
+		_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+		assert.Equal(t, codePreconditionFailed, resp.Code)
+		assert.Equal(t, `the device was modified, its current ETag is "2"`, resp.Detail)
+	})
+
+	t.Run("DeleteMismatch", func(t *testing.T) {
+		store := seededStore()
+		rec := doConditionalRequest(newTestWeb(store), http.MethodDelete, alpha, `"5"`, nil)
+		if rec.Code != http.StatusPreconditionFailed {
+			t.Fatalf("expected status %d, got %d", http.StatusPreconditionFailed, rec.Code)
+		}
+		assert.Equal(t, false, store.devices[0].Deleted)
+	})
+
+	t.Run("DeleteMatch", func(t *testing.T) {
+		rec := doConditionalRequest(newTestWeb(seededStore()), http.MethodDelete, alpha, `"1"`, nil)
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("expected status %d, got %d", http.StatusNoContent, rec.Code)
+		}
+	})
+
+	t.Run("Required", func(t *testing.T) {
+		w := newTestWeb(seededStore())
+		w.RequireIfMatch = true
+
+		rec := doConditionalRequest(w, http.MethodPut, alpha, "", model.Device{Name: "Alpha 2", Brand: "BrandA", State: "Available"})
+		if rec.Code != http.StatusPreconditionRequired {
+			t.Fatalf("expected status %d, got %d", http.StatusPreconditionRequired, rec.Code)
+		}
+		rec = doConditionalRequest(w, http.MethodDelete, alpha, "", nil)
+		if rec.Code != http.StatusPreconditionRequired {
+			t.Fatalf("expected status %d, got %d", http.StatusPreconditionRequired, rec.Code)
+		}
+		rec = doConditionalRequest(w, http.MethodPut, alpha, "*", model.Device{Name: "Alpha 2", Brand: "BrandA", State: "Available"})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+		}
+	})
+
+	// Another request changed the device between the read and the write of this one
+	concurrent := []struct {
+		name, method, ifMatch string
+		want                  int
+		code                  string
+	}{
+		{"ConcurrentUpdate", http.MethodPut, "", http.StatusConflict, codeConcurrentUpdate},
+		{"ConcurrentUpdateWildcard", http.MethodPut, "*", http.StatusConflict, codeConcurrentUpdate},
+		{"ConcurrentUpdateIfMatch", http.MethodPut, `"1"`, http.StatusPreconditionFailed, codePreconditionFailed},
+		{"ConcurrentDelete", http.MethodDelete, "", http.StatusConflict, codeConcurrentUpdate},
+		{"ConcurrentDeleteIfMatch", http.MethodDelete, `"1"`, http.StatusPreconditionFailed, codePreconditionFailed},
+	}
+	for _, tc := range concurrent {
+		t.Run(tc.name, func(t *testing.T) {
+			store := seededStore()
+			store.updateErr = db.ErrVersionMismatch
+			store.deleteErr = db.ErrVersionMismatch
+
+			var body interface{}
+			if tc.method == http.MethodPut {
+				body = model.Device{Name: "Alpha 2", Brand: "BrandA", State: "Available"}
+			}
+			rec := doConditionalRequest(newTestWeb(store), tc.method, alpha, tc.ifMatch, body)
+			if rec.Code != tc.want {
+				t.Fatalf("expected status %d, got %d", tc.want, rec.Code)
+			}
+			var resp model.Problem
+			_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+			assert.Equal(t, tc.code, resp.Code)
+		})
+	}
+}
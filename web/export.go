@@ -0,0 +1,192 @@
+package web
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lcmps/DevicesAPI/model"
+	"github.com/lcmps/DevicesAPI/model/database"
+)
+
+const ndjsonContentType = "application/x-ndjson"
+
+// exportFlushRows is the number of devices written between two flushes of an export, so clients
+// receive it as it goes instead of once the buffers fill up.
+const exportFlushRows = 500
+
+// exportFormats maps the values of the format query parameter to their media type.
+var exportFormats = map[string]string{
+	"csv":    csvContentType,
+	"ndjson": ndjsonContentType,
+}
+
+// exportColumns is the header of CSV exports, following the fields of model.Device.
+var exportColumns = []string{"id", "name", "brand", "state", "createdAt", "deletedAt", "deletedBy"}
+
+// exportFormat picks the media type of the export from the format query parameter or, when absent, from the
+// Accept header (CSV unless asked otherwise). It responds with 400 or 406 and returns false when none fits.
+func exportFormat(ctx *gin.Context) (string, bool) {
+	if format := ctx.Query("format"); format != "" {
+		contentType, ok := exportFormats[format]
+		if !ok {
+			message := "format must be one of: csv, ndjson"
+			respondProblem(ctx, http.StatusBadRequest, codeInvalidQuery, message,
+				model.FieldError{Field: "format", Code: fieldCodeInvalid, Message: message})
+		}
+		return contentType, ok
+	}
+
+	contentType := ctx.NegotiateFormat(csvContentType, ndjsonContentType)
+	if contentType == "" {
+		respondProblem(ctx, http.StatusNotAcceptable, codeNotAcceptable,
+			fmt.Sprintf("devices can only be exported as %s or %s", csvContentType, ndjsonContentType))
+		return "", false
+	}
+	return contentType, true
+}
+
+// deviceEncoder writes devices in the format of an export.
+type deviceEncoder interface {
+	Encode(device model.Device) error
+	Flush() error
+}
+
+type csvEncoder struct {
+	writer *csv.Writer
+}
+
+func (e csvEncoder) Encode(device model.Device) error {
+	return e.writer.Write([]string{device.ID, device.Name, device.Brand, device.State, device.CreatedAt, device.DeletedAt, device.DeletedBy})
+}
+
+func (e csvEncoder) Flush() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+type ndjsonEncoder struct {
+	encoder *json.Encoder
+}
+
+func (e ndjsonEncoder) Encode(device model.Device) error {
+	return e.encoder.Encode(device)
+}
+
+func (e ndjsonEncoder) Flush() error {
+	return nil
+}
+
+// abortStream cuts the connection of a response that already started, so the client can tell it was
+// truncated instead of receiving what looks like a complete file.
+func abortStream(ctx *gin.Context) {
+	// gin's own Hijack panics when the connection can't be hijacked, e.g. on HTTP/2
+	writer := http.ResponseWriter(ctx.Writer)
+	if unwrapper, ok := writer.(interface{ Unwrap() http.ResponseWriter }); ok {
+		writer = unwrapper.Unwrap()
+	}
+	if conn, _, err := http.NewResponseController(writer).Hijack(); err == nil {
+		_ = conn.Close()
+	}
+}
+
+// exportDevices
+// accepts the same filters and sort as getDeviceByFilter, along with:
+// - format: csv or ndjson, taking precedence over the Accept header (text/csv or application/x-ndjson)
+//
+// @Summary      Export devices
+// @Description  Stream every device matching the filters, with no pagination, as CSV (with a header) or as NDJSON
+// @Description  (one JSON device per line). The format is chosen through format or, when absent, the Accept header,
+// @Description  CSV being the default. Devices are sorted and filtered the same way as GET /device.
+// @Tags         devices
+// @Produce      text/csv,application/x-ndjson
+// @Param        format          query     string  false  "csv or ndjson, instead of the Accept header"
+// @Param        sort            query     string  false  "Fields to sort by (name, brand, state, createdAt), descending when prefixed by -, e.g. brand,-createdAt"
+// @Param        name            query     string  false  "Filter by device name (partial match)"
+// @Param        brand           query     string  false  "Filter by device brands, comma separated: Brand* matches by prefix, *rand* partially, a leading ! negates them"
+// @Param        state           query     string  false  "Filter by device states (Available, In-Use, Inactive), comma separated, a leading ! negates them"
+// @Param        createdAfter    query     string  false  "Only devices created at or after this RFC 3339 time"
+// @Param        createdBefore   query     string  false  "Only devices created before this RFC 3339 time"
+// @Param        filter          query     string  false  "Filter expression, e.g. brand:BrandA AND createdAt>=2025-09-01T00:00:00Z AND NOT state:Inactive"
+// @Param        includeDeleted  query     bool    false  "Also export deleted devices (admins only)"
+// @Param        onlyDeleted     query     bool    false  "Export only deleted devices (admins only)"
+// @Success      200             {string}  string  "Devices, as CSV or NDJSON"
+// @Failure      400             {object}  model.Problem
+// @Failure      403             {object}  model.Problem
+// @Failure      406             {object}  model.Problem
+// @Failure      500             {object}  model.Problem
+// @Router       /device/export [get]
+func (w *Web) exportDevices(ctx *gin.Context) {
+	contentType, ok := exportFormat(ctx)
+	if !ok {
+		return
+	}
+	query, ok := w.listQuery(ctx)
+	if !ok {
+		return
+	}
+
+	// Nothing is written until the first device, so failures before it still get a proper response
+	var encoder deviceEncoder
+	start := func() error {
+		extension := "csv"
+		if contentType == ndjsonContentType {
+			extension = "ndjson"
+		}
+		ctx.Header("Content-Type", contentType+"; charset=utf-8")
+		ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="devices.%s"`, extension))
+		ctx.Status(http.StatusOK)
+
+		if contentType == ndjsonContentType {
+			encoder = ndjsonEncoder{encoder: json.NewEncoder(ctx.Writer)}
+			return nil
+		}
+		writer := csv.NewWriter(ctx.Writer)
+		encoder = csvEncoder{writer: writer}
+		return writer.Write(exportColumns)
+	}
+
+	exported := 0
+	err := w.DB.ExportDevices(ctx.Request.Context(), query, func(device database.Device) error {
+		if encoder == nil {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		var dvc model.Device
+		dvc.TranslateToAPI(device)
+		if err := encoder.Encode(dvc); err != nil {
+			return err
+		}
+		if exported++; exported%exportFlushRows == 0 {
+			if err := encoder.Flush(); err != nil {
+				return err
+			}
+			ctx.Writer.Flush()
+		}
+		return nil
+	})
+	if err != nil && encoder == nil {
+		respondError(ctx, err)
+		return
+	}
+	if err != nil {
+		log.Printf("%s %s failed after %d devices: %v", ctx.Request.Method, ctx.Request.URL.Path, exported, err)
+		abortStream(ctx)
+		return
+	}
+
+	// An empty export still gets its header
+	if encoder == nil {
+		if err := start(); err != nil {
+			log.Printf("%s %s failed: %v", ctx.Request.Method, ctx.Request.URL.Path, err)
+			return
+		}
+	}
+	if err := encoder.Flush(); err != nil {
+		log.Printf("%s %s failed after %d devices: %v", ctx.Request.Method, ctx.Request.URL.Path, exported, err)
+	}
+}
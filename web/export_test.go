@@ -0,0 +1,145 @@
+package web
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/lcmps/DevicesAPI/db"
+	"github.com/lcmps/DevicesAPI/model"
+	"github.com/lcmps/DevicesAPI/model/database"
+)
+
+func doExport(w *Web, query, accept string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(http.MethodGet, "/api/device/export"+query, nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	rec := httptest.NewRecorder()
+	w.Router.ServeHTTP(rec, req)
+	return rec
+}
+
+// failingExport is a store whose exports fail after the given number of devices.
+type failingExport struct {
+	*mockStore
+	after int
+}
+
+func (s failingExport) ExportDevices(ctx context.Context, query db.DeviceQuery, fn func(device database.Device) error) error {
+	for _, d := range s.devices[:s.after] {
+		if err := fn(d); err != nil {
+			return err
+		}
+	}
+	return errors.New("connection reset")
+}
+
+func TestExportDevices(t *testing.T) {
+	t.Run("CSV", func(t *testing.T) {
+		w := newTestWeb(seededStore())
+		rec := doExport(w, "?brand=BrandA", "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="devices.csv"`, rec.Header().Get("Content-Disposition"))
+
+		rows, err := csv.NewReader(rec.Body).ReadAll()
+		if err != nil {
+			t.Fatalf("failed to read export: %v", err)
+		}
+		assert.Equal(t, 3, len(rows))
+		assert.Equal(t, exportColumns, rows[0])
+		assert.Equal(t, []string{"3fa85f64-5717-4562-b3fc-2c963f66afa6", "Alpha", "BrandA", "Available"}, rows[1][:4])
+		assert.Equal(t, "Gamma", rows[2][1])
+	})
+
+	t.Run("NDJSON", func(t *testing.T) {
+		w := newTestWeb(seededStore())
+		for _, c := range []struct{ query, accept string }{{"?format=ndjson", "text/csv"}, {"", "application/x-ndjson"}} {
+			rec := doExport(w, c.query, c.accept)
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "application/x-ndjson; charset=utf-8", rec.Header().Get("Content-Type"))
+
+			var names []string
+			scanner := bufio.NewScanner(rec.Body)
+			for scanner.Scan() {
+				var device model.Device
+				if err := json.Unmarshal(scanner.Bytes(), &device); err != nil {
+					t.Fatalf("failed to unmarshal line %q: %v", scanner.Text(), err)
+				}
+				names = append(names, device.Name)
+			}
+			assert.Equal(t, []string{"Alpha", "Beta", "Gamma"}, names)
+		}
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		w := newTestWeb(seededStore())
+		rec := doExport(w, "?brand=BrandZ", "text/csv")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, strings.Join(exportColumns, ",")+"\n", rec.Body.String())
+
+		rec = doExport(w, "?brand=BrandZ&format=ndjson", "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "", rec.Body.String())
+	})
+
+	t.Run("Memory", func(t *testing.T) {
+		store := db.NewMemory()
+		w := newTestWeb(store)
+		var devices []database.Device
+		for _, name := range strings.Fields("Alpha Beta Gamma Delta") {
+			devices = append(devices, database.Device{Name: name, Brand: "BrandA", State: "Available"})
+		}
+		_, _ = store.CreateDevices(t.Context(), devices, db.BulkAtomic)
+		rec := doExport(w, "?sort=name&filter="+url.QueryEscape("name:*a* AND NOT name:Gamma"), "")
+		rows, _ := csv.NewReader(rec.Body).ReadAll()
+		assert.Equal(t, 4, len(rows))
+		assert.Equal(t, "Alpha", rows[1][1])
+		assert.Equal(t, "Delta", rows[3][1])
+	})
+
+	invalid := []struct {
+		name       string
+		query      string
+		accept     string
+		wantStatus int
+	}{
+		{"InvalidFormat", "?format=xlsx", "", http.StatusBadRequest},
+		{"NotAcceptable", "", "application/json", http.StatusNotAcceptable},
+		{"InvalidSort", "?sort=color", "", http.StatusBadRequest},
+		{"InvalidFilter", "?state=Broken", "", http.StatusBadRequest},
+		{"DeletedNotAdmin", "?onlyDeleted=true", "", http.StatusForbidden},
+	}
+	for _, c := range invalid {
+		t.Run(c.name, func(t *testing.T) {
+			w := newTestWeb(seededStore())
+			w.Admins = []string{"root"}
+			rec := doExport(w, c.query, c.accept)
+			assert.Equal(t, c.wantStatus, rec.Code)
+		})
+	}
+
+	t.Run("StoreError", func(t *testing.T) {
+		w := newTestWeb(&mockStore{listErr: errors.New("connection refused")})
+		rec := doExport(w, "", "")
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Equal(t, problemContentType, rec.Header().Get("Content-Type"))
+	})
+
+	t.Run("FailsWhileStreaming", func(t *testing.T) {
+		w := newTestWeb(failingExport{mockStore: seededStore(), after: 2})
+		rec := doExport(w, "", "")
+		// The status was already sent, the stream is cut instead
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get("Content-Type"))
+	})
+}
@@ -0,0 +1,341 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lcmps/DevicesAPI/db"
+	"github.com/lcmps/DevicesAPI/model"
+)
+
+// Limits of the filter expressions, so a single request can't make the database chew on a huge condition
+const (
+	maxFilterLength = 1000
+	maxFilterDepth  = 16
+)
+
+// filterFields maps the fields of the filter language to their column.
+var filterFields = map[string]string{
+	"name":      db.ColumnName,
+	"brand":     db.ColumnBrand,
+	"state":     db.ColumnState,
+	"createdAt": db.ColumnCreatedAt,
+}
+
+// listFilter reads the filters of the device list, all of them having to match:
+// - brand and state: values lists (see parseValues), wildcards are only allowed on brand
+// - createdAfter and createdBefore: RFC 3339 times, createdAfter included
+// - filter: an expression (see parseFilter)
+//
+// It responds with 400 and returns false when one of them is invalid, and returns nil when none is set.
+func listFilter(ctx *gin.Context) (*db.Filter, bool) {
+	var filters []db.Filter
+	invalid := func(field string, err error) (*db.Filter, bool) {
+		respondProblem(ctx, http.StatusBadRequest, codeInvalidQuery, field+": "+err.Error(),
+			model.FieldError{Field: field, Code: fieldCodeInvalid, Message: err.Error()})
+		return nil, false
+	}
+
+	for _, param := range []struct{ field, column string }{{"brand", db.ColumnBrand}, {"state", db.ColumnState}} {
+		value := ctx.Query(param.field)
+		if value == "" {
+			continue
+		}
+		filter, err := parseValues(param.column, value)
+		if err != nil {
+			return invalid(param.field, err)
+		}
+		filters = append(filters, filter)
+	}
+
+	for _, param := range []struct {
+		field string
+		op    db.FilterOp
+	}{{"createdAfter", db.FilterFrom}, {"createdBefore", db.FilterUntil}} {
+		t, ok := timeQuery(ctx, param.field)
+		if !ok {
+			return nil, false
+		}
+		if !t.IsZero() {
+			filters = append(filters, db.Filter{Column: db.ColumnCreatedAt, Op: param.op, Time: t})
+		}
+	}
+
+	if expression := ctx.Query("filter"); expression != "" {
+		filter, err := parseFilter(expression)
+		if err != nil {
+			return invalid("filter", err)
+		}
+		filters = append(filters, filter)
+	}
+
+	if len(filters) == 0 {
+		return nil, true
+	}
+	return &db.Filter{Filters: filters}, true
+}
+
+// parseValues reads a comma separated list of values matched by a filter on the column, e.g. "BrandA,Brand*".
+// Values ending with * match by prefix, values surrounded by * match partially (both case-insensitive),
+// except for quoted ones, which like the others match exactly. A leading ! negates the whole list.
+func parseValues(column, list string) (db.Filter, error) {
+	not := strings.HasPrefix(list, "!")
+	list = strings.TrimPrefix(list, "!")
+
+	// Values are grouped by the way they are compared, any of them matching is enough
+	group := db.Filter{Or: true, Not: not}
+	byOp := map[db.FilterOp]int{}
+	for _, value := range splitOutsideQuotes(list, ',') {
+		value = strings.TrimSpace(value)
+		op := db.FilterEqual
+		if unquoted, ok := unquote(value); ok {
+			value = unquoted
+		} else {
+			op, value = wildcard(value)
+			if strings.Contains(value, "*") {
+				return db.Filter{}, fmt.Errorf("%q: * can only be used at the end of a value, or around it", value)
+			}
+		}
+		if value == "" {
+			return db.Filter{}, errors.New("values cannot be empty")
+		}
+		if column == db.ColumnState && (op != db.FilterEqual || !isValidState(value)) {
+			return db.Filter{}, fmt.Errorf("%q is not a valid state, should be one of: Available, In-Use, Inactive", value)
+		}
+
+		i, ok := byOp[op]
+		if !ok {
+			i = len(group.Filters)
+			byOp[op] = i
+			group.Filters = append(group.Filters, db.Filter{Column: column, Op: op})
+		}
+		group.Filters[i].Values = append(group.Filters[i].Values, value)
+	}
+
+	if len(group.Filters) == 1 {
+		filter := group.Filters[0]
+		filter.Not = not
+		return filter, nil
+	}
+	return group, nil
+}
+
+// wildcard tells how an unquoted value is compared, stripping its wildcards.
+func wildcard(value string) (db.FilterOp, string) {
+	if len(value) > 2 && strings.HasPrefix(value, "*") && strings.HasSuffix(value, "*") {
+		return db.FilterContains, value[1 : len(value)-1]
+	}
+	if len(value) > 1 && strings.HasSuffix(value, "*") {
+		return db.FilterPrefix, value[:len(value)-1]
+	}
+	return db.FilterEqual, value
+}
+
+func unquote(value string) (string, bool) {
+	if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+		return value[1 : len(value)-1], true
+	}
+	return value, false
+}
+
+// splitOutsideQuotes splits the value on sep, except between double quotes.
+func splitOutsideQuotes(value string, sep rune) []string {
+	var parts []string
+	quoted := false
+	start := 0
+	for i, r := range value {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == sep && !quoted:
+			parts = append(parts, value[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, value[start:])
+}
+
+// parseFilter reads a filter expression such as
+//
+//	brand:BrandA AND createdAt>=2025-09-01T00:00:00Z AND NOT (state:Inactive OR name:"Old*")
+//
+// made of comparisons combined through AND, OR, NOT and parentheses, AND taking precedence over OR.
+// A comparison is either field:values (see parseValues) on name, brand or state,
+// or createdAt>=time and createdAt<time with RFC 3339 times.
+func parseFilter(expression string) (db.Filter, error) {
+	if len(expression) > maxFilterLength {
+		return db.Filter{}, fmt.Errorf("filter cannot be longer than %d characters", maxFilterLength)
+	}
+
+	tokens, err := tokenizeFilter(expression)
+	if err != nil {
+		return db.Filter{}, err
+	}
+	p := &filterParser{tokens: tokens}
+	filter, err := p.or(0)
+	if err != nil {
+		return db.Filter{}, err
+	}
+	if p.pos < len(p.tokens) {
+		return db.Filter{}, fmt.Errorf("unexpected %q, expected AND or OR", p.tokens[p.pos])
+	}
+	return filter, nil
+}
+
+// tokenizeFilter splits an expression into parentheses and words, keeping quoted text together.
+func tokenizeFilter(expression string) ([]string, error) {
+	var tokens []string
+	var word strings.Builder
+	quoted := false
+	flush := func() {
+		if word.Len() > 0 {
+			tokens = append(tokens, word.String())
+			word.Reset()
+		}
+	}
+
+	for _, r := range expression {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			word.WriteRune(r)
+		case quoted:
+			word.WriteRune(r)
+		case r == '(' || r == ')':
+			flush()
+			tokens = append(tokens, string(r))
+		case r == ' ' || r == '\t' || r == '\n':
+			flush()
+		default:
+			word.WriteRune(r)
+		}
+	}
+	if quoted {
+		return nil, errors.New("unterminated quote")
+	}
+	flush()
+
+	if len(tokens) == 0 {
+		return nil, errors.New("filter cannot be empty")
+	}
+	return tokens, nil
+}
+
+// filterParser is a recursive descent parser over the tokens of a filter expression.
+type filterParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *filterParser) keyword(keyword string) bool {
+	if p.pos < len(p.tokens) && strings.EqualFold(p.tokens[p.pos], keyword) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *filterParser) or(depth int) (db.Filter, error) {
+	return p.group(depth, "OR", p.and)
+}
+
+func (p *filterParser) and(depth int) (db.Filter, error) {
+	return p.group(depth, "AND", p.not)
+}
+
+// group parses operands separated by the keyword, returning a single operand as is.
+func (p *filterParser) group(depth int, keyword string, operand func(int) (db.Filter, error)) (db.Filter, error) {
+	first, err := operand(depth)
+	if err != nil {
+		return db.Filter{}, err
+	}
+	group := db.Filter{Or: keyword == "OR", Filters: []db.Filter{first}}
+	for p.keyword(keyword) {
+		next, err := operand(depth)
+		if err != nil {
+			return db.Filter{}, err
+		}
+		group.Filters = append(group.Filters, next)
+	}
+	if len(group.Filters) == 1 {
+		return first, nil
+	}
+	return group, nil
+}
+
+func (p *filterParser) not(depth int) (db.Filter, error) {
+	if p.keyword("NOT") {
+		filter, err := p.not(depth)
+		if err != nil {
+			return db.Filter{}, err
+		}
+		// Wrapping it, so NOT NOT a is a
+		return db.Filter{Filters: []db.Filter{filter}, Not: true}, nil
+	}
+	return p.primary(depth)
+}
+
+func (p *filterParser) primary(depth int) (db.Filter, error) {
+	if p.pos >= len(p.tokens) {
+		return db.Filter{}, errors.New("unexpected end of filter")
+	}
+
+	token := p.tokens[p.pos]
+	p.pos++
+	switch {
+	case token == "(":
+		if depth >= maxFilterDepth {
+			return db.Filter{}, fmt.Errorf("filter cannot nest more than %d groups", maxFilterDepth)
+		}
+		filter, err := p.or(depth + 1)
+		if err != nil {
+			return db.Filter{}, err
+		}
+		if !p.keyword(")") {
+			return db.Filter{}, errors.New("missing closing parenthesis")
+		}
+		return filter, nil
+	case token == ")", strings.EqualFold(token, "AND"), strings.EqualFold(token, "OR"):
+		return db.Filter{}, fmt.Errorf("unexpected %q, expected a comparison", token)
+	default:
+		return parseComparison(token)
+	}
+}
+
+// parseComparison reads a single comparison, e.g. state:Available,In-Use or createdAt>=2025-01-01T00:00:00Z.
+func parseComparison(token string) (db.Filter, error) {
+	i := strings.IndexAny(token, ":<>")
+	if i < 0 {
+		return db.Filter{}, fmt.Errorf("%q is not a comparison, expected field:values", token)
+	}
+	field, operator, value := token[:i], token[i:i+1], token[i+1:]
+	if operator == ">" && strings.HasPrefix(value, "=") {
+		operator, value = ">=", value[1:]
+	}
+
+	column, ok := filterFields[field]
+	if !ok {
+		return db.Filter{}, fmt.Errorf("unknown filter field %q, should be one of: name, brand, state, createdAt", field)
+	}
+
+	if column != db.ColumnCreatedAt {
+		if operator != ":" {
+			return db.Filter{}, fmt.Errorf("%s can only be compared through %s:values", field, field)
+		}
+		return parseValues(column, value)
+	}
+
+	op := map[string]db.FilterOp{">=": db.FilterFrom, "<": db.FilterUntil}
+	if _, ok := op[operator]; !ok {
+		return db.Filter{}, errors.New("createdAt can only be compared through createdAt>=time or createdAt<time")
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return db.Filter{}, fmt.Errorf("%q is not an RFC 3339 timestamp, e.g. 2023-10-05T14:48:00Z", value)
+	}
+	return db.Filter{Column: column, Op: op[operator], Time: t}, nil
+}
@@ -0,0 +1,163 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/lcmps/DevicesAPI/db"
+	"github.com/lcmps/DevicesAPI/model"
+)
+
+func TestParseValues(t *testing.T) {
+	cases := []struct {
+		name    string
+		column  string
+		list    string
+		want    db.Filter
+		wantErr bool
+	}{
+		{"Single", db.ColumnBrand, "BrandA", db.Filter{Column: db.ColumnBrand, Values: []string{"BrandA"}}, false},
+		{"Multiple", db.ColumnState, "Available,In-Use", db.Filter{Column: db.ColumnState, Values: []string{"Available", "In-Use"}}, false},
+		{"Negated", db.ColumnState, "!Inactive", db.Filter{Column: db.ColumnState, Values: []string{"Inactive"}, Not: true}, false},
+		{"Prefix", db.ColumnBrand, "Brand*", db.Filter{Column: db.ColumnBrand, Op: db.FilterPrefix, Values: []string{"Brand"}}, false},
+		{"Partial", db.ColumnBrand, "*rand*", db.Filter{Column: db.ColumnBrand, Op: db.FilterContains, Values: []string{"rand"}}, false},
+		{"Quoted", db.ColumnBrand, `"Brand*","A,B"`, db.Filter{Column: db.ColumnBrand, Values: []string{"Brand*", "A,B"}}, false},
+		{
+			"Mixed", db.ColumnBrand, "!BrandA,Other*",
+			db.Filter{Or: true, Not: true, Filters: []db.Filter{
+				{Column: db.ColumnBrand, Values: []string{"BrandA"}},
+				{Column: db.ColumnBrand, Op: db.FilterPrefix, Values: []string{"Other"}},
+			}},
+			false,
+		},
+		{"InnerWildcard", db.ColumnBrand, "Br*nd", db.Filter{}, true},
+		{"SuffixWildcard", db.ColumnBrand, "*rand", db.Filter{}, true},
+		{"EmptyValue", db.ColumnBrand, "BrandA,", db.Filter{}, true},
+		{"InvalidState", db.ColumnState, "Broken", db.Filter{}, true},
+		{"StateWildcard", db.ColumnState, "In*", db.Filter{}, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseValues(tc.column, tc.list)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error %t, got %v", tc.wantErr, err)
+			}
+			if !tc.wantErr {
+				assert.Equal(t, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	september := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("Precedence", func(t *testing.T) {
+		got, err := parseFilter(`brand:BrandA AND createdAt>=2025-09-01T00:00:00Z OR not (state:Inactive or name:"Old Device")`)
+		if err != nil {
+			t.Fatalf("expected nil error, got %v", err)
+		}
+		want := db.Filter{Or: true, Filters: []db.Filter{
+			{Filters: []db.Filter{
+				{Column: db.ColumnBrand, Values: []string{"BrandA"}},
+				{Column: db.ColumnCreatedAt, Op: db.FilterFrom, Time: september},
+			}},
+			{Not: true, Filters: []db.Filter{{Or: true, Filters: []db.Filter{
+				{Column: db.ColumnState, Values: []string{"Inactive"}},
+				{Column: db.ColumnName, Values: []string{"Old Device"}},
+			}}}},
+		}}
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("Single", func(t *testing.T) {
+		got, err := parseFilter("createdAt<2025-09-01T00:00:00Z")
+		if err != nil {
+			t.Fatalf("expected nil error, got %v", err)
+		}
+		assert.Equal(t, db.Filter{Column: db.ColumnCreatedAt, Op: db.FilterUntil, Time: september}, got)
+	})
+
+	invalid := []string{
+		"",
+		"brand",
+		"brand:BrandA state:Available",
+		"brand:BrandA AND",
+		"(brand:BrandA",
+		"brand:BrandA)",
+		"OR brand:BrandA",
+		`name:"Unterminated`,
+		"version:1",
+		"brand>BrandA",
+		"createdAt:2025-09-01T00:00:00Z",
+		"createdAt>=yesterday",
+		"createdAt>2025-09-01T00:00:00Z",
+		"((((((((((((((((((brand:BrandA))))))))))))))))))",
+	}
+	for _, expression := range invalid {
+		t.Run(expression, func(t *testing.T) {
+			if _, err := parseFilter(expression); err == nil {
+				t.Fatalf("expected error on %q", expression)
+			}
+		})
+	}
+}
+
+func TestGetDeviceByFilter_Filters(t *testing.T) {
+	w := newTestWeb(db.NewMemory())
+	for _, d := range []model.Device{
+		{Name: "Alpha", Brand: "BrandA", State: "Available"},
+		{Name: "Beta", Brand: "BrandA", State: "Inactive"},
+		{Name: "Gamma", Brand: "BrandB", State: "In-Use"},
+		{Name: "Delta", Brand: "Other", State: "Available"},
+	} {
+		doRequest(w, http.MethodPost, "/api/device/", d)
+	}
+	hourAgo := url.QueryEscape(time.Now().Add(-time.Hour).Format(time.RFC3339))
+
+	cases := []struct {
+		name       string
+		query      string
+		wantStatus int
+		want       []string
+	}{
+		{"States", "?state=Available,In-Use", http.StatusOK, []string{"Alpha", "Gamma", "Delta"}},
+		{"NotState", "?state=!Available", http.StatusOK, []string{"Beta", "Gamma"}},
+		{"BrandPrefix", "?brand=brand*", http.StatusOK, []string{"Alpha", "Beta", "Gamma"}},
+		{"CreatedRange", "?createdAfter=" + hourAgo + "&createdBefore=2999-01-01T00:00:00Z", http.StatusOK, []string{"Alpha", "Beta", "Gamma", "Delta"}},
+		{"CreatedAfterNow", "?createdAfter=2999-01-01T00:00:00Z", http.StatusOK, nil},
+		{"Expression", "?filter=" + url.QueryEscape("brand:BrandA AND NOT state:Inactive OR name:*elt*"), http.StatusOK, []string{"Alpha", "Delta"}},
+		{"Combined", "?brand=Brand*&filter=" + url.QueryEscape("state:In-Use OR name:Alpha"), http.StatusOK, []string{"Alpha", "Gamma"}},
+		{"InvalidState", "?state=Broken", http.StatusBadRequest, nil},
+		{"InvalidTime", "?createdAfter=yesterday", http.StatusBadRequest, nil},
+		{"InvalidExpression", "?filter=" + url.QueryEscape("brand:BrandA AND"), http.StatusBadRequest, nil},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := doRequest(w, http.MethodGet, "/api/device/"+tc.query, nil)
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tc.wantStatus, rec.Code, rec.Body.String())
+			}
+			if tc.wantStatus != http.StatusOK {
+				var problem model.Problem
+				_ = json.Unmarshal(rec.Body.Bytes(), &problem)
+				assert.Equal(t, codeInvalidQuery, problem.Code)
+				return
+			}
+			var list model.DeviceList
+			_ = json.Unmarshal(rec.Body.Bytes(), &list)
+			var names []string
+			for _, d := range list.Devices {
+				names = append(names, d.Name)
+			}
+			assert.Equal(t, tc.want, names)
+			assert.Equal(t, int64(len(tc.want)), *list.Total)
+		})
+	}
+}
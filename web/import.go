@@ -0,0 +1,332 @@
+package web
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lcmps/DevicesAPI/db"
+	"github.com/lcmps/DevicesAPI/model"
+	"github.com/lcmps/DevicesAPI/model/database"
+)
+
+const csvContentType = "text/csv"
+
+// importBatchSize is the number of rows written at once by a CSV import.
+const importBatchSize = 500
+
+// maxRejectedRows is the number of rejected rows listed on the report of an import, the others being only counted,
+// so a large file full of invalid rows doesn't pile its whole content up in memory.
+const maxRejectedRows = 1000
+
+// maxRejectedValueLength is the longest value of a rejected row kept on the report, the length of the device columns.
+const maxRejectedValueLength = 250
+
+// importFields are the device fields read from a CSV import, found through the header of the file.
+var importFields = []string{"name", "brand", "state"}
+
+// importColumns finds the column of every device field on the header, ignoring case and surrounding spaces.
+// Fields are looked for by their own name unless the request maps them to another one, e.g. nameColumn=Model.
+func importColumns(ctx *gin.Context, header []string) (map[string]int, []model.FieldError) {
+	positions := map[string]int{}
+	for i, title := range header {
+		// Spreadsheets tend to start their exports with a byte order mark
+		if i == 0 {
+			title = strings.TrimPrefix(title, "\ufeff")
+		}
+		positions[strings.ToLower(strings.TrimSpace(title))] = i
+	}
+
+	columns := map[string]int{}
+	var fieldErrors []model.FieldError
+	for _, field := range importFields {
+		title := ctx.DefaultQuery(field+"Column", field)
+		position, ok := positions[strings.ToLower(strings.TrimSpace(title))]
+		if !ok {
+			fieldErrors = append(fieldErrors, model.FieldError{Field: field + "Column", Code: fieldCodeRequired,
+				Message: fmt.Sprintf("the header has no %q column", title)})
+			continue
+		}
+		columns[field] = position
+	}
+	return columns, fieldErrors
+}
+
+// importRow is a valid row of a CSV import, waiting to be written.
+type importRow struct {
+	line   int
+	device model.Device
+}
+
+// importer writes the rows of a CSV import in batches, keeping its report up to date.
+type importer struct {
+	w      *Web
+	ctx    *gin.Context
+	upsert bool
+	rows   []importRow
+	report model.ImportReport
+}
+
+func (i *importer) reject(line int, device model.Device, reason string, fieldErrors ...model.FieldError) {
+	i.report.Rejected++
+	i.report.RejectedRows = append(i.report.RejectedRows, model.RejectedRow{
+		Line:   line,
+		Name:   truncate(device.Name, maxRejectedValueLength),
+		Brand:  truncate(device.Brand, maxRejectedValueLength),
+		State:  truncate(device.State, maxRejectedValueLength),
+		Reason: reason,
+		Errors: fieldErrors,
+	})
+	// Trimming only once twice as many rows piled up keeps it cheap, while bounding the memory used
+	if len(i.report.RejectedRows) >= 2*maxRejectedRows {
+		i.trimRejected()
+	}
+}
+
+// trimRejected sorts the rejected rows by line and keeps the first maxRejectedRows of them. Rows conflicting with
+// a device are only rejected once their batch is written, so they don't come in order.
+func (i *importer) trimRejected() {
+	slices.SortStableFunc(i.report.RejectedRows, func(a, b model.RejectedRow) int {
+		return a.Line - b.Line
+	})
+	if len(i.report.RejectedRows) > maxRejectedRows {
+		i.report.RejectedRows = i.report.RejectedRows[:maxRejectedRows]
+	}
+	i.report.RejectedRowsTruncated = i.report.Rejected > len(i.report.RejectedRows)
+}
+
+// add queues the row, writing the queued rows once there are enough of them.
+func (i *importer) add(row importRow) error {
+	i.rows = append(i.rows, row)
+	if len(i.rows) < importBatchSize {
+		return nil
+	}
+	return i.flush()
+}
+
+// flush writes the queued rows. Only failures of the store are returned, after recording the line of the row
+// they stopped at on the report, rows are rejected otherwise.
+func (i *importer) flush() error {
+	if len(i.rows) == 0 {
+		return nil
+	}
+	devices := make([]database.Device, 0, len(i.rows))
+	for _, row := range i.rows {
+		newDevice := model.Device{Name: row.device.Name, Brand: row.device.Brand, State: row.device.State}
+		devices = append(devices, newDevice.TranslateToDB())
+	}
+
+	// The whole batch is rolled back when the write fails
+	errs, err := i.w.DB.CreateDevices(i.ctx.Request.Context(), devices, db.BulkBestEffort)
+	if err != nil {
+		i.report.FailedLine = i.rows[0].line
+		return err
+	}
+	// Created devices are counted first, as they are already written even if an update fails below
+	for _, err := range errs {
+		if err == nil {
+			i.report.Created++
+		}
+	}
+	for k, err := range errs {
+		switch {
+		case err == nil:
+		case errors.Is(err, db.ErrConflict) && i.upsert:
+			if err := i.update(i.rows[k]); err != nil {
+				i.report.FailedLine = i.rows[k].line
+				return err
+			}
+		default:
+			i.reject(i.rows[k].line, i.rows[k].device, err.Error())
+		}
+	}
+	i.rows = i.rows[:0]
+	return nil
+}
+
+// respondFailure writes the report of an import stopped by a failure of the store, so the client knows what was
+// imported and which rows to send again. The error itself is only logged, as respondError does.
+func (i *importer) respondFailure(err error) {
+	status, _ := errorStatus(err)
+	i.report.Error = err.Error()
+	if status == http.StatusInternalServerError {
+		log.Printf("%s %s failed at line %d: %v", i.ctx.Request.Method, i.ctx.Request.URL.Path, i.report.FailedLine, err)
+		i.report.Error = "an unexpected error occurred while writing the devices"
+	}
+	i.trimRejected()
+	respond(i.ctx, status, i.report)
+}
+
+// update moves the live device with the name and brand of the row to its state, following the same rules as PUT.
+func (i *importer) update(row importRow) error {
+	devices, err := i.w.DB.GetDevices(i.ctx.Request.Context(), db.DeviceQuery{
+		Filter: &db.Filter{Filters: []db.Filter{
+			{Column: db.ColumnName, Op: db.FilterEqual, Values: []string{row.device.Name}},
+			{Column: db.ColumnBrand, Op: db.FilterEqual, Values: []string{row.device.Brand}},
+		}},
+		Limit: 1,
+	})
+	if err != nil {
+		return err
+	}
+	// Deleted since the conflict was found
+	if len(devices) == 0 {
+		i.reject(row.line, row.device, db.ErrNotFound.Error())
+		return nil
+	}
+
+	device := devices[0]
+	if device.State == row.device.State {
+		i.report.Unchanged++
+		return nil
+	}
+	if err := i.w.checkTransition(device, row.device.State); err != nil {
+		i.reject(row.line, row.device, err.Error())
+		return nil
+	}
+	device.State = row.device.State
+	if err := i.w.DB.UpdateDevice(i.ctx.Request.Context(), &device); err != nil {
+		if status, _ := errorStatus(err); status == http.StatusInternalServerError {
+			return err
+		}
+		i.reject(row.line, row.device, err.Error())
+		return nil
+	}
+	i.report.Updated++
+	return nil
+}
+
+// respondImport writes the report of the import, as a CSV file of the rejected rows when the client asks for text/csv.
+func respondImport(ctx *gin.Context, report model.ImportReport) {
+	if ctx.GetString(formatKey) != csvContentType {
+		respond(ctx, http.StatusOK, report)
+		return
+	}
+
+	ctx.Header("Content-Type", csvContentType+"; charset=utf-8")
+	ctx.Header("Content-Disposition", `attachment; filename="import-report.csv"`)
+	ctx.Status(http.StatusOK)
+	writer := csv.NewWriter(ctx.Writer)
+	_ = writer.Write([]string{"line", "name", "brand", "state", "reason"})
+	for _, row := range report.RejectedRows {
+		_ = writer.Write([]string{strconv.Itoa(row.Line), row.Name, row.Brand, row.State, row.Reason})
+	}
+	writer.Flush()
+}
+
+// importDevices
+// accepts the following query parameters:
+// - upsert: true to update the state of the live devices with the same name and brand, instead of rejecting them
+// - nameColumn, brandColumn and stateColumn: header of the column holding each field (default: name, brand and state)
+//
+// @Summary      Import devices from a CSV file
+// @Description  Create the devices listed on a CSV file, one per row, whose header tells which columns hold the name,
+// @Description  brand and state (other columns are ignored). The file is read as it is received and written in
+// @Description  batches, each row following the same rules as POST /device. Invalid rows, and the ones conflicting with
+// @Description  a live device, are rejected while the others are imported. With upsert, conflicting rows update the
+// @Description  state of the device instead, following its allowed transitions. The report lists the first 1000
+// @Description  rejected rows and why, downloadable as a CSV file by asking for text/csv. If the store fails, the
+// @Description  import stops and the report of what was written so far is sent with failedLine, the line to resume from.
+// @Tags         devices
+// @Accept       text/csv
+// @Produce      json,xml,application/msgpack,application/yaml,text/csv
+// @Param        upsert       query     bool    false  "Update the state of existing devices with the same name and brand"
+// @Param        nameColumn   query     string  false  "Header of the name column (default: name)"
+// @Param        brandColumn  query     string  false  "Header of the brand column (default: brand)"
+// @Param        stateColumn  query     string  false  "Header of the state column (default: state)"
+// @Param        file         body      string  true   "CSV file, starting with its header"
+// @Success      200          {object}  model.ImportReport
+// @Failure      400          {object}  model.Problem
+// @Failure      406          {object}  model.Problem
+// @Failure      415          {object}  model.Problem
+// @Failure      500          {object}  model.ImportReport  "The store failed, reporting what was imported before"
+// @Router       /device/import [post]
+func (w *Web) importDevices(ctx *gin.Context) {
+	if contentType := ctx.ContentType(); contentType != csvContentType {
+		respondProblem(ctx, http.StatusUnsupportedMediaType, codeUnsupportedMedia,
+			fmt.Sprintf("unsupported content type %q, use %s", contentType, csvContentType))
+		return
+	}
+
+	upsert := false
+	if value := ctx.Query("upsert"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			message := "upsert must be true or false"
+			respondProblem(ctx, http.StatusBadRequest, codeInvalidQuery, message,
+				model.FieldError{Field: "upsert", Code: fieldCodeInvalid, Message: message})
+			return
+		}
+		upsert = parsed
+	}
+
+	// Rows are read one at a time, so the file never has to fit in memory
+	reader := csv.NewReader(ctx.Request.Body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		respondProblem(ctx, http.StatusBadRequest, codeInvalidBody, "the file is empty, a header is required")
+		return
+	}
+	if err != nil {
+		respondProblem(ctx, http.StatusBadRequest, codeInvalidBody, err.Error())
+		return
+	}
+	columns, fieldErrors := importColumns(ctx, header)
+	if len(fieldErrors) > 0 {
+		respondValidation(ctx, "the header must name the name, brand and state columns", fieldErrors...)
+		return
+	}
+
+	imp := &importer{w: w, ctx: ctx, upsert: upsert, report: model.ImportReport{RejectedRows: []model.RejectedRow{}}}
+	field := func(record []string, name string) string {
+		if position := columns[name]; position < len(record) {
+			return strings.TrimSpace(record[position])
+		}
+		return ""
+	}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		// Malformed rows are rejected, the following ones are still read
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			imp.reject(parseErr.StartLine, model.Device{}, parseErr.Err.Error())
+			continue
+		}
+		if err != nil {
+			respondProblem(ctx, http.StatusBadRequest, codeInvalidBody, err.Error())
+			return
+		}
+
+		line, _ := reader.FieldPos(0)
+		device := model.Device{Name: field(record, "name"), Brand: field(record, "brand"), State: field(record, "state")}
+		if detail, fieldErrors := validateNewDevice(device); detail != "" {
+			imp.reject(line, device, detail, fieldErrors...)
+			continue
+		}
+		if err := imp.add(importRow{line: line, device: device}); err != nil {
+			imp.respondFailure(err)
+			return
+		}
+	}
+	if err := imp.flush(); err != nil {
+		imp.respondFailure(err)
+		return
+	}
+	imp.trimRejected()
+
+	respondImport(ctx, imp.report)
+}
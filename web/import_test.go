@@ -0,0 +1,230 @@
+package web
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/lcmps/DevicesAPI/db"
+	"github.com/lcmps/DevicesAPI/model"
+	"github.com/lcmps/DevicesAPI/model/database"
+)
+
+func doImport(w *Web, query, file, contentType, accept string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(http.MethodPost, "/api/device/import"+query, strings.NewReader(file))
+	req.Header.Set("Content-Type", contentType)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	rec := httptest.NewRecorder()
+	w.Router.ServeHTTP(rec, req)
+	return rec
+}
+
+func decodeImportReport(t *testing.T, rec *httptest.ResponseRecorder) model.ImportReport {
+	var report model.ImportReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("failed to unmarshal report: %v", err)
+	}
+	return report
+}
+
+// failingImport is a store whose bulk creations fail after the given number of batches.
+type failingImport struct {
+	*mockStore
+	batches int
+}
+
+func (s *failingImport) CreateDevices(ctx context.Context, devices []database.Device, mode db.BulkMode) ([]error, error) {
+	if s.batches == 0 {
+		return nil, errors.New("connection reset")
+	}
+	s.batches--
+	return s.mockStore.CreateDevices(ctx, devices, mode)
+}
+
+func TestImportDevices(t *testing.T) {
+	file := "\ufeffName, Brand ,State,Notes\n" +
+		"Delta,BrandD,Available,new\n" +
+		"Alpha,BrandA,In-Use,taken\n" +
+		"Epsilon,,Available,no brand\n" +
+		"Zeta,BrandZ,Broken,bad state\n" +
+		"\"Eta, the second\",BrandE,Inactive,quoted\n" +
+		"Theta,BrandT\n" +
+		"Delta,BrandD,Available,repeated\n"
+
+	t.Run("Report", func(t *testing.T) {
+		w := newTestWeb(seededStore())
+		rec := doImport(w, "", file, "text/csv; charset=utf-8", "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		report := decodeImportReport(t, rec)
+		assert.Equal(t, 2, report.Created)
+		assert.Equal(t, 5, report.Rejected)
+
+		var lines []int
+		for _, row := range report.RejectedRows {
+			lines = append(lines, row.Line)
+		}
+		assert.Equal(t, []int{3, 4, 5, 7, 8}, lines)
+		assert.Equal(t, db.ErrConflict.Error(), report.RejectedRows[0].Reason)
+		assert.Equal(t, "brand", report.RejectedRows[1].Errors[0].Field)
+		assert.Equal(t, "Broken", report.RejectedRows[2].State)
+		assert.Equal(t, invalidStateMessage, report.RejectedRows[2].Reason)
+		assert.Equal(t, "state", report.RejectedRows[3].Errors[0].Field)
+	})
+
+	t.Run("Upsert", func(t *testing.T) {
+		store := seededStore()
+		w := newTestWeb(store)
+		file := "name,brand,state\n" +
+			"Alpha,BrandA,In-Use\n" +
+			"Beta,BrandB,In-Use\n" +
+			"Gamma,BrandA,In-Use\n" +
+			"Delta,BrandD,Available\n"
+		rec := doImport(w, "?upsert=true", file, "text/csv", "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		report := decodeImportReport(t, rec)
+		assert.Equal(t, 1, report.Created)
+		assert.Equal(t, 1, report.Updated)
+		assert.Equal(t, 1, report.Unchanged)
+		assert.Equal(t, 1, report.Rejected)
+		assert.Equal(t, 3, report.RejectedRows[0].Line)
+		assert.Equal(t, "In-Use", store.devices[0].State)
+		assert.Equal(t, "Inactive", store.devices[1].State)
+	})
+
+	t.Run("HeaderMapping", func(t *testing.T) {
+		w := newTestWeb(db.NewMemory())
+		rec := doImport(w, "?nameColumn=Model&brandColumn=Maker", "Model,Maker,state\nDelta,BrandD,Available\n", "text/csv", "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, 1, decodeImportReport(t, rec).Created)
+	})
+
+	t.Run("ManyBatches", func(t *testing.T) {
+		store := db.NewMemory()
+		w := newTestWeb(store)
+		var file strings.Builder
+		file.WriteString("name,brand,state\n")
+		for i := range importBatchSize*2 + 10 {
+			fmt.Fprintf(&file, "Device %d,BrandA,Available\n", i%(importBatchSize+5))
+		}
+		report := decodeImportReport(t, doImport(w, "", file.String(), "text/csv", ""))
+		assert.Equal(t, importBatchSize+5, report.Created)
+		assert.Equal(t, importBatchSize+5, report.Rejected)
+		count, _, _ := store.CountDevices(t.Context(), db.DeviceQuery{}, db.CountExact)
+		assert.Equal(t, int64(importBatchSize+5), count)
+	})
+
+	t.Run("TooManyRejections", func(t *testing.T) {
+		var file strings.Builder
+		file.WriteString("name,brand,state\n")
+		for i := range maxRejectedRows*2 + 10 {
+			fmt.Fprintf(&file, "Device %d,BrandA,Broken\n", i)
+		}
+		report := decodeImportReport(t, doImport(newTestWeb(db.NewMemory()), "", file.String(), "text/csv", ""))
+		assert.Equal(t, maxRejectedRows*2+10, report.Rejected)
+		assert.Equal(t, maxRejectedRows, len(report.RejectedRows))
+		assert.Equal(t, true, report.RejectedRowsTruncated)
+		assert.Equal(t, 2, report.RejectedRows[0].Line)
+		assert.Equal(t, maxRejectedRows+1, report.RejectedRows[maxRejectedRows-1].Line)
+
+		// Long values are cut
+		report = decodeImportReport(t, doImport(newTestWeb(db.NewMemory()), "", "name,brand,state\n"+strings.Repeat("x", 1000)+",BrandA,Broken\n", "text/csv", ""))
+		assert.Equal(t, maxRejectedValueLength, len(report.RejectedRows[0].Name))
+		assert.Equal(t, false, report.RejectedRowsTruncated)
+	})
+
+	t.Run("MalformedRow", func(t *testing.T) {
+		w := newTestWeb(db.NewMemory())
+		rec := doImport(w, "", "name,brand,state\nDel\"ta,BrandD,Available\nEta,BrandE,Available\n", "text/csv", "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		report := decodeImportReport(t, rec)
+		assert.Equal(t, 1, report.Created)
+		assert.Equal(t, 2, report.RejectedRows[0].Line)
+	})
+
+	t.Run("DownloadReport", func(t *testing.T) {
+		w := newTestWeb(seededStore())
+		rec := doImport(w, "", file, "text/csv", "text/csv")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="import-report.csv"`, rec.Header().Get("Content-Disposition"))
+
+		rows, err := csv.NewReader(rec.Body).ReadAll()
+		if err != nil {
+			t.Fatalf("failed to read report: %v", err)
+		}
+		assert.Equal(t, 6, len(rows))
+		assert.Equal(t, []string{"line", "name", "brand", "state", "reason"}, rows[0])
+		assert.Equal(t, []string{"3", "Alpha", "BrandA", "In-Use", db.ErrConflict.Error()}, rows[1])
+	})
+
+	invalid := []struct {
+		name        string
+		query       string
+		file        string
+		contentType string
+		wantStatus  int
+	}{
+		{"NotCSV", "", "name,brand,state\n", "application/json", http.StatusUnsupportedMediaType},
+		{"Empty", "", "", "text/csv", http.StatusBadRequest},
+		{"MissingColumn", "", "name,state\nDelta,Available\n", "text/csv", http.StatusBadRequest},
+		{"UnknownMapping", "?stateColumn=Status", "name,brand,state\n", "text/csv", http.StatusBadRequest},
+		{"InvalidUpsert", "?upsert=sometimes", "name,brand,state\n", "text/csv", http.StatusBadRequest},
+	}
+	for _, c := range invalid {
+		t.Run(c.name, func(t *testing.T) {
+			w := newTestWeb(seededStore())
+			rec := doImport(w, c.query, c.file, c.contentType, "")
+			assert.Equal(t, c.wantStatus, rec.Code)
+		})
+	}
+
+	t.Run("StoreError", func(t *testing.T) {
+		w := newTestWeb(&mockStore{createErr: errors.New("connection refused")})
+		rec := doImport(w, "", file, "text/csv", "")
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		report := decodeImportReport(t, rec)
+		assert.Equal(t, 0, report.Created)
+		assert.Equal(t, 2, report.FailedLine)
+		assert.Equal(t, "an unexpected error occurred while writing the devices", report.Error)
+	})
+
+	t.Run("StoreErrorMidway", func(t *testing.T) {
+		// The first batch is written, along with a rejected row, before the second one fails
+		var file strings.Builder
+		file.WriteString("name,brand,state\nBroken,BrandA,Broken\n")
+		for i := range importBatchSize + 10 {
+			fmt.Fprintf(&file, "Device %d,BrandA,Available\n", i)
+		}
+		store := &failingImport{mockStore: &mockStore{}, batches: 1}
+		rec := doImport(newTestWeb(store), "", file.String(), "text/csv", "text/csv")
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Equal(t, "application/json; charset=utf-8", rec.Header().Get("Content-Type"))
+		report := decodeImportReport(t, rec)
+		assert.Equal(t, importBatchSize, report.Created)
+		assert.Equal(t, 1, report.Rejected)
+		assert.Equal(t, 2, report.RejectedRows[0].Line)
+		assert.Equal(t, importBatchSize+3, report.FailedLine)
+		assert.Equal(t, importBatchSize, len(store.devices))
+	})
+
+	t.Run("UpsertStoreError", func(t *testing.T) {
+		store := seededStore()
+		store.updateErr = errors.New("connection reset")
+		rec := doImport(newTestWeb(store), "?upsert=true", "name,brand,state\nDelta,BrandD,Available\nAlpha,BrandA,Inactive\nEta,BrandE,Available\n", "text/csv", "")
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		report := decodeImportReport(t, rec)
+		// Devices after the failing row were created along with the batch
+		assert.Equal(t, 2, report.Created)
+		assert.Equal(t, 0, report.Updated)
+		assert.Equal(t, 3, report.FailedLine)
+	})
+}
@@ -0,0 +1,143 @@
+package web
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/gin-gonic/gin/render"
+)
+
+const problemXMLContentType = "application/problem+xml"
+
+// representations are the media types responses and request bodies can use, JSON being the default.
+var representations = []string{
+	binding.MIMEJSON,
+	binding.MIMEXML, binding.MIMEXML2,
+	binding.MIMEMSGPACK, binding.MIMEMSGPACK2,
+	binding.MIMEYAML, binding.MIMEYAML2,
+}
+
+// formatKey holds the media type negotiated for the response on the request context.
+const formatKey = "format"
+
+// negotiate picks the media type of the response among offers from the Accept header, before the handler
+// runs so nothing is done for a client that can't read the response. It responds with 406 when none fits.
+func negotiate(offers ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Writer.Header().Add("Vary", "Accept")
+		format := ctx.NegotiateFormat(offers...)
+		if format == "" {
+			respondProblem(ctx, http.StatusNotAcceptable, codeNotAcceptable,
+				fmt.Sprintf("unsupported Accept header %q, use one of: %s", ctx.GetHeader("Accept"), strings.Join(offers, ", ")))
+			ctx.Abort()
+			return
+		}
+		ctx.Set(formatKey, format)
+		ctx.Next()
+	}
+}
+
+// representation is the media type to respond with: the negotiated one, or the best one the client accepts on
+// routes negotiating other types (e.g. problems of exports), falling back on JSON.
+func representation(ctx *gin.Context) string {
+	format := ctx.GetString(formatKey)
+	if !slices.Contains(representations, format) {
+		format = ctx.NegotiateFormat(representations...)
+	}
+	if format == "" {
+		return binding.MIMEJSON
+	}
+	return format
+}
+
+// respond writes obj with the given status code, in the representation negotiated with the client.
+func respond(ctx *gin.Context, status int, obj any) {
+	format := representation(ctx)
+
+	var r render.Render
+	switch format {
+	case binding.MIMEXML, binding.MIMEXML2:
+		r = xmlRender{Data: obj}
+	case binding.MIMEMSGPACK, binding.MIMEMSGPACK2:
+		r = render.MsgPack{Data: obj}
+	case binding.MIMEYAML, binding.MIMEYAML2:
+		r = render.YAML{Data: obj}
+	default:
+		r = render.JSON{Data: obj}
+	}
+
+	// Responses are labelled with the exact type the client asked for, e.g. text/xml
+	if ctx.Writer.Header().Get("Content-Type") == "" {
+		contentType := format
+		if format != binding.MIMEMSGPACK && format != binding.MIMEMSGPACK2 {
+			contentType += "; charset=utf-8"
+		}
+		ctx.Header("Content-Type", contentType)
+	}
+	ctx.Render(status, r)
+}
+
+// xmlRender writes Data as an XML document whose root element is named after its type in camel case,
+// e.g. deviceList, the same way as the elements inside it.
+type xmlRender struct {
+	Data any
+}
+
+func (r xmlRender) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	name := reflect.Indirect(reflect.ValueOf(r.Data)).Type().Name()
+	if name == "" {
+		name = "response"
+	}
+	root := xml.StartElement{Name: xml.Name{Local: strings.ToLower(name[:1]) + name[1:]}}
+	return xml.NewEncoder(w).EncodeElement(r.Data, root)
+}
+
+func (r xmlRender) WriteContentType(w http.ResponseWriter) {
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", binding.MIMEXML+"; charset=utf-8")
+	}
+}
+
+// bodyBinding picks the decoder of the request body from its Content-Type, JSON when there is none.
+// It responds with 415 and returns false for any other media type.
+func bodyBinding(ctx *gin.Context) (binding.Binding, bool) {
+	switch contentType := ctx.ContentType(); contentType {
+	case "", binding.MIMEJSON:
+		return binding.JSON, true
+	case binding.MIMEXML, binding.MIMEXML2:
+		return binding.XML, true
+	case binding.MIMEMSGPACK, binding.MIMEMSGPACK2:
+		return binding.MsgPack, true
+	case binding.MIMEYAML, binding.MIMEYAML2:
+		return binding.YAML, true
+	default:
+		respondProblem(ctx, http.StatusUnsupportedMediaType, codeUnsupportedMedia,
+			fmt.Sprintf("unsupported content type %q, use one of: %s", contentType, strings.Join(representations, ", ")))
+		return nil, false
+	}
+}
+
+// bindBody decodes the request body into obj, whatever representation it was sent in.
+// It responds with 415 or 400 and returns false when the body can't be read.
+func bindBody(ctx *gin.Context, obj any) bool {
+	b, ok := bodyBinding(ctx)
+	if !ok {
+		return false
+	}
+	if err := ctx.ShouldBindWith(obj, b); err != nil {
+		respondProblem(ctx, http.StatusBadRequest, codeInvalidBody, err.Error())
+		return false
+	}
+	return true
+}
@@ -0,0 +1,273 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/goccy/go-yaml"
+	"github.com/lcmps/DevicesAPI/model"
+	"github.com/ugorji/go/codec"
+)
+
+func doNegotiated(w *Web, method, path, contentType, accept string, body []byte) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, bytes.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	rec := httptest.NewRecorder()
+	w.Router.ServeHTTP(rec, req)
+	return rec
+}
+
+func encodeMsgPack(t *testing.T, v any) []byte {
+	var buf bytes.Buffer
+	if err := codec.NewEncoder(&buf, new(codec.MsgpackHandle)).Encode(v); err != nil {
+		t.Fatalf("failed to encode msgpack: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func decodeMsgPack(t *testing.T, data []byte, v any) {
+	if err := codec.NewDecoderBytes(data, new(codec.MsgpackHandle)).Decode(v); err != nil {
+		t.Fatalf("failed to decode msgpack: %v", err)
+	}
+}
+
+func TestNegotiateResponse(t *testing.T) {
+	const alpha = "/api/device/3fa85f64-5717-4562-b3fc-2c963f66afa6"
+
+	t.Run("JSONByDefault", func(t *testing.T) {
+		for _, accept := range []string{"", "*/*", "application/*", "application/json"} {
+			rec := doNegotiated(newTestWeb(seededStore()), http.MethodGet, alpha, "", accept, nil)
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "application/json; charset=utf-8", rec.Header().Get("Content-Type"))
+			assert.Equal(t, "Accept", rec.Header().Get("Vary"))
+		}
+	})
+
+	t.Run("XML", func(t *testing.T) {
+		for _, accept := range []string{"application/xml", "text/xml"} {
+			rec := doNegotiated(newTestWeb(seededStore()), http.MethodGet, alpha, "", accept, nil)
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, accept+"; charset=utf-8", rec.Header().Get("Content-Type"))
+			assert.Equal(t, true, strings.HasPrefix(rec.Body.String(), xml.Header+"<device><id>3fa85f64-5717-4562-b3fc-2c963f66afa6</id><name>Alpha</name>"))
+
+			var dvc model.Device
+			if err := xml.Unmarshal(rec.Body.Bytes(), &dvc); err != nil {
+				t.Fatalf("failed to unmarshal xml: %v", err)
+			}
+			assert.Equal(t, "Alpha", dvc.Name)
+		}
+	})
+
+	t.Run("XMLList", func(t *testing.T) {
+		rec := doNegotiated(newTestWeb(seededStore()), http.MethodGet, "/api/device/?brand=BrandA", "", "application/xml", nil)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, true, strings.HasPrefix(rec.Body.String(), xml.Header+"<deviceList><total>2</total>"))
+
+		var list model.DeviceList
+		if err := xml.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+			t.Fatalf("failed to unmarshal xml: %v", err)
+		}
+		assert.Equal(t, 2, len(list.Devices))
+		assert.Equal(t, "Gamma", list.Devices[1].Name)
+		assert.Equal(t, int64(2), *list.Total)
+	})
+
+	t.Run("MsgPack", func(t *testing.T) {
+		for _, accept := range []string{"application/msgpack", "application/x-msgpack"} {
+			rec := doNegotiated(newTestWeb(seededStore()), http.MethodGet, alpha, "", accept, nil)
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, accept, rec.Header().Get("Content-Type"))
+
+			var dvc model.Device
+			decodeMsgPack(t, rec.Body.Bytes(), &dvc)
+			assert.Equal(t, "Alpha", dvc.Name)
+			assert.Equal(t, "3fa85f64-5717-4562-b3fc-2c963f66afa6", dvc.ID)
+		}
+	})
+
+	t.Run("YAML", func(t *testing.T) {
+		rec := doNegotiated(newTestWeb(seededStore()), http.MethodGet, "/api/device/search?q=Gamma&mode=fuzzy", "", "application/yaml", nil)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/yaml; charset=utf-8", rec.Header().Get("Content-Type"))
+
+		// Embedded fields are inlined, as they are in JSON
+		var results model.SearchResults
+		if err := yaml.Unmarshal(rec.Body.Bytes(), &results); err != nil {
+			t.Fatalf("failed to unmarshal yaml: %v", err)
+		}
+		assert.Equal(t, 1, len(results.Results))
+		assert.Equal(t, "Gamma", results.Results[0].Name)
+		assert.Equal(t, true, strings.Contains(rec.Body.String(), "\n  name: Gamma\n"))
+	})
+
+	t.Run("FirstAccepted", func(t *testing.T) {
+		rec := doNegotiated(newTestWeb(seededStore()), http.MethodGet, alpha, "", "text/html, application/xml;q=0.9, */*;q=0.8", nil)
+		assert.Equal(t, "application/xml; charset=utf-8", rec.Header().Get("Content-Type"))
+	})
+
+	t.Run("NotAcceptable", func(t *testing.T) {
+		store := seededStore()
+		body, _ := json.Marshal(model.Device{Name: "Delta", Brand: "BrandD", State: "Available"})
+		rec := doNegotiated(newTestWeb(store), http.MethodPost, "/api/device/", "application/json", "text/html", body)
+		assert.Equal(t, http.StatusNotAcceptable, rec.Code)
+		assert.Equal(t, problemContentType, rec.Header().Get("Content-Type"))
+
+		var problem model.Problem
+		_ = json.Unmarshal(rec.Body.Bytes(), &problem)
+		assert.Equal(t, codeNotAcceptable, problem.Code)
+		// Nothing is done for a client that can't read the response
+		assert.Equal(t, 3, len(store.devices))
+	})
+
+	t.Run("XMLProblem", func(t *testing.T) {
+		rec := doNegotiated(newTestWeb(seededStore()), http.MethodGet, "/api/device/00000000-0000-0000-0000-000000000000", "", "application/xml", nil)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, problemXMLContentType, rec.Header().Get("Content-Type"))
+
+		var problem model.Problem
+		if err := xml.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+			t.Fatalf("failed to unmarshal xml: %v", err)
+		}
+		assert.Equal(t, codeNotFound, problem.Code)
+		assert.Equal(t, http.StatusNotFound, problem.Status)
+	})
+
+	t.Run("MsgPackProblem", func(t *testing.T) {
+		rec := doNegotiated(newTestWeb(seededStore()), http.MethodGet, "/api/device/not-a-uuid", "", "application/msgpack", nil)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "application/msgpack", rec.Header().Get("Content-Type"))
+
+		var problem model.Problem
+		decodeMsgPack(t, rec.Body.Bytes(), &problem)
+		assert.Equal(t, codeInvalidID, problem.Code)
+	})
+
+	t.Run("UnknownRoute", func(t *testing.T) {
+		rec := doNegotiated(newTestWeb(seededStore()), http.MethodGet, "/api/nothing", "", "application/xml", nil)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, problemXMLContentType, rec.Header().Get("Content-Type"))
+	})
+
+	t.Run("Audit", func(t *testing.T) {
+		rec := doNegotiated(newTestWeb(seededStore()), http.MethodGet, "/api/audit", "", "application/xml", nil)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, true, strings.HasPrefix(rec.Body.String(), xml.Header+"<auditList>"))
+
+		rec = doNegotiated(newTestWeb(seededStore()), http.MethodGet, "/api/audit", "", "text/plain", nil)
+		assert.Equal(t, http.StatusNotAcceptable, rec.Code)
+	})
+
+	t.Run("ImportReport", func(t *testing.T) {
+		rec := doImport(newTestWeb(seededStore()), "", "name,brand,state\nDelta,BrandD,Available\n", csvContentType, "application/xml")
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var report model.ImportReport
+		if err := xml.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+			t.Fatalf("failed to unmarshal xml: %v", err)
+		}
+		assert.Equal(t, 1, report.Created)
+
+		rec = doImport(newTestWeb(seededStore()), "", "name,brand,state\n", csvContentType, "text/html")
+		assert.Equal(t, http.StatusNotAcceptable, rec.Code)
+	})
+
+	t.Run("ExportProblem", func(t *testing.T) {
+		rec := doExport(newTestWeb(seededStore()), "?format=xlsx", "application/xml")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, problemXMLContentType, rec.Header().Get("Content-Type"))
+	})
+}
+
+func TestNegotiateRequestBody(t *testing.T) {
+	t.Run("Create", func(t *testing.T) {
+		device := model.Device{Name: "Delta", Brand: "BrandD", State: "Available"}
+		yamlBody, _ := yaml.Marshal(device)
+		bodies := []struct {
+			contentType string
+			body        []byte
+		}{
+			{"application/xml", []byte("<device><name>Delta</name><brand>BrandD</brand><state>Available</state></device>")},
+			{"text/xml; charset=utf-8", []byte("<device><name>Delta</name><brand>BrandD</brand><state>Available</state></device>")},
+			{"application/x-msgpack", encodeMsgPack(t, device)},
+			{"application/msgpack", encodeMsgPack(t, device)},
+			{"application/yaml", yamlBody},
+			{"application/x-yaml", []byte("name: Delta\nbrand: BrandD\nstate: Available\n")},
+			{"", []byte(`{"name":"Delta","brand":"BrandD","state":"Available"}`)},
+		}
+		for _, b := range bodies {
+			store := &mockStore{}
+			rec := doNegotiated(newTestWeb(store), http.MethodPost, "/api/device/", b.contentType, "", b.body)
+			if rec.Code != http.StatusCreated {
+				t.Fatalf("expected status %d for %q, got %d: %s", http.StatusCreated, b.contentType, rec.Code, rec.Body.String())
+			}
+			assert.Equal(t, 1, len(store.devices))
+			assert.Equal(t, "Delta", store.devices[0].Name)
+			assert.Equal(t, "BrandD", store.devices[0].Brand)
+		}
+	})
+
+	t.Run("Replace", func(t *testing.T) {
+		store := seededStore()
+		body := []byte("<device><name>Alpha</name><brand>BrandA</brand><state>In-Use</state></device>")
+		rec := doNegotiated(newTestWeb(store), http.MethodPut, "/api/device/3fa85f64-5717-4562-b3fc-2c963f66afa6", "application/xml", "application/xml", body)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "In-Use", store.devices[0].State)
+
+		var dvc model.Device
+		_ = xml.Unmarshal(rec.Body.Bytes(), &dvc)
+		assert.Equal(t, "In-Use", dvc.State)
+	})
+
+	t.Run("Bulk", func(t *testing.T) {
+		store := &mockStore{}
+		body := []byte("<devices>" +
+			"<device><name>Delta</name><brand>BrandD</brand><state>Available</state></device>" +
+			"<device><name>Epsilon</name><brand>BrandD</brand><state>Inactive</state></device>" +
+			"</devices>")
+		rec := doNegotiated(newTestWeb(store), http.MethodPost, "/api/device/bulk", "application/xml", "application/xml", body)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, 2, len(store.devices))
+		assert.Equal(t, "Epsilon", store.devices[1].Name)
+		assert.Equal(t, true, strings.HasPrefix(rec.Body.String(), xml.Header+"<bulkResults><created>2</created>"))
+
+		// The body of a bulk deletion is optional whatever its type
+		rec = doNegotiated(newTestWeb(seededStore()), http.MethodPost, "/api/device/bulk/delete?brand=BrandB", "application/xml", "", nil)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("UnsupportedMediaType", func(t *testing.T) {
+		store := seededStore()
+		for _, path := range []string{"/api/device/", "/api/device/bulk"} {
+			rec := doNegotiated(newTestWeb(store), http.MethodPost, path, "text/plain", "application/xml", []byte("Delta"))
+			assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+			assert.Equal(t, problemXMLContentType, rec.Header().Get("Content-Type"))
+
+			var problem model.Problem
+			_ = xml.Unmarshal(rec.Body.Bytes(), &problem)
+			assert.Equal(t, codeUnsupportedMedia, problem.Code)
+		}
+		rec := doNegotiated(newTestWeb(store), http.MethodPut, "/api/device/3fa85f64-5717-4562-b3fc-2c963f66afa6", "application/x-www-form-urlencoded", "", []byte("name=Alpha"))
+		assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+		assert.Equal(t, 3, len(store.devices))
+	})
+
+	t.Run("Malformed", func(t *testing.T) {
+		for _, contentType := range []string{"application/xml", "application/msgpack", "application/yaml"} {
+			rec := doNegotiated(newTestWeb(&mockStore{}), http.MethodPost, "/api/device/", contentType, "", []byte("<device><name>"))
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("expected status %d for %s, got %d", http.StatusBadRequest, contentType, rec.Code)
+			}
+		}
+	})
+}
@@ -0,0 +1,117 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/gin-gonic/gin"
+	"github.com/lcmps/DevicesAPI/model"
+)
+
+// Media types accepted by PATCH, RFC 7396 and RFC 6902 respectively
+const (
+	mergePatchContentType = "application/merge-patch+json"
+	jsonPatchContentType  = "application/json-patch+json"
+)
+
+// errMalformedPatch is returned when the patch document itself can't be parsed.
+var errMalformedPatch = errors.New("malformed patch document")
+
+// applyPatch applies a merge patch or a JSON patch, depending on the content type, to a JSON document.
+func applyPatch(contentType string, document, patch []byte) ([]byte, error) {
+	if contentType == mergePatchContentType {
+		if !json.Valid(patch) {
+			return nil, errMalformedPatch
+		}
+		return jsonpatch.MergePatch(document, patch)
+	}
+
+	operations, err := jsonpatch.DecodePatch(patch)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errMalformedPatch, err)
+	}
+	return operations.Apply(document)
+}
+
+// @Summary      Partially update a device
+// @Description  Apply a JSON Merge Patch (application/merge-patch+json) or a JSON Patch (application/json-patch+json)
+// @Description  to the device representation. The result must be a valid device, following the same rules as PUT.
+// @Description  Failed JSON Patch "test" operations are rejected with 409.
+// @Tags         devices
+// @Accept       application/merge-patch+json,application/json-patch+json
+// @Produce      json,xml,application/msgpack,application/yaml
+// @Param        id        path      string        true   "Device ID"
+// @Param        If-Match  header    string        false  "ETag of the device, the update fails with 412 if it changed"
+// @Param        patch     body      model.Device  true   "Merge patch document or list of JSON Patch operations"
+// @Success      200       {object}  model.Device
+// @Header       200       {string}  ETag  "Version of the device"
+// @Failure      400       {object}  model.Problem
+// @Failure      404       {object}  model.Problem
+// @Failure      406       {object}  model.Problem
+// @Failure      409       {object}  model.Problem
+// @Failure      412       {object}  model.Problem
+// @Failure      415       {object}  model.Problem
+// @Failure      422       {object}  model.Problem
+// @Failure      428       {object}  model.Problem
+// @Failure      500       {object}  model.Problem
+// @Router       /device/{id} [patch]
+func (w *Web) patchDevice(ctx *gin.Context) {
+	contentType := ctx.ContentType()
+	if contentType != mergePatchContentType && contentType != jsonPatchContentType {
+		respondProblem(ctx, http.StatusUnsupportedMediaType, codeUnsupportedMedia,
+			fmt.Sprintf("unsupported content type %q, use %s or %s", contentType, mergePatchContentType, jsonPatchContentType))
+		return
+	}
+
+	patch, err := ctx.GetRawData()
+	if err != nil {
+		respondProblem(ctx, http.StatusBadRequest, codeInvalidBody, err.Error())
+		return
+	}
+
+	device, err := w.DB.GetDeviceByID(ctx.Request.Context(), ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	if !w.checkIfMatch(ctx, device) {
+		return
+	}
+
+	// The patch is applied to the same representation returned by GET
+	var current model.Device
+	current.TranslateToAPI(device)
+	document, err := json.Marshal(current)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	patched, err := applyPatch(contentType, document, patch)
+	switch {
+	case errors.Is(err, errMalformedPatch):
+		respondProblem(ctx, http.StatusBadRequest, codeInvalidBody, err.Error())
+		return
+	case errors.Is(err, jsonpatch.ErrTestFailed):
+		respondProblem(ctx, http.StatusConflict, codePatchTestFailed, err.Error())
+		return
+	case err != nil:
+		respondProblem(ctx, http.StatusUnprocessableEntity, codeInvalidPatch, "the patch can't be applied to the device: "+err.Error())
+		return
+	}
+
+	// Anything that isn't a device field, or has the wrong type, is refused
+	var replacement model.Device
+	decoder := json.NewDecoder(bytes.NewReader(patched))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&replacement); err != nil {
+		respondValidation(ctx, "the patched document is not a valid device: "+err.Error())
+		return
+	}
+
+	w.replaceDevice(ctx, device, replacement)
+}
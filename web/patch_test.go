@@ -0,0 +1,164 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/lcmps/DevicesAPI/model"
+)
+
+// doPatch sends a raw patch document with the given content type.
+func doPatch(w *Web, path, contentType, patch string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(http.MethodPatch, path, strings.NewReader(patch))
+	req.Header.Set("Content-Type", contentType)
+	rec := httptest.NewRecorder()
+	w.Router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestPatchDevice(t *testing.T) {
+	const (
+		alpha = "/api/device/3fa85f64-5717-4562-b3fc-2c963f66afa6"
+		gamma = "/api/device/5fa85f64-5717-4562-b3fc-2c963f66afa8"
+	)
+
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		patch       string
+		wantStatus  int
+		wantCode    string
+		wantDevice  model.Device
+	}{
+		{
+			name:        "Merge patch",
+			path:        alpha,
+			contentType: mergePatchContentType,
+			patch:       `{"state": "Inactive"}`,
+			wantStatus:  http.StatusOK,
+			wantDevice:  model.Device{Name: "Alpha", Brand: "BrandA", State: "Inactive"},
+		},
+		{
+			name:        "Merge patch removing a required field",
+			path:        alpha,
+			contentType: mergePatchContentType,
+			patch:       `{"brand": null}`,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    codeValidation,
+		},
+		{
+			name:        "Merge patch with unknown field",
+			path:        alpha,
+			contentType: mergePatchContentType,
+			patch:       `{"color": "red"}`,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    codeValidation,
+		},
+		{
+			name:        "Merge patch on read-only field",
+			path:        alpha,
+			contentType: mergePatchContentType,
+			patch:       `{"createdAt": "2020-01-01T00:00:00Z"}`,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    codeValidation,
+		},
+		{
+			name:        "Malformed merge patch",
+			path:        alpha,
+			contentType: mergePatchContentType,
+			patch:       `{"state": `,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    codeInvalidBody,
+		},
+		{
+			name:        "JSON patch",
+			path:        alpha,
+			contentType: jsonPatchContentType,
+			patch:       `[{"op": "test", "path": "/state", "value": "Available"}, {"op": "replace", "path": "/name", "value": "Alpha 2"}]`,
+			wantStatus:  http.StatusOK,
+			wantDevice:  model.Device{Name: "Alpha 2", Brand: "BrandA", State: "Available"},
+		},
+		{
+			name:        "JSON patch failed test",
+			path:        alpha,
+			contentType: jsonPatchContentType,
+			patch:       `[{"op": "test", "path": "/state", "value": "In-Use"}, {"op": "replace", "path": "/name", "value": "Alpha 2"}]`,
+			wantStatus:  http.StatusConflict,
+			wantCode:    codePatchTestFailed,
+		},
+		{
+			name:        "JSON patch on missing path",
+			path:        alpha,
+			contentType: jsonPatchContentType,
+			patch:       `[{"op": "replace", "path": "/color", "value": "red"}]`,
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantCode:    codeInvalidPatch,
+		},
+		{
+			name:        "Malformed JSON patch",
+			path:        alpha,
+			contentType: jsonPatchContentType,
+			patch:       `{"op": "replace"}`,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    codeInvalidBody,
+		},
+		{
+			name:        "Rename in use",
+			path:        gamma,
+			contentType: jsonPatchContentType,
+			patch:       `[{"op": "replace", "path": "/name", "value": "Delta"}]`,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    codeStateViolation,
+		},
+		{
+			name:        "Transition not allowed",
+			path:        "/api/device/4fa85f64-5717-4562-b3fc-2c963f66afa7",
+			contentType: mergePatchContentType,
+			patch:       `{"state": "In-Use"}`,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    codeStateViolation,
+		},
+		{
+			name:        "Not found",
+			path:        "/api/device/00000000-0000-0000-0000-000000000000",
+			contentType: mergePatchContentType,
+			patch:       `{"state": "Inactive"}`,
+			wantStatus:  http.StatusNotFound,
+			wantCode:    codeNotFound,
+		},
+		{
+			name:        "Unsupported content type",
+			path:        alpha,
+			contentType: "application/json",
+			patch:       `{"state": "Inactive"}`,
+			wantStatus:  http.StatusUnsupportedMediaType,
+			wantCode:    codeUnsupportedMedia,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := doPatch(newTestWeb(seededStore()), tc.path, tc.contentType, tc.patch)
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tc.wantStatus, rec.Code, rec.Body.String())
+			}
+			if tc.wantCode != "" {
+				var resp model.Problem
+				_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+				assert.Equal(t, tc.wantCode, resp.Code)
+				return
+			}
+			var dvc model.Device
+			_ = json.Unmarshal(rec.Body.Bytes(), &dvc)
+			assert.Equal(t, tc.wantDevice.Name, dvc.Name)
+			assert.Equal(t, tc.wantDevice.Brand, dvc.Brand)
+			assert.Equal(t, tc.wantDevice.State, dvc.State)
+			assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lcmps/DevicesAPI/db"
+	"github.com/lcmps/DevicesAPI/model"
+)
+
+// Values of the mode query parameter of the device search
+const (
+	searchModeFuzzy = "fuzzy"
+	searchModeText  = "text"
+)
+
+// searchDevices
+// accepts the following query parameters:
+// - q: text to look for on device names and brands (required)
+// - mode: fuzzy (default), comparing the whole text through its trigram similarity, or text,
+// a full-text search on words, "quoted phrases", prefixes* and -negations
+// - threshold: similarity a device must reach to be found by a fuzzy search, between 0 and 1 (default: 0.3 unless configured)
+// - limit: number of records to return (default: 50, at most 500)
+// - start: starting index (default: 0)
+//
+// @Summary      Search devices
+// @Description  Search devices by name and brand, best match first. Deleted devices are never found.
+// @Description  Fuzzy searches (the default) find devices despite typos, scoring them by trigram similarity, from 0 to 1.
+// @Description  Full-text searches (mode=text) look for every word, "quoted phrase" and prefix* given, and none
+// @Description  of the -negated ones, returning the name and brand with the matches highlighted through <mark> tags.
+// @Tags         devices
+// @Produce      json,xml,application/msgpack,application/yaml
+// @Param        q          query     string  true   "Text to look for, e.g. Gamm, or \"gamma ray\" scan* -acme on full-text searches"
+// @Param        mode       query     string  false  "fuzzy (default) or text"
+// @Param        threshold  query     number  false  "Similarity a device must reach on fuzzy searches, between 0 and 1 (default: 0.3)"
+// @Param        limit      query     int     false  "Number of records to return (default: 50, at most 500)"
+// @Param        start      query     int     false  "Starting index (default: 0)"
+// @Success      200        {object}  model.SearchResults
+// @Failure      400        {object}  model.Problem
+// @Failure      406        {object}  model.Problem
+// @Failure      500        {object}  model.Problem
+// @Router       /device/search [get]
+func (w *Web) searchDevices(ctx *gin.Context) {
+	text := ctx.Query("q")
+	if text == "" {
+		respondProblem(ctx, http.StatusBadRequest, codeInvalidQuery, "q is required",
+			model.FieldError{Field: "q", Code: fieldCodeRequired, Message: "q is required"})
+		return
+	}
+
+	mode := db.SearchFuzzy
+	switch ctx.DefaultQuery("mode", searchModeFuzzy) {
+	case searchModeFuzzy:
+	case searchModeText:
+		mode = db.SearchText
+	default:
+		message := "mode must be one of: fuzzy, text"
+		respondProblem(ctx, http.StatusBadRequest, codeInvalidQuery, message,
+			model.FieldError{Field: "mode", Code: fieldCodeInvalid, Message: message})
+		return
+	}
+
+	threshold := w.searchThreshold()
+	if value := ctx.Query("threshold"); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed <= 0 || parsed > 1 {
+			message := "threshold must be a number greater than 0, up to 1"
+			respondProblem(ctx, http.StatusBadRequest, codeInvalidQuery, message,
+				model.FieldError{Field: "threshold", Code: fieldCodeInvalid, Message: message})
+			return
+		}
+		threshold = parsed
+	}
+
+	limit, start, ok := pagination(ctx)
+	if !ok {
+		return
+	}
+
+	// One more result than asked for tells whether there's another page
+	found, err := w.DB.SearchDevices(ctx.Request.Context(), db.SearchQuery{Text: text, Mode: mode, Threshold: threshold, Limit: limit + 1, Offset: start})
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	results := model.SearchResults{Limit: limit, Start: start, HasMore: len(found) > limit, Results: []model.SearchResult{}}
+	for _, f := range found[:min(limit, len(found))] {
+		var result model.SearchResult
+		result.TranslateToAPI(f.Device, f.Score, f.NameHighlight, f.BrandHighlight)
+		results.Results = append(results.Results, result)
+	}
+
+	respond(ctx, http.StatusOK, results)
+}
+
+// searchThreshold is the similarity used when the search doesn't ask for one.
+func (w *Web) searchThreshold() float64 {
+	if w.SearchThreshold > 0 {
+		return w.SearchThreshold
+	}
+	return db.DefaultSearchThreshold
+}
@@ -0,0 +1,101 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/lcmps/DevicesAPI/db"
+	"github.com/lcmps/DevicesAPI/model"
+)
+
+func TestSearchDevices(t *testing.T) {
+	w := newTestWeb(db.NewMemory())
+	for _, d := range []model.Device{
+		{Name: "Alpha", Brand: "Brand", State: "Available"},
+		{Name: "Gamma", Brand: "Acme", State: "Available"},
+		{Name: "Gamma Ray", Brand: "Brand", State: "In-Use"},
+	} {
+		doRequest(w, http.MethodPost, "/api/device/", d)
+	}
+
+	cases := []struct {
+		name        string
+		query       string
+		wantStatus  int
+		wantNames   []string
+		wantHasMore bool
+	}{
+		{"Typo", "?q=Gamm", http.StatusOK, []string{"Gamma", "Gamma Ray"}, false},
+		{"Threshold", "?q=Gamm&threshold=0.5", http.StatusOK, []string{"Gamma"}, false},
+		{"DefaultThreshold", "?q=Brnd", http.StatusOK, []string{"Alpha", "Gamma Ray"}, false},
+		{"Paginated", "?q=Gamm&limit=1", http.StatusOK, []string{"Gamma"}, true},
+		{"NoMatch", "?q=Zzz", http.StatusOK, nil, false},
+		{"Text", "?mode=text&q=gamma", http.StatusOK, []string{"Gamma", "Gamma Ray"}, false},
+		{"TextPhrase", "?mode=text&q=" + url.QueryEscape(`"gamma ray"`), http.StatusOK, []string{"Gamma Ray"}, false},
+		{"TextPrefixAndNegation", "?mode=text&q=" + url.QueryEscape("gam* -ray"), http.StatusOK, []string{"Gamma"}, false},
+		{"TextTypo", "?mode=text&q=Gamm", http.StatusOK, nil, false},
+		{"MissingText", "", http.StatusBadRequest, nil, false},
+		{"NothingToSearch", "?mode=text&q=-gamma", http.StatusBadRequest, nil, false},
+		{"InvalidMode", "?q=Gamm&mode=exact", http.StatusBadRequest, nil, false},
+		{"InvalidThreshold", "?q=Gamm&threshold=2", http.StatusBadRequest, nil, false},
+		{"ZeroThreshold", "?q=Gamm&threshold=0", http.StatusBadRequest, nil, false},
+		{"InvalidLimit", "?q=Gamm&limit=many", http.StatusBadRequest, nil, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := doRequest(w, http.MethodGet, "/api/device/search"+tc.query, nil)
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tc.wantStatus, rec.Code, rec.Body.String())
+			}
+			if tc.wantStatus != http.StatusOK {
+				return
+			}
+			var results model.SearchResults
+			_ = json.Unmarshal(rec.Body.Bytes(), &results)
+			var names []string
+			for _, r := range results.Results {
+				names = append(names, r.Name)
+				if r.Score <= 0 || r.Score > 1 || r.ID == "" {
+					t.Fatalf("unexpected result %+v", r)
+				}
+			}
+			assert.Equal(t, tc.wantNames, names)
+			assert.Equal(t, tc.wantHasMore, results.HasMore)
+		})
+	}
+
+	t.Run("ConfiguredThreshold", func(t *testing.T) {
+		w.SearchThreshold = 0.5
+		defer func() { w.SearchThreshold = 0 }()
+		rec := doRequest(w, http.MethodGet, "/api/device/search?q=Gamm", nil)
+		var results model.SearchResults
+		_ = json.Unmarshal(rec.Body.Bytes(), &results)
+		assert.Equal(t, 1, len(results.Results))
+	})
+
+	t.Run("Highlight", func(t *testing.T) {
+		rec := doRequest(w, http.MethodGet, "/api/device/search?mode=text&q=ray", nil)
+		var results model.SearchResults
+		_ = json.Unmarshal(rec.Body.Bytes(), &results)
+		assert.Equal(t, &model.Highlight{Name: "Gamma <mark>Ray</mark>", Brand: "Brand"}, results.Results[0].Highlight)
+
+		rec = doRequest(w, http.MethodGet, "/api/device/search?q=Gamm", nil)
+		results = model.SearchResults{}
+		_ = json.Unmarshal(rec.Body.Bytes(), &results)
+		assert.Equal(t, (*model.Highlight)(nil), results.Results[0].Highlight)
+	})
+
+	t.Run("StoreError", func(t *testing.T) {
+		store := seededStore()
+		store.listErr = errors.New("failed to search devices: boom")
+		rec := doRequest(newTestWeb(store), http.MethodGet, "/api/device/search?q=Alpha", nil)
+		if rec.Code != http.StatusInternalServerError {
+			t.Fatalf("expected status %d, got %d", http.StatusInternalServerError, rec.Code)
+		}
+	})
+}
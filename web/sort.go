@@ -0,0 +1,77 @@
+package web
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lcmps/DevicesAPI/db"
+)
+
+// sortFields maps the fields the device list can be sorted by to their column, in the order they're documented.
+var sortFields = []struct {
+	field  string
+	column string
+}{
+	{"name", db.ColumnName},
+	{"brand", db.ColumnBrand},
+	{"state", db.ColumnState},
+	{"createdAt", db.ColumnCreatedAt},
+}
+
+// parseSort reads a sort parameter such as "brand,-createdAt": a comma separated list of fields,
+// each one descending when prefixed by "-".
+func parseSort(value string) ([]db.SortField, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	var sort []db.SortField
+	seen := map[string]bool{}
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		desc := strings.HasPrefix(field, "-")
+		field = strings.TrimPrefix(field, "-")
+
+		column := ""
+		for _, f := range sortFields {
+			if f.field == field {
+				column = f.column
+			}
+		}
+		if column == "" {
+			return nil, fmt.Errorf("unknown sort field %q, should be one of: %s", field, sortFieldNames())
+		}
+		if seen[column] {
+			return nil, fmt.Errorf("sort field %q is repeated", field)
+		}
+		seen[column] = true
+		sort = append(sort, db.SortField{Column: column, Desc: desc})
+	}
+	return sort, nil
+}
+
+// formatSort writes the sort back as a sort parameter, so equivalent parameters compare equal.
+func formatSort(sort []db.SortField) string {
+	fields := make([]string, 0, len(sort))
+	for _, s := range sort {
+		for _, f := range sortFields {
+			if f.column != s.Column {
+				continue
+			}
+			if s.Desc {
+				fields = append(fields, "-"+f.field)
+			} else {
+				fields = append(fields, f.field)
+			}
+		}
+	}
+	return strings.Join(fields, ",")
+}
+
+func sortFieldNames() string {
+	names := make([]string, 0, len(sortFields))
+	for _, f := range sortFields {
+		names = append(names, f.field)
+	}
+	return strings.Join(names, ", ")
+}
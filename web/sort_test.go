@@ -0,0 +1,87 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/lcmps/DevicesAPI/db"
+	"github.com/lcmps/DevicesAPI/model"
+)
+
+func TestParseSort(t *testing.T) {
+	cases := []struct {
+		name    string
+		value   string
+		want    []db.SortField
+		wantErr bool
+	}{
+		{"Empty", "", nil, false},
+		{"Single", "name", []db.SortField{{Column: db.ColumnName}}, false},
+		{"MultiKey", "brand, -createdAt", []db.SortField{{Column: db.ColumnBrand}, {Column: db.ColumnCreatedAt, Desc: true}}, false},
+		{"Unknown", "id", nil, true},
+		{"ColumnName", "created_at", nil, true},
+		{"EmptyField", "name,", nil, true},
+		{"Repeated", "name,-name", nil, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseSort(tc.value)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error %t, got %v", tc.wantErr, err)
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	sort, _ := parseSort(" brand ,-createdAt")
+	assert.Equal(t, "brand,-createdAt", formatSort(sort))
+}
+
+func TestGetDeviceByFilter_Sort(t *testing.T) {
+	w := newTestWeb(db.NewMemory())
+	for _, d := range []model.Device{
+		{Name: "Alpha", Brand: "BrandB", State: "Available"},
+		{Name: "Beta", Brand: "BrandA", State: "Inactive"},
+		{Name: "Gamma", Brand: "BrandB", State: "Available"},
+	} {
+		doRequest(w, http.MethodPost, "/api/device/", d)
+	}
+
+	list := func(query string) (int, model.DeviceList) {
+		rec := doRequest(w, http.MethodGet, "/api/device/"+query, nil)
+		var page model.DeviceList
+		_ = json.Unmarshal(rec.Body.Bytes(), &page)
+		return rec.Code, page
+	}
+	names := func(page model.DeviceList) []string {
+		var result []string
+		for _, d := range page.Devices {
+			result = append(result, d.Name)
+		}
+		return result
+	}
+
+	code, page := list("?sort=brand,-name&limit=2")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"Beta", "Gamma"}, names(page))
+
+	_, next := list("?sort=brand,-name&limit=2&cursor=" + page.Next)
+	assert.Equal(t, []string{"Alpha"}, names(next))
+
+	t.Run("CursorOfAnotherSort", func(t *testing.T) {
+		code, _ := list("?sort=name&limit=2&cursor=" + page.Next)
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("InvalidSort", func(t *testing.T) {
+		rec := doRequest(w, http.MethodGet, "/api/device/?sort=-version", nil)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		var problem model.Problem
+		_ = json.Unmarshal(rec.Body.Bytes(), &problem)
+		assert.Equal(t, codeInvalidQuery, problem.Code)
+		assert.Equal(t, "sort", problem.Errors[0].Field)
+	})
+}
@@ -1,14 +1,18 @@
 package web
 
 import (
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/lcmps/DevicesAPI/db"
 	"github.com/lcmps/DevicesAPI/model"
+	"github.com/lcmps/DevicesAPI/model/database"
 
 	_ "github.com/lcmps/DevicesAPI/docs"
 
@@ -22,39 +26,211 @@ import (
 // @BasePath	/api
 type Web struct {
 	Router *gin.Engine
-	DB     *db.DB
+	DB     db.DeviceStore
+	States *model.StateMachine
+	// RequireIfMatch makes the If-Match header mandatory on PUT, PATCH and DELETE
+	RequireIfMatch bool
+	// Admins are the actors allowed to see and restore deleted devices, nobody when empty
+	Admins []string
+	// SearchThreshold is the default similarity of the device search, db.DefaultSearchThreshold when 0
+	SearchThreshold float64
 }
 
-func New(connection *db.DB) *Web {
+// Config holds the optional settings of the API, anything left empty falls back to its default.
+type Config struct {
+	// States defines the allowed transitions between device states (default: model.DefaultTransitions)
+	States *model.StateMachine
+	// RequireIfMatch rejects PUT, PATCH and DELETE requests without an If-Match header with 428 (default: false)
+	RequireIfMatch bool
+	// Admins are the only actors allowed to list and restore deleted devices (default: nobody)
+	Admins []string
+	// SearchThreshold is the similarity devices must reach to be found by a search (default: db.DefaultSearchThreshold)
+	SearchThreshold float64
+}
+
+// New creates the web server on top of any DeviceStore implementation
+// and registers all the API routes.
+func New(store db.DeviceStore, config Config) *Web {
 	gin.SetMode(gin.ReleaseMode)
 
-	return &Web{
-		Router: gin.Default(),
-		DB:     connection,
+	if config.States == nil {
+		config.States = model.DefaultStateMachine()
+	}
+
+	w := &Web{
+		Router:          gin.Default(),
+		DB:              store,
+		States:          config.States,
+		RequireIfMatch:  config.RequireIfMatch,
+		Admins:          config.Admins,
+		SearchThreshold: config.SearchThreshold,
 	}
+	w.registerRoutes()
+
+	return w
 }
 
-func isValidState(state string) bool {
-	switch state {
-	case "Available", "In-Use", "Inactive":
-		return true
-	default:
-		return false
+const invalidStateMessage = "invalid state value, should be one of: Available, In-Use, Inactive"
+
+// invalidStateError is the field error sent along with invalidStateMessage.
+var invalidStateError = model.FieldError{Field: "state", Code: fieldCodeInvalid, Message: "state should be one of: Available, In-Use, Inactive"}
+
+// missingFields lists the required device fields left empty on the request.
+func missingFields(device model.Device) []model.FieldError {
+	fields := []struct{ name, value string }{
+		{"name", device.Name},
+		{"brand", device.Brand},
+		{"state", device.State},
+	}
+
+	var fieldErrors []model.FieldError
+	for _, f := range fields {
+		if f.value == "" {
+			fieldErrors = append(fieldErrors, model.FieldError{Field: f.name, Code: fieldCodeRequired, Message: f.name + " is required"})
+		}
 	}
+	return fieldErrors
 }
 
-func (w *Web) Serve() {
-	api := w.Router.Group("/api/device")
+// readOnlyFields lists the server managed fields the replacement tries to change.
+// They can be left empty or sent back exactly as they were returned.
+func readOnlyFields(device database.Device, replacement model.Device) []model.FieldError {
+	var current model.Device
+	current.TranslateToAPI(device)
+
+	fields := []struct{ name, value, current string }{
+		{"id", replacement.ID, current.ID},
+		{"createdAt", replacement.CreatedAt, current.CreatedAt},
+		{"deletedAt", replacement.DeletedAt, current.DeletedAt},
+		{"deletedBy", replacement.DeletedBy, current.DeletedBy},
+	}
+
+	var fieldErrors []model.FieldError
+	for _, f := range fields {
+		if f.value != "" && f.value != f.current {
+			fieldErrors = append(fieldErrors, model.FieldError{Field: f.name, Code: fieldCodeReadOnly, Message: f.name + " cannot be changed"})
+		}
+	}
+	return fieldErrors
+}
+
+// pagination reads the limit and start query parameters, responding with 400 when they aren't integers.
+func pagination(ctx *gin.Context) (limit, start int, ok bool) {
+	// Setting default values for limit and start parameters if none are provided through the URL query.
+	// since returning all records could be heavy on the server and network.
+	// Default limit is 50 records, default start is 0th record.
+	limitStr := ctx.DefaultQuery("limit", "50")
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil {
+		respondProblem(ctx, http.StatusBadRequest, codeInvalidQuery, "limit must be an integer",
+			model.FieldError{Field: "limit", Code: fieldCodeNotInteger, Message: "limit must be an integer"})
+		return 0, 0, false
+	}
+
+	if limit < 1 || limit > maxPageSize {
+		message := fmt.Sprintf("limit must be between 1 and %d", maxPageSize)
+		respondProblem(ctx, http.StatusBadRequest, codeInvalidQuery, message,
+			model.FieldError{Field: "limit", Code: fieldCodeInvalid, Message: message})
+		return 0, 0, false
+	}
+
+	startStr := ctx.DefaultQuery("start", "0")
+	start, err = strconv.Atoi(startStr)
+	if err != nil {
+		respondProblem(ctx, http.StatusBadRequest, codeInvalidQuery, "start must be an integer",
+			model.FieldError{Field: "start", Code: fieldCodeNotInteger, Message: "start must be an integer"})
+		return 0, 0, false
+	}
+
+	if start < 0 {
+		respondProblem(ctx, http.StatusBadRequest, codeInvalidQuery, "start must not be negative",
+			model.FieldError{Field: "start", Code: fieldCodeInvalid, Message: "start must not be negative"})
+		return 0, 0, false
+	}
+
+	return limit, start, true
+}
+
+// deletedFilter reads the includeDeleted and onlyDeleted query parameters, responding with 400 when they
+// aren't booleans. onlyDeleted wins when both are set.
+func deletedFilter(ctx *gin.Context) (db.DeletedFilter, bool) {
+	filter := db.ExcludeDeleted
+	params := []struct {
+		name   string
+		filter db.DeletedFilter
+	}{
+		{"includeDeleted", db.IncludeDeleted},
+		{"onlyDeleted", db.OnlyDeleted},
+	}
+
+	for _, p := range params {
+		value := ctx.Query(p.name)
+		if value == "" {
+			continue
+		}
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			respondProblem(ctx, http.StatusBadRequest, codeInvalidQuery, p.name+" must be a boolean",
+				model.FieldError{Field: p.name, Code: fieldCodeInvalid, Message: p.name + " must be a boolean"})
+			return filter, false
+		}
+		if enabled {
+			filter = p.filter
+		}
+	}
+
+	return filter, true
+}
+
+// isAdmin tells whether the actor of the request can manage deleted devices, nobody being an admin unless
+// some are configured. Authentication is left to whatever sits in front of the API, which is trusted to set
+// the actor header and to drop the one sent by clients.
+func (w *Web) isAdmin(ctx *gin.Context) bool {
+	actor := ctx.GetHeader(actorHeader)
+	return actor != "" && slices.Contains(w.Admins, actor)
+}
+
+func isValidState(state string) bool {
+	return model.IsValidState(state)
+}
+
+func (w *Web) registerRoutes() {
+	// Every change is recorded along with who asked for it
+	w.Router.Use(auditInfo())
+
+	// Responses are written in the representation the client accepts, JSON by default
+	api := w.Router.Group("/api/device", negotiate(representations...))
 	{
 		// Create a new device
 		api.POST("/", w.newDevice)
 
-		// Fully and/or partially update an existing device.
+		// Create several devices at once.
+		api.POST("/bulk", w.createDevices)
+
+		// Move several devices to another state at once.
+		api.POST("/bulk/state", w.changeDevicesState)
+
+		// Delete several devices at once.
+		api.POST("/bulk/delete", w.deleteDevices)
+
+		// Fully replace an existing device.
 		api.PUT("/:id", w.updateDevice)
 
+		// Partially update an existing device through a JSON (Merge) Patch.
+		api.PATCH("/:id", w.patchDevice)
+
+		// Search devices by similarity, ignoring typos.
+		api.GET("/search", w.searchDevices)
+
 		// Fetch a single device (by ID).
 		api.GET("/:id", w.getDeviceByID)
 
+		// List the states a device can go to from its current one.
+		api.GET("/:id/transitions", w.getDeviceTransitions)
+
+		// List the changes made to a device.
+		api.GET("/:id/history", w.getDeviceHistory)
+
 		// fetch all devices.
 		// devices by name (partial match).
 		// devices by brand.
@@ -63,10 +239,32 @@ func (w *Web) Serve() {
 
 		// Delete a single device.
 		api.DELETE("/:id", w.deleteDevice)
+
+		// Bring a deleted device back.
+		api.POST("/:id/restore", w.restoreDevice)
+	}
+
+	// Imports and exports deal with files, so they negotiate their own media types
+	files := w.Router.Group("/api/device")
+	{
+		// Import devices from a CSV file, reporting the rejected rows as CSV too when asked to.
+		files.POST("/import", negotiate(append(representations, csvContentType)...), w.importDevices)
+
+		// Stream every device matching the filters as CSV or NDJSON.
+		files.GET("/export", w.exportDevices)
 	}
 
+	// Query the changes made to every device.
+	w.Router.GET("/api/audit", negotiate(representations...), w.getAuditRecords)
+
 	w.Router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
+	w.Router.NoRoute(func(ctx *gin.Context) {
+		respondProblem(ctx, http.StatusNotFound, codeRouteNotFound, "the requested route does not exist")
+	})
+}
+
+func (w *Web) Serve() {
 	log.Println("Starting server on port " + os.Getenv("PORT"))
 	err := w.Router.Run(":" + os.Getenv("PORT"))
 	if err != nil {
@@ -74,6 +272,29 @@ func (w *Web) Serve() {
 	}
 }
 
+// checkTransition refuses to move the device to the given state if the state machine doesn't allow it.
+func (w *Web) checkTransition(device database.Device, state string) error {
+	if !w.States.CanTransition(device.State, state) {
+		return &db.StateViolationError{Reason: fmt.Sprintf("cannot change state from %s to %s, allowed states: %s",
+			device.State, state, strings.Join(w.States.AllowedTransitions(device.State), ", "))}
+	}
+	return nil
+}
+
+// validateNewDevice checks the device to create, returning why it is invalid (empty when it is fine)
+// along with the invalid fields.
+func validateNewDevice(device model.Device) (string, []model.FieldError) {
+	if fieldErrors := missingFields(device); len(fieldErrors) > 0 {
+		return "name, brand, and state are required fields", fieldErrors
+	}
+
+	// checking if the provided state is one of model.States.
+	if !isValidState(device.State) {
+		return invalidStateMessage, []model.FieldError{invalidStateError}
+	}
+	return "", nil
+}
+
 // newDevice godoc
 // @Summary      Create a new device
 // @Description  Create a new device entry. Name, brand, and state are required. State must be one of:
@@ -81,44 +302,24 @@ func (w *Web) Serve() {
 // In-Use,
 // Inactive.
 // @Tags         devices
-// @Accept       json
-// @Produce      json
+// @Accept       json,xml,application/msgpack,application/yaml
+// @Produce      json,xml,application/msgpack,application/yaml
 // @Param        device  body      model.Device  true  "Device to create"
 // @Success      201     {object}  model.Device
-// @Failure      400     {object}  model.RestError
-// @Failure      409     {object}  model.RestError
-// @Failure      500     {object}  model.RestError
+// @Failure      400     {object}  model.Problem
+// @Failure      406     {object}  model.Problem
+// @Failure      409     {object}  model.Problem
+// @Failure      415     {object}  model.Problem
+// @Failure      500     {object}  model.Problem
 // @Router       /device [post]
 func (w *Web) newDevice(ctx *gin.Context) {
 	var requestBody model.Device
-	if err := ctx.ShouldBindJSON(&requestBody); err != nil {
-		ctx.JSON(http.StatusBadRequest, model.RestError{Message: err.Error()})
-		return
-	}
-
-	if requestBody.Name == "" || requestBody.Brand == "" || requestBody.State == "" {
-		ctx.JSON(http.StatusBadRequest, model.RestError{Message: "name, brand, and state are required fields"})
+	if !bindBody(ctx, &requestBody) {
 		return
 	}
 
-	// checking if the provided state is one of the 3 valid values.
-	if !isValidState(requestBody.State) {
-		ctx.JSON(http.StatusBadRequest, model.RestError{Message: "invalid state value, should be one of: Available, In-Use, Inactive"})
-		return
-	}
-
-	// Checking if an entry with the same name and brand already exists (and is not deleted).
-	// This could've been forced through the following unique constraint statement in the database:
-	// -----> CONSTRAINT name_brand_unique UNIQUE (name, brand)
-	// but since the document didn't specify that, I've implemented it in the application logic,
-	// so if my guess that name+brand should be unique is wrong, it can be easily changed.
-	existingDevices, err := w.DB.GetDevices(1, 0, requestBody.Brand, "", requestBody.Name)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, model.RestError{Message: err.Error()})
-		return
-	}
-	if len(existingDevices) > 0 {
-		ctx.JSON(http.StatusConflict, model.RestError{Message: "a device with the same name and brand already exists"})
+	if detail, fieldErrors := validateNewDevice(requestBody); detail != "" {
+		respondValidation(ctx, detail, fieldErrors...)
 		return
 	}
 
@@ -129,224 +330,400 @@ func (w *Web) newDevice(ctx *gin.Context) {
 	}
 	dbDevice := newDevice.TranslateToDB()
 
-	err = w.DB.CreateDevice(&dbDevice)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, model.RestError{Message: err.Error()})
+	// Name and brand must be unique among live devices, which is enforced by the database
+	// through a partial unique index, so there's no race between checking and inserting.
+	if err := w.DB.CreateDevice(ctx.Request.Context(), &dbDevice); err != nil {
+		respondError(ctx, err)
 		return
 	}
 
 	var dvc model.Device
 	dvc.TranslateToAPI(dbDevice)
-	ctx.JSON(http.StatusCreated, dvc)
+	ctx.Header("ETag", etag(dbDevice))
+	respond(ctx, http.StatusCreated, dvc)
 }
 
-// @Summary      Update an existing device
-// @Description  Fully or partially update an existing device. Name and brand cannot be changed if device is in use.
+// @Summary      Replace an existing device
+// @Description  Replace every field of an existing device. Name, brand and state are required, id and createdAt are read-only.
+// @Description  Name and brand cannot be changed if device is in use.
+// @Description  State changes must follow the allowed transitions, listed by /device/{id}/transitions.
 // @Tags         devices
-// @Accept       json
-// @Produce      json
-// @Param        id      path      string        true  "Device ID"
-// @Param        device  body      model.Device  true  "Device fields to update"
-// @Success      200     {object}  model.Device
-// @Failure      400     {object}  model.RestError
-// @Failure      404     {object}  model.RestError
-// @Failure      500     {object}  model.RestError
+// @Accept       json,xml,application/msgpack,application/yaml
+// @Produce      json,xml,application/msgpack,application/yaml
+// @Param        id        path      string        true   "Device ID"
+// @Param        If-Match  header    string        false  "ETag of the device, the update fails with 412 if it changed"
+// @Param        device    body      model.Device  true   "New representation of the device"
+// @Success      200       {object}  model.Device
+// @Header       200       {string}  ETag  "Version of the device"
+// @Failure      400       {object}  model.Problem
+// @Failure      404       {object}  model.Problem
+// @Failure      406       {object}  model.Problem
+// @Failure      409       {object}  model.Problem
+// @Failure      412       {object}  model.Problem
+// @Failure      415       {object}  model.Problem
+// @Failure      428       {object}  model.Problem
+// @Failure      500       {object}  model.Problem
 // @Router       /device/{id} [put]
 func (w *Web) updateDevice(ctx *gin.Context) {
 	id := ctx.Param("id")
 
 	var requestBody model.Device
-	if err := ctx.ShouldBindJSON(&requestBody); err != nil {
-		ctx.JSON(http.StatusBadRequest, model.RestError{Message: err.Error()})
+	if !bindBody(ctx, &requestBody) {
 		return
 	}
 
-	device, err := w.DB.GetDeviceByID(id)
+	device, err := w.DB.GetDeviceByID(ctx.Request.Context(), id)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, model.RestError{Message: err.Error()})
+		respondError(ctx, err)
 		return
 	}
-
-	if device.ID.String() == "" {
-		ctx.JSON(http.StatusNotFound, model.RestError{Message: "device not found"})
+	if !w.checkIfMatch(ctx, device) {
 		return
 	}
 
-	// Name and brand properties cannot be updated if the device is in use, so I need to check that first.
-	// If the device is in use and either the name or brand is being changed, return an error.
-	// Otherwise, proceed with the update.
-	if device.State == "In-Use" {
-		nameChanged := requestBody.Name != "" && requestBody.Name != device.Name
-		brandChanged := requestBody.Brand != "" && requestBody.Brand != device.Brand
-		if nameChanged || brandChanged {
-			ctx.JSON(http.StatusBadRequest, model.RestError{Message: "cannot update name or brand: device is currently in use"})
-			return
-		}
-	}
+	w.replaceDevice(ctx, device, requestBody)
+}
 
-	// Check if any field would actually change
-	noChange := true
-	if requestBody.Name != "" && requestBody.Name != device.Name {
-		noChange = false
-	}
-	if requestBody.Brand != "" && requestBody.Brand != device.Brand {
-		noChange = false
+// replaceDevice validates the new representation of a device against the stored one and saves it.
+// Both PUT and PATCH end up here, so the same rules apply no matter how the change was described.
+func (w *Web) replaceDevice(ctx *gin.Context, device database.Device, replacement model.Device) {
+	if fieldErrors := missingFields(replacement); len(fieldErrors) > 0 {
+		respondValidation(ctx, "name, brand, and state are required fields", fieldErrors...)
+		return
 	}
-	if requestBody.State != "" && requestBody.State != device.State {
-		noChange = false
+	if fieldErrors := readOnlyFields(device, replacement); len(fieldErrors) > 0 {
+		respondValidation(ctx, "read-only fields cannot be changed", fieldErrors...)
+		return
 	}
-
-	if noChange {
-		var dvc model.Device
-		dvc.TranslateToAPI(device)
-		ctx.JSON(http.StatusOK, dvc)
+	// checking if the provided state is one of model.States.
+	if !isValidState(replacement.State) {
+		respondValidation(ctx, invalidStateMessage, invalidStateError)
 		return
 	}
 
-	// Updating only the fields that are provided in the request body.
-	if requestBody.Name != "" {
-		device.Name = requestBody.Name
+	// Name and brand properties cannot be updated if the device is in use.
+	if device.State == model.StateInUse && (replacement.Name != device.Name || replacement.Brand != device.Brand) {
+		respondError(ctx, &db.StateViolationError{Reason: "cannot update name or brand: device is currently in use"})
+		return
 	}
-	if requestBody.Brand != "" {
-		device.Brand = requestBody.Brand
+	if err := w.checkTransition(device, replacement.State); err != nil {
+		respondError(ctx, err)
+		return
 	}
-	if requestBody.State != "" {
-		// checking if the provided state is one of the 3 valid values.
-		if !isValidState(requestBody.State) {
-			ctx.JSON(http.StatusBadRequest, model.RestError{Message: "invalid state value, should be one of: Available, In-Use, Inactive"})
+
+	// Nothing to write if no field would actually change
+	if replacement.Name != device.Name || replacement.Brand != device.Brand || replacement.State != device.State {
+		device.Name = replacement.Name
+		device.Brand = replacement.Brand
+		device.State = replacement.State
+
+		// The write only succeeds if nobody changed the device since it was read,
+		// so the rules checked above still hold when it is applied
+		if err := w.DB.UpdateDevice(ctx.Request.Context(), &device); err != nil {
+			respondWriteError(ctx, err)
 			return
 		}
-		device.State = requestBody.State
-	}
-
-	err = w.DB.UpdateDevice(device)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, model.RestError{Message: err.Error()})
-		return
 	}
 
 	var dvc model.Device
 	dvc.TranslateToAPI(device)
 
-	ctx.JSON(http.StatusOK, dvc)
+	ctx.Header("ETag", etag(device))
+	respond(ctx, http.StatusOK, dvc)
 }
 
 // @Summary      Get device by ID
 // @Description  Fetch a single device by its ID.
 // @Tags         devices
-// @Produce      json
+// @Produce      json,xml,application/msgpack,application/yaml
 // @Param        id   path      string        true  "Device ID"
 // @Success      200  {object}  model.Device
-// @Failure      404  {object}  model.RestError
-// @Failure      500  {object}  model.RestError
+// @Header       200  {string}  ETag  "Version of the device, to be sent on If-Match"
+// @Failure      400  {object}  model.Problem
+// @Failure      404  {object}  model.Problem
+// @Failure      406  {object}  model.Problem
+// @Failure      500  {object}  model.Problem
 // @Router       /device/{id} [get]
 func (w *Web) getDeviceByID(ctx *gin.Context) {
 	id := ctx.Param("id")
 
-	device, err := w.DB.GetDeviceByID(id)
+	device, err := w.DB.GetDeviceByID(ctx.Request.Context(), id)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, model.RestError{Message: err.Error()})
+		respondError(ctx, err)
 		return
 	}
 
-	if device.ID.String() == "" {
-		ctx.JSON(http.StatusNotFound, model.RestError{Message: "device not found"})
+	var dvc model.Device
+	dvc.TranslateToAPI(device)
+
+	ctx.Header("ETag", etag(device))
+	respond(ctx, http.StatusOK, dvc)
+}
+
+// @Summary      List allowed state transitions
+// @Description  List the states a device can be moved to from its current state.
+// @Tags         devices
+// @Produce      json,xml,application/msgpack,application/yaml
+// @Param        id   path      string  true  "Device ID"
+// @Success      200  {object}  model.DeviceTransitions
+// @Failure      400  {object}  model.Problem
+// @Failure      404  {object}  model.Problem
+// @Failure      406  {object}  model.Problem
+// @Failure      500  {object}  model.Problem
+// @Router       /device/{id}/transitions [get]
+func (w *Web) getDeviceTransitions(ctx *gin.Context) {
+	device, err := w.DB.GetDeviceByID(ctx.Request.Context(), ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, err)
 		return
 	}
 
-	var dvc model.Device
-	dvc.TranslateToAPI(device)
+	respond(ctx, http.StatusOK, model.DeviceTransitions{
+		ID:            device.ID.String(),
+		State:         device.State,
+		AllowedStates: w.States.AllowedTransitions(device.State),
+	})
+}
 
-	ctx.JSON(http.StatusOK, dvc)
+// listQuery reads the filters and the sort of the device list, leaving its pagination aside.
+// It responds with 400 (or 403 when asking for deleted devices without being an admin) and returns false
+// when they are invalid.
+func (w *Web) listQuery(ctx *gin.Context) (db.DeviceQuery, bool) {
+	deleted, ok := deletedFilter(ctx)
+	if !ok {
+		return db.DeviceQuery{}, false
+	}
+	if deleted != db.ExcludeDeleted && !w.isAdmin(ctx) {
+		respondProblem(ctx, http.StatusForbidden, codeForbidden, "only admins can list deleted devices")
+		return db.DeviceQuery{}, false
+	}
+
+	filter, ok := listFilter(ctx)
+	if !ok {
+		return db.DeviceQuery{}, false
+	}
+
+	sort, err := parseSort(ctx.Query("sort"))
+	if err != nil {
+		respondProblem(ctx, http.StatusBadRequest, codeInvalidQuery, err.Error(),
+			model.FieldError{Field: "sort", Code: fieldCodeInvalid, Message: err.Error()})
+		return db.DeviceQuery{}, false
+	}
+
+	return db.DeviceQuery{
+		Name:    ctx.DefaultQuery("name", ""),
+		Filter:  filter,
+		Deleted: deleted,
+		Sort:    sort,
+	}, true
 }
 
 // getDeviceByFilter
 // accepts the following query parameters:
-// - limit: number of records to return (default: 50)
+// - limit: number of records to return (default: 50, at most 500)
 // - start: starting index (default: 0)
+// - cursor: next or prev value of a previous page, replacing start
+// - sort: comma separated fields to sort by (name, brand, state, createdAt), descending when prefixed by "-"
+// - count: how the total is computed, exact (default), estimate (from statistics, on large tables) or none
 // - name: filter by device name (optional, partial match)
-// - brand: filter by device brand (optional)
-// - state: filter by device state (optional), with the following possible values:
+// - brand: filter by device brand (optional), a comma separated list matching any of them, Brand* by prefix and
+// *rand* partially, negated when starting with !
+// - state: filter by device state (optional), a comma separated list, negated when starting with !, with the
+// following possible values:
 //   - Available
 //   - In-use
 //   - Inactive
 //
+// - createdAfter, createdBefore: RFC 3339 creation time range (optional)
+// - filter: expression combining comparisons through AND, OR, NOT and parentheses (optional), e.g.
+// brand:BrandA AND createdAt>=2025-09-01T00:00:00Z AND NOT state:Inactive
+// - includeDeleted: also list deleted devices (optional, admins only)
+// - onlyDeleted: list only deleted devices (optional, admins only)
+//
 // @Summary      List devices
-// @Description  List devices with optional filters for name, brand, and state. Supports pagination.
+// @Description  List devices with optional filters for name, brand, and state, oldest first unless sorted. Supports pagination,
+// @Description  either through start or through the next and prev cursors of a previous page.
+// @Description  Admins can also list deleted devices through includeDeleted or onlyDeleted.
 // @Tags         devices
-// @Produce      json
-// @Param        limit  query     int     false  "Number of records to return (default: 50)"
-// @Param        start  query     int     false  "Starting index (default: 0)"
-// @Param        name   query     string  false  "Filter by device name (partial match)"
-// @Param        brand  query     string  false  "Filter by device brand"
-// @Param        state  query     string  false  "Filter by device state (Available, In-Use, Inactive)"
-// @Success      200    {object}  model.DeviceList
-// @Failure      400    {object}  model.RestError
-// @Failure      500    {object}  model.RestError
+// @Produce      json,xml,application/msgpack,application/yaml
+// @Param        limit           query     int     false  "Number of records to return (default: 50, at most 500)"
+// @Param        start           query     int     false  "Starting index (default: 0)"
+// @Param        cursor          query     string  false  "Next or prev cursor of a previous page, replacing start"
+// @Param        sort            query     string  false  "Fields to sort by (name, brand, state, createdAt), descending when prefixed by -, e.g. brand,-createdAt"
+// @Param        count           query     string  false  "How the total is computed: exact (default), estimate or none"
+// @Param        name            query     string  false  "Filter by device name (partial match)"
+// @Param        brand           query     string  false  "Filter by device brands, comma separated: Brand* matches by prefix, *rand* partially, a leading ! negates them"
+// @Param        state           query     string  false  "Filter by device states (Available, In-Use, Inactive), comma separated, a leading ! negates them"
+// @Param        createdAfter    query     string  false  "Only devices created at or after this RFC 3339 time"
+// @Param        createdBefore   query     string  false  "Only devices created before this RFC 3339 time"
+// @Param        filter          query     string  false  "Filter expression, e.g. brand:BrandA AND createdAt>=2025-09-01T00:00:00Z AND NOT state:Inactive"
+// @Param        includeDeleted  query     bool    false  "Also list deleted devices (admins only)"
+// @Param        onlyDeleted     query     bool    false  "List only deleted devices (admins only)"
+// @Success      200             {object}  model.DeviceList
+// @Failure      400             {object}  model.Problem
+// @Failure      403             {object}  model.Problem
+// @Failure      406             {object}  model.Problem
+// @Failure      500             {object}  model.Problem
 // @Router       /device [get]
 func (w *Web) getDeviceByFilter(ctx *gin.Context) {
-	// Setting default values for limit and start parameters if none are provided through the URL query.
-	// since returning all records could be heavy on the server and network.
-	// Default limit is 50 records, default start is 0th record.
-	limitStr := ctx.DefaultQuery("limit", "50")
-	limit, err := strconv.Atoi(limitStr)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, model.RestError{Message: "limit must be an integer"})
+	limit, start, ok := pagination(ctx)
+	if !ok {
 		return
 	}
 
-	startStr := ctx.DefaultQuery("start", "0")
-	start, err := strconv.Atoi(startStr)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, model.RestError{Message: "start must be an integer"})
+	query, ok := w.listQuery(ctx)
+	if !ok {
 		return
 	}
+	// One more device than asked for tells whether there's another page
+	query.Limit, query.Offset = limit+1, start
+	sortParam := formatSort(query.Sort)
+
+	// Cursors replace start, both can't be used at once
+	var cursor pageCursor
+	if token := ctx.Query("cursor"); token != "" {
+		if ctx.Query("start") != "" {
+			respondProblem(ctx, http.StatusBadRequest, codeInvalidQuery, "cursor and start cannot be used together")
+			return
+		}
+		var err error
+		if cursor, err = decodeCursor(token); err != nil {
+			respondProblem(ctx, http.StatusBadRequest, codeInvalidQuery, "cursor is invalid",
+				model.FieldError{Field: "cursor", Code: fieldCodeInvalid, Message: "cursor must be a next or prev value of a previous page"})
+			return
+		}
+		// A position is only meaningful on the order it was taken from
+		if cursor.Sort != sortParam {
+			respondProblem(ctx, http.StatusBadRequest, codeInvalidQuery, "cursor was issued for another sort",
+				model.FieldError{Field: "cursor", Code: fieldCodeInvalid, Message: "cursor must be used with the same sort as the page it came from"})
+			return
+		}
+		cursor.apply(&query)
+	}
 
-	brand := ctx.DefaultQuery("brand", "")
-	state := ctx.DefaultQuery("state", "")
-	name := ctx.DefaultQuery("name", "")
+	count := ctx.DefaultQuery("count", countExact)
+	if !slices.Contains(countModes, count) {
+		message := "count must be one of: " + strings.Join(countModes, ", ")
+		respondProblem(ctx, http.StatusBadRequest, codeInvalidQuery, message,
+			model.FieldError{Field: "count", Code: fieldCodeInvalid, Message: message})
+		return
+	}
 
-	devices, err := w.DB.GetDevices(limit, start, brand, state, name)
+	devices, err := w.DB.GetDevices(ctx.Request.Context(), query)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, model.RestError{Message: err.Error()})
+		respondError(ctx, err)
 		return
 	}
 
+	// The extra device is the furthest one from the cursor: the first one when going backwards
+	more := len(devices) > limit
+	if more && cursor.Prev {
+		devices = devices[1:]
+	} else if more {
+		devices = devices[:limit]
+	}
+
 	var dvcList model.DeviceList
 	dvcList.TranslateToAPI(devices)
+	dvcList.Limit, dvcList.Start = limit, start
+
+	if count != countNone {
+		mode := db.CountExact
+		if count == countEstimate {
+			mode = db.CountEstimate
+		}
+		total, estimated, err := w.DB.CountDevices(ctx.Request.Context(), query, mode)
+		if err != nil {
+			respondError(ctx, err)
+			return
+		}
+		dvcList.Total, dvcList.TotalEstimated = &total, estimated
+	}
 
-	ctx.JSON(http.StatusOK, dvcList)
+	if len(devices) > 0 {
+		first, last := devices[0], devices[len(devices)-1]
+		// Going forward, there's a previous page unless this is the first one, and the other way around
+		hasPrev, hasNext := start > 0 || query.After != nil, more
+		if cursor.Prev {
+			hasPrev, hasNext = more, true
+		}
+		if hasPrev {
+			dvcList.Prev = encodeCursor(first, sortParam, true)
+		}
+		if hasNext {
+			dvcList.Next = encodeCursor(last, sortParam, false)
+		}
+		dvcList.HasMore = hasNext
+	}
+
+	respond(ctx, http.StatusOK, dvcList)
 }
 
 // @Summary      Delete a device
 // @Description  Soft delete a device by ID. Devices in use cannot be deleted.
 // @Tags         devices
-// @Param        id   path      string  true  "Device ID"
-// @Success      204  "No Content"
-// @Failure      400  {object}  model.RestError
-// @Failure      404  {object}  model.RestError
-// @Failure      500  {object}  model.RestError
+// @Param        id        path      string  true   "Device ID"
+// @Param        If-Match  header    string  false  "ETag of the device, the deletion fails with 412 if it changed"
+// @Success      204       "No Content"
+// @Failure      400       {object}  model.Problem
+// @Failure      404       {object}  model.Problem
+// @Failure      406       {object}  model.Problem
+// @Failure      409       {object}  model.Problem
+// @Failure      412       {object}  model.Problem
+// @Failure      428       {object}  model.Problem
+// @Failure      500       {object}  model.Problem
 // @Router       /device/{id} [delete]
 func (w *Web) deleteDevice(ctx *gin.Context) {
 	id := ctx.Param("id")
 
-	// Fetch device to check its state
-	device, err := w.DB.GetDeviceByID(id)
+	device, err := w.DB.GetDeviceByID(ctx.Request.Context(), id)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, model.RestError{Message: err.Error()})
+		respondError(ctx, err)
+		return
+	}
+	if !w.checkIfMatch(ctx, device) {
+		return
+	}
+
+	// The store refuses to delete devices in use, and deleting on the version read above
+	// makes sure the device didn't change in between
+	if err := w.DB.DeleteDevice(ctx.Request.Context(), id, device.Version); err != nil {
+		respondWriteError(ctx, err)
 		return
 	}
-	if device.State == "In-Use" {
-		ctx.JSON(http.StatusBadRequest, model.RestError{Message: "cannot delete device: device is currently in use"})
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// @Summary      Restore a deleted device
+// @Description  Bring a soft deleted device back. Fails with 409 if a live device took its name and brand. Admins only.
+// @Tags         devices
+// @Produce      json,xml,application/msgpack,application/yaml
+// @Param        id   path      string  true  "Device ID"
+// @Success      200  {object}  model.Device
+// @Header       200  {string}  ETag  "Version of the device"
+// @Failure      400  {object}  model.Problem
+// @Failure      403  {object}  model.Problem
+// @Failure      404  {object}  model.Problem
+// @Failure      406  {object}  model.Problem
+// @Failure      409  {object}  model.Problem
+// @Failure      500  {object}  model.Problem
+// @Router       /device/{id}/restore [post]
+func (w *Web) restoreDevice(ctx *gin.Context) {
+	if !w.isAdmin(ctx) {
+		respondProblem(ctx, http.StatusForbidden, codeForbidden, "only admins can restore deleted devices")
 		return
 	}
 
-	// Proceed with deletion
-	err = w.DB.DeleteDevice(id)
+	device, err := w.DB.RestoreDevice(ctx.Request.Context(), ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, model.RestError{Message: err.Error()})
+		respondError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusNoContent, nil)
+	var dvc model.Device
+	dvc.TranslateToAPI(device)
+
+	ctx.Header("ETag", etag(device))
+	respond(ctx, http.StatusOK, dvc)
 }
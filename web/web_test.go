@@ -2,56 +2,292 @@ package web
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/assert/v2"
+	"github.com/google/uuid"
+	"github.com/lcmps/DevicesAPI/db"
 	"github.com/lcmps/DevicesAPI/model"
 	"github.com/lcmps/DevicesAPI/model/database"
 )
 
-// Define an interface for the DB methods used in tests
-type DeviceDB interface {
-	GetDevices(limit, offset int, brand, state, name string) ([]model.Device, error)
-	CreateDevice(device *database.Device) error
+// mockStore is a DeviceStore fake used to drive the handlers in tests.
+// Each *Err field forces the matching method to fail.
+type mockStore struct {
+	devices   []database.Device
+	createErr error
+	getErr    error
+	listErr   error
+	countErr  error
+	updateErr error
+	deleteErr error
+	audit     []database.AuditRecord
+	auditErr  error
 }
 
-// MockDB for newDevice test, matching DeviceDB interface
-type MockDBNewDevice struct {
-	existing  []model.Device
-	createErr error
+var _ db.DeviceStore = (*mockStore)(nil)
+
+func (m *mockStore) CreateDevice(ctx context.Context, device *database.Device) error {
+	if m.createErr != nil {
+		return m.createErr
+	}
+	if m.conflicts(*device) {
+		return db.ErrConflict
+	}
+	device.ID = uuid.New()
+	device.CreatedAt = time.Now()
+	device.Version = 1
+	m.devices = append(m.devices, *device)
+	return nil
 }
 
-func (m *MockDBNewDevice) GetDevices(limit, offset int, brand, state, name string) ([]model.Device, error) {
-	var result []model.Device
-	for _, d := range m.existing {
-		if (brand == "" || d.Brand == brand) && (name == "" || d.Name == name) {
+// conflicts emulates the unique (name, brand) index of live devices.
+func (m *mockStore) conflicts(device database.Device) bool {
+	for _, d := range m.devices {
+		if !d.Deleted && d.ID != device.ID && d.Name == device.Name && d.Brand == device.Brand {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *mockStore) CreateDevices(ctx context.Context, devices []database.Device, mode db.BulkMode) ([]error, error) {
+	if m.createErr != nil {
+		return nil, m.createErr
+	}
+	created := len(m.devices)
+	errs := make([]error, len(devices))
+	failed := false
+	for i := range devices {
+		errs[i] = m.CreateDevice(ctx, &devices[i])
+		failed = failed || errs[i] != nil
+	}
+	if mode == db.BulkAtomic && failed {
+		m.devices = m.devices[:created]
+		for i, err := range errs {
+			if err == nil {
+				errs[i] = db.ErrBulkAborted
+			}
+		}
+	}
+	return errs, nil
+}
+
+func (m *mockStore) ChangeDevices(ctx context.Context, change db.BulkChange) ([]db.BulkOutcome, error) {
+	if m.updateErr != nil {
+		return nil, m.updateErr
+	}
+	var outcomes []db.BulkOutcome
+	if len(change.IDs) == 0 {
+		devices, err := m.GetDevices(ctx, change.Query)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range devices {
+			outcomes = append(outcomes, db.BulkOutcome{ID: d.ID.String(), Device: d})
+		}
+	}
+	for _, id := range change.IDs {
+		d, err := m.GetDeviceByID(ctx, id)
+		outcomes = append(outcomes, db.BulkOutcome{ID: id, Device: d, Err: err})
+	}
+	// Only the checks, bulk writes and their modes are tested on the stores
+	for i, outcome := range outcomes {
+		if outcome.Err == nil && change.Check != nil {
+			outcomes[i].Err = change.Check(outcome.Device)
+		}
+		outcomes[i].Changed = outcomes[i].Err == nil
+	}
+	return outcomes, nil
+}
+
+func (m *mockStore) GetDeviceByID(ctx context.Context, id string) (database.Device, error) {
+	if m.getErr != nil {
+		return database.Device{}, m.getErr
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		return database.Device{}, db.ErrInvalidID
+	}
+	for _, d := range m.devices {
+		if d.ID.String() == id && !d.Deleted {
+			return d, nil
+		}
+	}
+	return database.Device{}, db.ErrNotFound
+}
+
+func (m *mockStore) GetDevices(ctx context.Context, query db.DeviceQuery) ([]database.Device, error) {
+	if m.listErr != nil {
+		return nil, m.listErr
+	}
+	var result []database.Device
+	for _, d := range m.devices {
+		if d.Deleted && query.Deleted == db.ExcludeDeleted || !d.Deleted && query.Deleted == db.OnlyDeleted {
+			continue
+		}
+		if (query.Brand == "" || d.Brand == query.Brand) && (query.State == "" || d.State == query.State) &&
+			(query.Name == "" || strings.Contains(strings.ToLower(d.Name), strings.ToLower(query.Name))) &&
+			(query.Filter == nil || query.Filter.Matches(d)) {
 			result = append(result, d)
 		}
 	}
+	if query.Offset >= len(result) {
+		return []database.Device{}, nil
+	}
+	result = result[query.Offset:]
+	if query.Limit < len(result) {
+		result = result[:query.Limit]
+	}
 	return result, nil
 }
 
-func (m *MockDBNewDevice) CreateDevice(device *database.Device) error {
-	if m.createErr != nil {
-		return m.createErr
+func (m *mockStore) ExportDevices(ctx context.Context, query db.DeviceQuery, fn func(device database.Device) error) error {
+	query.Limit, query.Offset = len(m.devices), 0
+	devices, err := m.GetDevices(ctx, query)
+	if err != nil {
+		return err
+	}
+	for _, d := range devices {
+		if err := fn(d); err != nil {
+			return err
+		}
 	}
-	// Optionally, convert database.Device to model.Device and append to m.existing for test state
-	m.existing = append(m.existing, model.Device{
-		Name:  device.Name,
-		Brand: device.Brand,
-		State: device.State,
-	})
 	return nil
 }
 
-// Use DeviceDB interface for Web struct in tests
-type TestWeb struct {
-	Router *gin.Engine
-	DB     DeviceDB
+func (m *mockStore) CountDevices(ctx context.Context, query db.DeviceQuery, mode db.CountMode) (int64, bool, error) {
+	if m.countErr != nil {
+		return 0, false, m.countErr
+	}
+	devices, err := m.GetDevices(ctx, db.DeviceQuery{Brand: query.Brand, State: query.State, Name: query.Name, Filter: query.Filter, Deleted: query.Deleted, Limit: len(m.devices)})
+	return int64(len(devices)), mode == db.CountEstimate, err
+}
+
+func (m *mockStore) SearchDevices(ctx context.Context, query db.SearchQuery) ([]db.SearchResult, error) {
+	if m.listErr != nil {
+		return nil, m.listErr
+	}
+	// Plain substring matches, similarity scoring is tested on the stores
+	var results []db.SearchResult
+	for _, d := range m.devices {
+		if !d.Deleted && strings.Contains(strings.ToLower(d.Name+" "+d.Brand), strings.ToLower(query.Text)) {
+			results = append(results, db.SearchResult{Device: d, Score: 1})
+		}
+	}
+	results = results[min(query.Offset, len(results)):]
+	return results[:min(query.Limit, len(results))], nil
+}
+
+func (m *mockStore) UpdateDevice(ctx context.Context, device *database.Device) error {
+	if m.updateErr != nil {
+		return m.updateErr
+	}
+	if m.conflicts(*device) {
+		return db.ErrConflict
+	}
+	for i, d := range m.devices {
+		if d.ID == device.ID && !d.Deleted {
+			if device.Version > 0 && device.Version != d.Version {
+				return db.ErrVersionMismatch
+			}
+			m.devices[i].Name = device.Name
+			m.devices[i].Brand = device.Brand
+			m.devices[i].State = device.State
+			m.devices[i].Version++
+			*device = m.devices[i]
+			return nil
+		}
+	}
+	return db.ErrNotFound
+}
+
+func (m *mockStore) DeleteDevice(ctx context.Context, id string, version int64) error {
+	if m.deleteErr != nil {
+		return m.deleteErr
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		return db.ErrInvalidID
+	}
+	for i, d := range m.devices {
+		if d.ID.String() == id && !d.Deleted {
+			if d.State == "In-Use" {
+				return &db.StateViolationError{Reason: "cannot delete device: device is currently in use"}
+			}
+			if version > 0 && version != d.Version {
+				return db.ErrVersionMismatch
+			}
+			m.devices[i].Deleted = true
+			return nil
+		}
+	}
+	return db.ErrNotFound
+}
+
+func (m *mockStore) RestoreDevice(ctx context.Context, id string) (database.Device, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return database.Device{}, db.ErrInvalidID
+	}
+	for i, d := range m.devices {
+		if d.ID.String() != id {
+			continue
+		}
+		if !d.Deleted {
+			return database.Device{}, &db.StateViolationError{Reason: "cannot restore device: device is not deleted"}
+		}
+		if m.conflicts(d) {
+			return database.Device{}, db.ErrConflict
+		}
+		m.devices[i].Deleted = false
+		m.devices[i].DeletedAt = nil
+		m.devices[i].Version++
+		return m.devices[i], nil
+	}
+	return database.Device{}, db.ErrNotFound
+}
+
+func (m *mockStore) GetAuditRecords(ctx context.Context, query db.AuditQuery) ([]database.AuditRecord, error) {
+	if m.auditErr != nil {
+		return nil, m.auditErr
+	}
+	return m.audit, nil
+}
+
+func newTestWeb(store db.DeviceStore) *Web {
+	gin.SetMode(gin.TestMode)
+	w := &Web{Router: gin.New(), DB: store, States: model.DefaultStateMachine()}
+	w.registerRoutes()
+	return w
+}
+
+func doRequest(w *Web, method, path string, body interface{}) *httptest.ResponseRecorder {
+	var reader *bytes.Reader
+	if body != nil {
+		bodyBytes, _ := json.Marshal(body)
+		reader = bytes.NewReader(bodyBytes)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req, _ := http.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	w.Router.ServeHTTP(rec, req)
+	return rec
+}
+
+func seededStore() *mockStore {
+	return &mockStore{devices: []database.Device{
+		{ID: uuid.MustParse("3fa85f64-5717-4562-b3fc-2c963f66afa6"), Name: "Alpha", Brand: "BrandA", State: "Available", Version: 1},
+		{ID: uuid.MustParse("4fa85f64-5717-4562-b3fc-2c963f66afa7"), Name: "Beta", Brand: "BrandB", State: "Inactive", Version: 1},
+		{ID: uuid.MustParse("5fa85f64-5717-4562-b3fc-2c963f66afa8"), Name: "Gamma", Brand: "BrandA", State: "In-Use", Version: 1},
+	}}
 }
 
 func TestIsValidState(t *testing.T) {
@@ -78,114 +314,617 @@ func TestIsValidState(t *testing.T) {
 }
 
 func TestNewDevice(t *testing.T) {
-	gin.SetMode(gin.TestMode)
-
 	tests := []struct {
 		name       string
 		body       model.Device
-		mockDB     *MockDBNewDevice
+		store      *mockStore
 		wantStatus int
 		wantMsg    string
 	}{
 		{
 			name:       "Missing fields",
 			body:       model.Device{Brand: "BrandX", State: "Available"},
-			mockDB:     &MockDBNewDevice{},
+			store:      &mockStore{},
 			wantStatus: http.StatusBadRequest,
 			wantMsg:    "name, brand, and state are required fields",
 		},
 		{
 			name:       "Invalid state",
 			body:       model.Device{Name: "DeviceA", Brand: "BrandX", State: "Unknown"},
-			mockDB:     &MockDBNewDevice{},
+			store:      &mockStore{},
 			wantStatus: http.StatusBadRequest,
 			wantMsg:    "invalid state value, should be one of: Available, In-Use, Inactive",
 		},
 		{
 			name:       "Duplicate device",
 			body:       model.Device{Name: "DeviceA", Brand: "BrandX", State: "Available"},
-			mockDB:     &MockDBNewDevice{existing: []model.Device{{Name: "DeviceA", Brand: "BrandX", State: "Available"}}},
+			store:      &mockStore{devices: []database.Device{{ID: uuid.New(), Name: "DeviceA", Brand: "BrandX", State: "Available"}}},
 			wantStatus: http.StatusConflict,
 			wantMsg:    "a device with the same name and brand already exists",
 		},
+		{
+			name:       "Similar name is not a duplicate",
+			body:       model.Device{Name: "Alpha", Brand: "BrandX", State: "Available"},
+			store:      &mockStore{devices: []database.Device{{ID: uuid.New(), Name: "AlphaX", Brand: "BrandX", State: "Available"}}},
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "Create error",
+			body:       model.Device{Name: "DeviceB", Brand: "BrandY", State: "Available"},
+			store:      &mockStore{createErr: errors.New("failed to create device: boom")},
+			wantStatus: http.StatusInternalServerError,
+			wantMsg:    "an unexpected error occurred while processing the request",
+		},
 		{
 			name:       "Success",
 			body:       model.Device{Name: "DeviceB", Brand: "BrandY", State: "Available"},
-			mockDB:     &MockDBNewDevice{},
+			store:      &mockStore{},
 			wantStatus: http.StatusCreated,
-			wantMsg:    "",
 		},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			web := &TestWeb{Router: gin.New(), DB: tc.mockDB}
+			rec := doRequest(newTestWeb(tc.store), http.MethodPost, "/api/device/", tc.body)
 
-			rec := httptest.NewRecorder()
-			ctx, _ := gin.CreateTestContext(rec)
-			bodyBytes, _ := json.Marshal(tc.body)
-			ctx.Request, _ = http.NewRequest("POST", "/api/device/", bytes.NewReader(bodyBytes))
-			ctx.Request.Header.Set("Content-Type", "application/json")
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("expected status %d, got %d", tc.wantStatus, rec.Code)
+			}
+			if tc.wantMsg != "" {
+				var resp model.Problem
+				_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+				if resp.Detail != tc.wantMsg {
+					t.Fatalf("expected error message %q, got %q", tc.wantMsg, resp.Detail)
+				}
+			}
+			if tc.wantStatus == http.StatusCreated {
+				var dvc model.Device
+				_ = json.Unmarshal(rec.Body.Bytes(), &dvc)
+				if dvc.ID == "" || dvc.Name != tc.body.Name {
+					t.Fatalf("unexpected created device %+v", dvc)
+				}
+			}
+		})
+	}
+}
 
-			// Call the handler directly, passing the test DB
-			newDeviceWithTestDB(web.DB, ctx)
+func TestGetDeviceByID(t *testing.T) {
+	t.Run("Found", func(t *testing.T) {
+		rec := doRequest(newTestWeb(seededStore()), http.MethodGet, "/api/device/3fa85f64-5717-4562-b3fc-2c963f66afa6", nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+		}
+		var dvc model.Device
+		_ = json.Unmarshal(rec.Body.Bytes(), &dvc)
+		assert.Equal(t, "Alpha", dvc.Name)
+	})
 
+	t.Run("NotFound", func(t *testing.T) {
+		rec := doRequest(newTestWeb(seededStore()), http.MethodGet, "/api/device/00000000-0000-0000-0000-000000000000", nil)
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("expected status %d, got %d", http.StatusNotFound, rec.Code)
+		}
+	})
+
+	t.Run("InvalidID", func(t *testing.T) {
+		rec := doRequest(newTestWeb(seededStore()), http.MethodGet, "/api/device/not-a-uuid", nil)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+		}
+	})
+
+	t.Run("StoreError", func(t *testing.T) {
+		store := seededStore()
+		store.getErr = errors.New("connection refused")
+		rec := doRequest(newTestWeb(store), http.MethodGet, "/api/device/3fa85f64-5717-4562-b3fc-2c963f66afa6", nil)
+		if rec.Code != http.StatusInternalServerError {
+			t.Fatalf("expected status %d, got %d", http.StatusInternalServerError, rec.Code)
+		}
+	})
+}
+
+func TestGetDeviceByFilter(t *testing.T) {
+	cases := []struct {
+		name        string
+		query       string
+		wantStatus  int
+		wantDevices int
+		wantTotal   int64
+		wantHasMore bool
+	}{
+		{"All", "", http.StatusOK, 3, 3, false},
+		{"ByBrand", "?brand=BrandA", http.StatusOK, 2, 2, false},
+		{"ByState", "?state=Inactive", http.StatusOK, 1, 1, false},
+		{"ByPartialName", "?name=amm", http.StatusOK, 1, 1, false},
+		{"Limit", "?limit=1", http.StatusOK, 1, 3, true},
+		{"Start", "?start=2", http.StatusOK, 1, 3, false},
+		{"CountEstimate", "?count=estimate&limit=2", http.StatusOK, 2, 3, true},
+		{"CountNone", "?count=none", http.StatusOK, 3, -1, false},
+		{"InvalidLimit", "?limit=abc", http.StatusBadRequest, 0, 0, false},
+		{"InvalidStart", "?start=abc", http.StatusBadRequest, 0, 0, false},
+		{"NegativeStart", "?start=-5", http.StatusBadRequest, 0, 0, false},
+		{"InvalidCount", "?count=maybe", http.StatusBadRequest, 0, 0, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := doRequest(newTestWeb(seededStore()), http.MethodGet, "/api/device/"+tc.query, nil)
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("expected status %d, got %d", tc.wantStatus, rec.Code)
+			}
+			if tc.wantStatus != http.StatusOK {
+				return
+			}
+			var list model.DeviceList
+			_ = json.Unmarshal(rec.Body.Bytes(), &list)
+			assert.Equal(t, tc.wantDevices, len(list.Devices))
+			assert.Equal(t, tc.wantHasMore, list.HasMore)
+			if tc.wantTotal < 0 {
+				assert.Equal(t, (*int64)(nil), list.Total)
+				return
+			}
+			assert.Equal(t, tc.wantTotal, *list.Total)
+		})
+	}
+
+	t.Run("CountError", func(t *testing.T) {
+		store := seededStore()
+		store.countErr = errors.New("failed to count devices: boom")
+		rec := doRequest(newTestWeb(store), http.MethodGet, "/api/device/", nil)
+		if rec.Code != http.StatusInternalServerError {
+			t.Fatalf("expected status %d, got %d", http.StatusInternalServerError, rec.Code)
+		}
+	})
+
+	t.Run("StoreError", func(t *testing.T) {
+		store := seededStore()
+		store.listErr = errors.New("failed to get devices: boom")
+		rec := doRequest(newTestWeb(store), http.MethodGet, "/api/device/", nil)
+		if rec.Code != http.StatusInternalServerError {
+			t.Fatalf("expected status %d, got %d", http.StatusInternalServerError, rec.Code)
+		}
+	})
+}
+
+func TestGetDeviceByFilter_Cursor(t *testing.T) {
+	w := newTestWeb(db.NewMemory())
+	for _, name := range []string{"Alpha", "Beta", "Gamma", "Delta", "Epsilon"} {
+		doRequest(w, http.MethodPost, "/api/device/", model.Device{Name: name, Brand: "BrandA", State: "Available"})
+	}
+
+	list := func(query string) model.DeviceList {
+		t.Helper()
+		rec := doRequest(w, http.MethodGet, "/api/device/"+query, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+		}
+		var page model.DeviceList
+		_ = json.Unmarshal(rec.Body.Bytes(), &page)
+		return page
+	}
+	names := func(page model.DeviceList) []string {
+		var result []string
+		for _, d := range page.Devices {
+			result = append(result, d.Name)
+		}
+		return result
+	}
+
+	first := list("?limit=2")
+	assert.Equal(t, []string{"Alpha", "Beta"}, names(first))
+	assert.Equal(t, "", first.Prev)
+
+	second := list("?limit=2&cursor=" + first.Next)
+	assert.Equal(t, []string{"Gamma", "Delta"}, names(second))
+
+	last := list("?limit=2&cursor=" + second.Next)
+	assert.Equal(t, []string{"Epsilon"}, names(last))
+	assert.Equal(t, "", last.Next)
+
+	back := list("?limit=2&cursor=" + last.Prev)
+	assert.Equal(t, []string{"Gamma", "Delta"}, names(back))
+	assert.Equal(t, second.Next, back.Next)
+
+	back = list("?limit=2&cursor=" + back.Prev)
+	assert.Equal(t, []string{"Alpha", "Beta"}, names(back))
+	assert.Equal(t, "", back.Prev)
+
+	// Offset pages hand out cursors too
+	offset := list("?limit=2&start=1")
+	assert.Equal(t, []string{"Beta", "Gamma"}, names(offset))
+	assert.Equal(t, []string{"Alpha"}, names(list("?limit=2&cursor="+offset.Prev)))
+
+	cases := []struct {
+		name  string
+		query string
+	}{
+		{"InvalidCursor", "?cursor=not-a-cursor"},
+		{"CursorAndStart", "?start=1&cursor=" + first.Next},
+		{"LimitTooLarge", "?limit=501"},
+		{"LimitTooSmall", "?limit=0"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := doRequest(w, http.MethodGet, "/api/device/"+tc.query, nil)
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+			}
+			var resp model.Problem
+			_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+			assert.Equal(t, codeInvalidQuery, resp.Code)
+		})
+	}
+}
+
+func TestUpdateDevice(t *testing.T) {
+	tests := []struct {
+		name       string
+		id         string
+		body       model.Device
+		wantStatus int
+		wantMsg    string
+		wantDevice model.Device
+	}{
+		{
+			name:       "Full replacement",
+			id:         "3fa85f64-5717-4562-b3fc-2c963f66afa6",
+			body:       model.Device{Name: "Alpha 2", Brand: "BrandC", State: "Inactive"},
+			wantStatus: http.StatusOK,
+			wantDevice: model.Device{Name: "Alpha 2", Brand: "BrandC", State: "Inactive"},
+		},
+		{
+			name:       "Missing fields",
+			id:         "3fa85f64-5717-4562-b3fc-2c963f66afa6",
+			body:       model.Device{State: "Inactive"},
+			wantStatus: http.StatusBadRequest,
+			wantMsg:    "name, brand, and state are required fields",
+		},
+		{
+			name:       "Read-only ID",
+			id:         "3fa85f64-5717-4562-b3fc-2c963f66afa6",
+			body:       model.Device{ID: "4fa85f64-5717-4562-b3fc-2c963f66afa7", Name: "Alpha", Brand: "BrandA", State: "Available"},
+			wantStatus: http.StatusBadRequest,
+			wantMsg:    "read-only fields cannot be changed",
+		},
+		{
+			name:       "No change",
+			id:         "3fa85f64-5717-4562-b3fc-2c963f66afa6",
+			body:       model.Device{ID: "3fa85f64-5717-4562-b3fc-2c963f66afa6", Name: "Alpha", Brand: "BrandA", State: "Available"},
+			wantStatus: http.StatusOK,
+			wantDevice: model.Device{Name: "Alpha", Brand: "BrandA", State: "Available"},
+		},
+		{
+			name:       "Rename in use",
+			id:         "5fa85f64-5717-4562-b3fc-2c963f66afa8",
+			body:       model.Device{Name: "Delta", Brand: "BrandA", State: "In-Use"},
+			wantStatus: http.StatusBadRequest,
+			wantMsg:    "cannot update name or brand: device is currently in use",
+		},
+		{
+			name:       "Not found",
+			id:         "00000000-0000-0000-0000-000000000000",
+			body:       model.Device{Name: "Alpha", Brand: "BrandA", State: "Inactive"},
+			wantStatus: http.StatusNotFound,
+			wantMsg:    "no device found with the given ID",
+		},
+		{
+			name:       "Invalid ID",
+			id:         "not-a-uuid",
+			body:       model.Device{Name: "Alpha", Brand: "BrandA", State: "Inactive"},
+			wantStatus: http.StatusBadRequest,
+			wantMsg:    "invalid device ID, should be a UUID",
+		},
+		{
+			name:       "Rename to existing device",
+			id:         "3fa85f64-5717-4562-b3fc-2c963f66afa6",
+			body:       model.Device{Name: "Gamma", Brand: "BrandA", State: "Available"},
+			wantStatus: http.StatusConflict,
+			wantMsg:    "a device with the same name and brand already exists",
+		},
+		{
+			name:       "Invalid state",
+			id:         "3fa85f64-5717-4562-b3fc-2c963f66afa6",
+			body:       model.Device{Name: "Alpha", Brand: "BrandA", State: "Broken"},
+			wantStatus: http.StatusBadRequest,
+			wantMsg:    "invalid state value, should be one of: Available, In-Use, Inactive",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := doRequest(newTestWeb(seededStore()), http.MethodPut, "/api/device/"+tc.id, tc.body)
 			if rec.Code != tc.wantStatus {
 				t.Fatalf("expected status %d, got %d", tc.wantStatus, rec.Code)
 			}
 			if tc.wantMsg != "" {
-				var resp model.RestError
+				var resp model.Problem
 				_ = json.Unmarshal(rec.Body.Bytes(), &resp)
-				if resp.Message != tc.wantMsg {
-					t.Fatalf("expected error message %q, got %q", tc.wantMsg, resp.Message)
-				}
+				assert.Equal(t, tc.wantMsg, resp.Detail)
+				return
 			}
+			var dvc model.Device
+			_ = json.Unmarshal(rec.Body.Bytes(), &dvc)
+			assert.Equal(t, tc.wantDevice.Name, dvc.Name)
+			assert.Equal(t, tc.wantDevice.Brand, dvc.Brand)
+			assert.Equal(t, tc.wantDevice.State, dvc.State)
 		})
 	}
+
+	t.Run("UpdateError", func(t *testing.T) {
+		store := seededStore()
+		store.updateErr = errors.New("failed to update device: boom")
+		rec := doRequest(newTestWeb(store), http.MethodPut, "/api/device/3fa85f64-5717-4562-b3fc-2c963f66afa6", model.Device{Name: "Alpha", Brand: "BrandA", State: "Inactive"})
+		if rec.Code != http.StatusInternalServerError {
+			t.Fatalf("expected status %d, got %d", http.StatusInternalServerError, rec.Code)
+		}
+	})
+}
+
+func TestDeleteDevice(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		store := seededStore()
+		rec := doRequest(newTestWeb(store), http.MethodDelete, "/api/device/3fa85f64-5717-4562-b3fc-2c963f66afa6", nil)
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("expected status %d, got %d", http.StatusNoContent, rec.Code)
+		}
+		if !store.devices[0].Deleted {
+			t.Fatal("expected device to be soft deleted")
+		}
+	})
+
+	t.Run("InUse", func(t *testing.T) {
+		rec := doRequest(newTestWeb(seededStore()), http.MethodDelete, "/api/device/5fa85f64-5717-4562-b3fc-2c963f66afa8", nil)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+		}
+		var resp model.Problem
+		_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+		assert.Equal(t, "cannot delete device: device is currently in use", resp.Detail)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		rec := doRequest(newTestWeb(seededStore()), http.MethodDelete, "/api/device/00000000-0000-0000-0000-000000000000", nil)
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("expected status %d, got %d", http.StatusNotFound, rec.Code)
+		}
+	})
+
+	t.Run("InvalidID", func(t *testing.T) {
+		rec := doRequest(newTestWeb(seededStore()), http.MethodDelete, "/api/device/not-a-uuid", nil)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+		}
+	})
+
+	t.Run("DeleteError", func(t *testing.T) {
+		store := seededStore()
+		store.deleteErr = errors.New("failed to delete device: boom")
+		rec := doRequest(newTestWeb(store), http.MethodDelete, "/api/device/3fa85f64-5717-4562-b3fc-2c963f66afa6", nil)
+		if rec.Code != http.StatusInternalServerError {
+			t.Fatalf("expected status %d, got %d", http.StatusInternalServerError, rec.Code)
+		}
+	})
 }
 
-// Helper: newDevice handler using DeviceDB interface for tests
-func newDeviceWithTestDB(db DeviceDB, ctx *gin.Context) {
-	var requestBody model.Device
-	if err := ctx.ShouldBindJSON(&requestBody); err != nil {
-		ctx.JSON(http.StatusBadRequest, model.RestError{Message: err.Error()})
-		return
+// TestDeviceLifecycle_Memory runs the whole API against the in-memory store,
+// so it doesn't need any database to be available.
+func TestDeviceLifecycle_Memory(t *testing.T) {
+	w := newTestWeb(db.NewMemory())
+
+	rec := doRequest(w, http.MethodPost, "/api/device/", model.Device{Name: "Alpha", Brand: "BrandA", State: "Available"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, rec.Code)
 	}
+	var created model.Device
+	_ = json.Unmarshal(rec.Body.Bytes(), &created)
 
-	if requestBody.Name == "" || requestBody.Brand == "" || requestBody.State == "" {
-		ctx.JSON(http.StatusBadRequest, model.RestError{Message: "name, brand, and state are required fields"})
-		return
+	rec = doRequest(w, http.MethodPost, "/api/device/", model.Device{Name: "Alpha", Brand: "BrandA", State: "Inactive"})
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected status %d on duplicate, got %d", http.StatusConflict, rec.Code)
 	}
 
-	if !isValidState(requestBody.State) {
-		ctx.JSON(http.StatusBadRequest, model.RestError{Message: "invalid state value, should be one of: Available, In-Use, Inactive"})
-		return
+	rec = doRequest(w, http.MethodPut, "/api/device/"+created.ID, model.Device{Name: "Alpha", Brand: "BrandA", State: "In-Use"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
 	}
 
-	existingDevices, err := db.GetDevices(1, 0, requestBody.Brand, "", requestBody.Name)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, model.RestError{Message: err.Error()})
-		return
+	rec = doRequest(w, http.MethodDelete, "/api/device/"+created.ID, nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d when deleting an in use device, got %d", http.StatusBadRequest, rec.Code)
 	}
-	if len(existingDevices) > 0 {
-		ctx.JSON(http.StatusConflict, model.RestError{Message: "a device with the same name and brand already exists"})
-		return
+
+	rec = doRequest(w, http.MethodPut, "/api/device/"+created.ID, model.Device{Name: "Alpha", Brand: "BrandA", State: "Available"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
 	}
 
-	newDevice := model.Device{
-		Name:  requestBody.Name,
-		Brand: requestBody.Brand,
-		State: requestBody.State,
+	rec = doRequest(w, http.MethodGet, "/api/device/?brand=BrandA", nil)
+	var list model.DeviceList
+	_ = json.Unmarshal(rec.Body.Bytes(), &list)
+	if len(list.Devices) != 1 || list.Devices[0].State != "Available" {
+		t.Fatalf("unexpected device list %+v", list)
 	}
-	dbDevice := newDevice.TranslateToDB()
 
-	err = db.CreateDevice(&dbDevice)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, model.RestError{Message: err.Error()})
-		return
+	rec = doRequest(w, http.MethodDelete, "/api/device/"+created.ID, nil)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d", http.StatusNoContent, rec.Code)
+	}
+
+	rec = doRequest(w, http.MethodGet, "/api/device/?brand=BrandA", nil)
+	list = model.DeviceList{}
+	_ = json.Unmarshal(rec.Body.Bytes(), &list)
+	if len(list.Devices) != 0 || *list.Total != 0 {
+		t.Fatalf("expected deleted device to be hidden, got %+v", list)
 	}
+}
+
+func TestUpdateDevice_StateTransitions(t *testing.T) {
+	t.Run("NotAllowed", func(t *testing.T) {
+		// Beta is Inactive, so it must become Available before being used
+		rec := doRequest(newTestWeb(seededStore()), http.MethodPut, "/api/device/4fa85f64-5717-4562-b3fc-2c963f66afa7", model.Device{Name: "Beta", Brand: "BrandB", State: "In-Use"})
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+		}
+		var resp model.Problem
+		_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+		assert.Equal(t, codeStateViolation, resp.Code)
+		assert.Equal(t, "cannot change state from Inactive to In-Use, allowed states: Available", resp.Detail)
+	})
 
-	var dvc model.Device
-	dvc.TranslateToAPI(dbDevice)
-	ctx.JSON(http.StatusCreated, dvc)
+	t.Run("CustomTransitions", func(t *testing.T) {
+		states, err := model.ParseStateMachine(`{"Inactive": ["In-Use"]}`)
+		if err != nil {
+			t.Fatalf("failed to parse transitions: %v", err)
+		}
+		w := newTestWeb(seededStore())
+		w.States = states
+
+		rec := doRequest(w, http.MethodPut, "/api/device/4fa85f64-5717-4562-b3fc-2c963f66afa7", model.Device{Name: "Beta", Brand: "BrandB", State: "In-Use"})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+		}
+	})
+}
+
+func TestGetDeviceTransitions(t *testing.T) {
+	cases := []struct {
+		name       string
+		id         string
+		wantStatus int
+		want       []string
+	}{
+		{"Available", "3fa85f64-5717-4562-b3fc-2c963f66afa6", http.StatusOK, []string{"In-Use", "Inactive"}},
+		{"Inactive", "4fa85f64-5717-4562-b3fc-2c963f66afa7", http.StatusOK, []string{"Available"}},
+		{"InUse", "5fa85f64-5717-4562-b3fc-2c963f66afa8", http.StatusOK, []string{"Available"}},
+		{"NotFound", "00000000-0000-0000-0000-000000000000", http.StatusNotFound, nil},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := doRequest(newTestWeb(seededStore()), http.MethodGet, "/api/device/"+tc.id+"/transitions", nil)
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("expected status %d, got %d", tc.wantStatus, rec.Code)
+			}
+			if tc.wantStatus != http.StatusOK {
+				return
+			}
+			var resp model.DeviceTransitions
+			_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+			assert.Equal(t, tc.id, resp.ID)
+			assert.Equal(t, tc.want, resp.AllowedStates)
+		})
+	}
+}
+
+// doRequestAs sends a request on behalf of the given actor.
+func doRequestAs(w *Web, method, path, actor string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, nil)
+	req.Header.Set(actorHeader, actor)
+	rec := httptest.NewRecorder()
+	w.Router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestDeletedDevices_Memory(t *testing.T) {
+	w := newTestWeb(db.NewMemory())
+	w.Admins = []string{"admin"}
+
+	rec := doRequest(w, http.MethodPost, "/api/device/", model.Device{Name: "Alpha", Brand: "BrandA", State: "Available"})
+	var created model.Device
+	_ = json.Unmarshal(rec.Body.Bytes(), &created)
+
+	rec = doRequestAs(w, http.MethodDelete, "/api/device/"+created.ID, "jane")
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d", http.StatusNoContent, rec.Code)
+	}
+
+	t.Run("ListNotAdmin", func(t *testing.T) {
+		rec := doRequestAs(w, http.MethodGet, "/api/device/?onlyDeleted=true", "jane")
+		if rec.Code != http.StatusForbidden {
+			t.Fatalf("expected status %d, got %d", http.StatusForbidden, rec.Code)
+		}
+	})
+
+	t.Run("ListInvalidFlag", func(t *testing.T) {
+		rec := doRequestAs(w, http.MethodGet, "/api/device/?includeDeleted=maybe", "admin")
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+		}
+	})
+
+	t.Run("ListDeleted", func(t *testing.T) {
+		rec := doRequestAs(w, http.MethodGet, "/api/device/?onlyDeleted=true", "admin")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+		}
+		var list model.DeviceList
+		_ = json.Unmarshal(rec.Body.Bytes(), &list)
+		if *list.Total != 1 || list.Devices[0].DeletedBy != "jane" || list.Devices[0].DeletedAt == "" {
+			t.Fatalf("unexpected device list %+v", list)
+		}
+	})
+
+	t.Run("RestoreNotAdmin", func(t *testing.T) {
+		rec := doRequestAs(w, http.MethodPost, "/api/device/"+created.ID+"/restore", "jane")
+		if rec.Code != http.StatusForbidden {
+			t.Fatalf("expected status %d, got %d", http.StatusForbidden, rec.Code)
+		}
+	})
+
+	t.Run("NoAdmins", func(t *testing.T) {
+		// Nobody is an admin until some are configured, whatever the actor claims to be
+		unconfigured := newTestWeb(w.DB)
+		for _, actor := range []string{"", "admin", "anonymous"} {
+			rec := doRequestAs(unconfigured, http.MethodGet, "/api/device/?includeDeleted=true", actor)
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+			rec = doRequestAs(unconfigured, http.MethodPost, "/api/device/"+created.ID+"/restore", actor)
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+		}
+	})
+
+	t.Run("Restore", func(t *testing.T) {
+		rec := doRequestAs(w, http.MethodPost, "/api/device/"+created.ID+"/restore", "admin")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+		}
+		var restored model.Device
+		_ = json.Unmarshal(rec.Body.Bytes(), &restored)
+		assert.Equal(t, created.ID, restored.ID)
+		assert.Equal(t, "", restored.DeletedAt)
+		assert.Equal(t, `"3"`, rec.Header().Get("ETag"))
+
+		rec = doRequest(w, http.MethodGet, "/api/device/"+created.ID, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected restored device to be found, got %d", rec.Code)
+		}
+	})
+}
+
+func TestRestoreDevice(t *testing.T) {
+	tests := []struct {
+		name       string
+		id         string
+		wantStatus int
+	}{
+		{"Not deleted", "3fa85f64-5717-4562-b3fc-2c963f66afa6", http.StatusBadRequest},
+		{"Not found", "00000000-0000-0000-0000-000000000000", http.StatusNotFound},
+		{"Invalid ID", "not-a-uuid", http.StatusBadRequest},
+		{"Name taken", "6fa85f64-5717-4562-b3fc-2c963f66afa9", http.StatusConflict},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			store := seededStore()
+			// A deleted Alpha, whose name and brand are now held by the live one
+			store.devices = append(store.devices, database.Device{
+				ID: uuid.MustParse("6fa85f64-5717-4562-b3fc-2c963f66afa9"), Name: "Alpha", Brand: "BrandA", State: "Available", Deleted: true, Version: 2,
+			})
+			w := newTestWeb(store)
+			w.Admins = []string{"admin"}
+			rec := doRequestAs(w, http.MethodPost, "/api/device/"+tc.id+"/restore", "admin")
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("expected status %d, got %d", tc.wantStatus, rec.Code)
+			}
+		})
+	}
 }